@@ -2,8 +2,8 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"github.com/ThreeDotsLabs/watermill"
 	"github.com/ThreeDotsLabs/watermill/message"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/go-go-golems/go-go-labs/cmd/bandcamp/pkg"
@@ -12,29 +12,40 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 	"os"
+	"strings"
+	"sync"
 )
 
 func main() {
 	var rootCmd = &cobra.Command{
 		Use:   "bancamp_search",
 		Short: "Search bandcamp", Long: `Search for music on bandcamp`,
+		// the search keyword, which is not a subcommand
+		Args: cobra.ArbitraryArgs,
 		Run: func(cmd *cobra.Command, args []string) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
 			client := pkg.NewClient()
-			machine, err := machinery.NewMachine()
-			httpServer := machinery.NewHTTPServer()
+			machine, err := machinery.NewMachine(watermill.NopLogger{})
 			cobra.CheckErr(err)
+			listen, _ := cmd.Flags().GetString("listen")
+			httpServer := machinery.NewHTTPServer(listen)
 
 			machine.Router.AddNoPublisherHandler(
 				"httpServer",
-				"playlist",
+				pkg.PlaylistTopic,
 				machine.PubSub,
 				func(msg *message.Message) error {
-					playlist := &pkg.Playlist{}
-					if err := json.Unmarshal(msg.Payload, playlist); err != nil {
-						return err
+					// errors are not returned, as the message would be
+					// redelivered forever, blocking the UI
+					event, err := pkg.NewPlaylistEventFromMessage(msg)
+					if err == nil {
+						err = httpServer.HandlePlaylistEvent(event)
+					}
+					if err != nil {
+						log.Error().Err(err).Msg("failed to handle playlist event")
 					}
-
-					httpServer.HandlePlaylist(playlist)
 					return nil
 				},
 			)
@@ -45,7 +56,7 @@ func main() {
 				log.Fatal().Msg("please provide a search keyword")
 			}
 
-			searchResp, err := client.Search(context.Background(), args[0], pkg.SearchType(filter))
+			searchResp, err := client.Search(ctx, args[0], pkg.SearchType(filter))
 			if err != nil {
 				log.Fatal().Err(err).Msg("failed to search")
 			}
@@ -53,6 +64,9 @@ func main() {
 			results := searchResp.Auto.Results[:3]
 			tracks_ := make([]*pkg.Track, len(results))
 
+			// the item pages are fetched concurrently, each with a timeout,
+			// so that a slow page only delays the start by ItemTimeout
+			var wg sync.WaitGroup
 			for i, result := range results {
 				tracks_[i] = &pkg.Track{
 					BackgroundColor: "black",
@@ -62,10 +76,20 @@ func main() {
 					BandName:        result.BandName,
 					ItemURLPath:     result.ItemURLPath,
 				}
+				wg.Add(1)
+				go func(track *pkg.Track) {
+					defer wg.Done()
+					itemCtx, cancel := context.WithTimeout(ctx, pkg.ItemTimeout)
+					defer cancel()
+					item, err := client.GetItem(itemCtx, track.ItemURLPath)
+					if err != nil {
+						log.Warn().Err(err).Str("url", track.ItemURLPath).Msg("failed to get item metadata")
+						return
+					}
+					track.SetItemInfo(item)
+				}(tracks_[i])
 			}
-
-			// TODO(manuel, 2023-08-16) A cool feature would be to expose the playlist
-			// as a render webpage immediately, so that one can see the final result.
+			wg.Wait()
 
 			playlist_ := &pkg.Playlist{
 				Title:       "Summer Playlist",
@@ -73,6 +97,28 @@ func main() {
 				Tracks:      tracks_,
 			}
 
+			// preview the playlist in the browser while it is being edited
+			if listen != "" {
+				routerErr := make(chan error, 1)
+				go func() {
+					routerErr <- machine.Router.Run(ctx)
+				}()
+				go func() {
+					if err := httpServer.Start(ctx); err != nil {
+						log.Error().Err(err).Msg("failed to run preview server")
+					}
+				}()
+				// the router can fail before running, leaving the playlist
+				// without preview
+				select {
+				case <-machine.Router.Running():
+					httpServer.HandlePlaylist(playlist_.Clone())
+					playlist_.SetPublisher(machine.PubSub)
+				case err := <-routerErr:
+					log.Error().Err(err).Msg("failed to run router, the preview is not updated")
+				}
+			}
+
 			m := playlist.NewModel(playlist_)
 
 			p := tea.NewProgram(m, tea.WithAltScreen())
@@ -89,7 +135,36 @@ func main() {
 		},
 	}
 
+	var exportCmd = &cobra.Command{
+		Use:   "export <playlist.json>",
+		Short: "Export a saved playlist",
+		Long: fmt.Sprintf("Convert a playlist saved as JSON to another format (%s), written to stdout or to --output.",
+			strings.Join(pkg.ExporterNames(), ", ")),
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			force, _ := cmd.Flags().GetBool("force")
+
+			exporter, err := pkg.GetExporter(format)
+			cobra.CheckErr(err)
+			playlist_, err := pkg.LoadFromFile(args[0])
+			cobra.CheckErr(err)
+
+			if output == "" {
+				cobra.CheckErr(exporter.Export(os.Stdout, playlist_))
+				return
+			}
+			cobra.CheckErr(playlist_.ExportToFile(output, exporter, force))
+		},
+	}
+	exportCmd.Flags().String("format", "html", "export format ("+strings.Join(pkg.ExporterNames(), ", ")+")")
+	exportCmd.Flags().StringP("output", "o", "", "file to write to (defaults to stdout)")
+	exportCmd.Flags().Bool("force", false, "overwrite the --output file if it exists")
+	rootCmd.AddCommand(exportCmd)
+
 	rootCmd.Flags().StringP("filter", "f", "", "filter search results by type (album, band, track)")
+	rootCmd.Flags().String("listen", "127.0.0.1:8080", "address of the live preview of the playlist (empty to disable)")
 
 	if err := rootCmd.Execute(); err != nil {
 		log.Fatal().Err(err).Msg("failed to execute command")
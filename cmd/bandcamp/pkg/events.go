@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/message"
+)
+
+// PlaylistTopic is the topic the playlist publishes its events to.
+const PlaylistTopic = "playlist"
+
+type PlaylistEventType string
+
+const (
+	TrackInserted   PlaylistEventType = "track-inserted"
+	TrackDeleted    PlaylistEventType = "track-deleted"
+	TrackMoved      PlaylistEventType = "track-moved"
+	MetadataChanged PlaylistEventType = "metadata-changed"
+)
+
+// PlaylistEvent is a change to a playlist. Subscribers keep a copy of the
+// playlist in sync by applying the events to it, in order.
+type PlaylistEvent struct {
+	Type PlaylistEventType `json:"type"`
+	// Index is the index of the inserted or deleted track, and the index a
+	// track is moved from.
+	Index int `json:"index"`
+	// To is the index a track is moved to.
+	To int `json:"to,omitempty"`
+	// Track is the inserted or deleted track.
+	Track *Track `json:"track,omitempty"`
+	// Title and Description are the new metadata of the playlist.
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+func (e *PlaylistEvent) ToMessage() (*message.Message, error) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	return message.NewMessage(watermill.NewUUID(), b), nil
+}
+
+func NewPlaylistEventFromMessage(msg *message.Message) (*PlaylistEvent, error) {
+	e := &PlaylistEvent{}
+	if err := json.Unmarshal(msg.Payload, e); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Apply applies the event to p, without publishing it again.
+func (e *PlaylistEvent) Apply(p *Playlist) error {
+	switch e.Type {
+	case TrackInserted:
+		if e.Index < 0 || e.Index > len(p.Tracks) {
+			return fmt.Errorf("cannot insert track at %d in a playlist of %d tracks", e.Index, len(p.Tracks))
+		}
+		p.Tracks = append(p.Tracks[:e.Index], append([]*Track{e.Track}, p.Tracks[e.Index:]...)...)
+	case TrackDeleted:
+		if e.Index < 0 || e.Index >= len(p.Tracks) {
+			return fmt.Errorf("cannot delete track %d of a playlist of %d tracks", e.Index, len(p.Tracks))
+		}
+		p.Tracks = append(p.Tracks[:e.Index], p.Tracks[e.Index+1:]...)
+	case TrackMoved:
+		if e.Index < 0 || e.Index >= len(p.Tracks) || e.To < 0 || e.To >= len(p.Tracks) {
+			return fmt.Errorf("cannot move track %d to %d in a playlist of %d tracks", e.Index, e.To, len(p.Tracks))
+		}
+		track := p.Tracks[e.Index]
+		p.Tracks = append(p.Tracks[:e.Index], p.Tracks[e.Index+1:]...)
+		p.Tracks = append(p.Tracks[:e.To], append([]*Track{track}, p.Tracks[e.To:]...)...)
+	case MetadataChanged:
+		p.Title = e.Title
+		p.Description = e.Description
+	default:
+		return fmt.Errorf("unknown playlist event %s", e.Type)
+	}
+	return nil
+}
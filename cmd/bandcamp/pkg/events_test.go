@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"github.com/ThreeDotsLabs/watermill"
+	"github.com/ThreeDotsLabs/watermill/pubsub/gochannel"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPlaylist_PublishesEvents(t *testing.T) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{BlockPublishUntilSubscriberAck: true}, watermill.NopLogger{})
+	defer pubSub.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages, err := pubSub.Subscribe(ctx, PlaylistTopic)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	playlist := &Playlist{Title: "Summer", Tracks: []*Track{{Name: "a"}, {Name: "b"}}}
+	replica := playlist.Clone()
+	playlist.SetPublisher(pubSub)
+
+	var events []*PlaylistEvent
+	received := make(chan struct{})
+	go func() {
+		for msg := range messages {
+			event, err := NewPlaylistEventFromMessage(msg)
+			if err != nil {
+				t.Error(err)
+			}
+			events = append(events, event)
+			msg.Ack()
+			if len(events) == 5 {
+				close(received)
+			}
+		}
+	}()
+
+	playlist.InsertTrack(&Track{Name: "c"}, 1)
+	playlist.MoveEntryDown(0)
+	playlist.MoveEntryUp(2)
+	playlist.DeleteEntry(0)
+	playlist.SetMetadata("Winter", "cold")
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("expected 5 events, got %d", len(events))
+	}
+
+	types := []PlaylistEventType{}
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+	expected := []PlaylistEventType{TrackInserted, TrackMoved, TrackMoved, TrackDeleted, MetadataChanged}
+	if !reflect.DeepEqual(types, expected) {
+		t.Fatalf("expected %v, got %v", expected, types)
+	}
+	if events[1].Index != 0 || events[1].To != 1 || events[3].Track.Name != "c" {
+		t.Fatalf("unexpected events %+v %+v", events[1], events[3])
+	}
+
+	for _, e := range events {
+		if err := e.Apply(replica); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !reflect.DeepEqual(replica, playlist.Clone()) {
+		t.Fatalf("expected replica %s, got %s", playlist.ToJSON(), replica.ToJSON())
+	}
+}
@@ -0,0 +1,270 @@
+package pkg
+
+import (
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exporter writes a playlist in a given format.
+type Exporter interface {
+	// Name is the name of the format, as passed to --format.
+	Name() string
+	// Extension is the file extension of the format, with the dot.
+	Extension() string
+	Export(w io.Writer, p *Playlist) error
+}
+
+var exporters = map[string]Exporter{}
+
+// RegisterExporter makes an exporter available to GetExporter, replacing any
+// exporter with the same name.
+func RegisterExporter(e Exporter) {
+	exporters[e.Name()] = e
+}
+
+func GetExporter(name string) (Exporter, error) {
+	e, ok := exporters[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown export format %s (available: %s)", name, strings.Join(ExporterNames(), ", "))
+	}
+	return e, nil
+}
+
+// ExporterNames returns the names of the registered exporters, sorted.
+func ExporterNames() []string {
+	ret := make([]string, 0, len(exporters))
+	for name := range exporters {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func init() {
+	RegisterExporter(&htmlExporter{})
+	RegisterExporter(&markdownExporter{})
+	RegisterExporter(&m3uExporter{})
+	RegisterExporter(&xspfExporter{})
+	RegisterExporter(&csvExporter{})
+}
+
+// URL returns the URL of the Bandcamp page of the track.
+func (t *Track) URL() string {
+	if strings.HasPrefix(t.ItemURLPath, "http://") || strings.HasPrefix(t.ItemURLPath, "https://") {
+		return t.ItemURLPath
+	}
+	return "https://bandcamp.com/" + strings.TrimPrefix(t.ItemURLPath, "/")
+}
+
+type htmlExporter struct{}
+
+func (e *htmlExporter) Name() string      { return "html" }
+func (e *htmlExporter) Extension() string { return ".html" }
+
+var htmlPageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <style>
+    body { font-family: sans-serif; max-width: 700px; margin: 2em auto; color: #222; }
+    header { border-bottom: 2px solid #1da0c3; margin-bottom: 1.5em; padding-bottom: 0.5em; }
+    header h1 { margin: 0; }
+    header p { margin: 0.3em 0; color: #666; }
+    .duration { color: #666; font-size: 0.9em; }
+  </style>
+</head>
+<body>
+  <header>
+    <h1>{{.Title}}</h1>
+    {{- if .Description}}
+    <p>{{.Description}}</p>
+    {{- end}}
+    <p>{{.Tracks}} tracks{{if .Runtime}}, {{.Runtime}}{{end}}</p>
+  </header>
+{{.HTML}}
+</body>
+</html>
+`))
+
+func (e *htmlExporter) Export(w io.Writer, p *Playlist) error {
+	rendered, err := p.Render()
+	if err != nil {
+		return err
+	}
+	runtime := ""
+	if duration := p.Duration(); duration > 0 {
+		runtime = FormatDuration(duration)
+	}
+	return htmlPageTemplate.Execute(w, map[string]interface{}{
+		"Title":       p.Title,
+		"Description": p.Description,
+		"Tracks":      len(p.Tracks),
+		"Runtime":     runtime,
+		// Render escapes the track fields with html/template
+		"HTML": template.HTML(rendered),
+	})
+}
+
+type markdownExporter struct{}
+
+func (e *markdownExporter) Name() string      { return "markdown" }
+func (e *markdownExporter) Extension() string { return ".md" }
+
+var markdownEscaper = strings.NewReplacer(`\`, `\\`, `[`, `\[`, `]`, `\]`, `*`, `\*`, `_`, `\_`)
+
+func (e *markdownExporter) Export(w io.Writer, p *Playlist) error {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "# %s\n\n", markdownEscaper.Replace(p.Title))
+	if p.Description != "" {
+		fmt.Fprintf(sb, "%s\n\n", markdownEscaper.Replace(p.Description))
+	}
+	for i, track := range p.Tracks {
+		fmt.Fprintf(sb, "%d. [%s](%s) by %s", i+1,
+			markdownEscaper.Replace(track.Name), track.URL(), markdownEscaper.Replace(track.BandName))
+		if track.Duration > 0 {
+			fmt.Fprintf(sb, " (%s)", FormatDuration(track.Duration))
+		}
+		sb.WriteString("\n")
+	}
+	if duration := p.Duration(); duration > 0 {
+		fmt.Fprintf(sb, "\nTotal runtime: %s\n", FormatDuration(duration))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// m3uExporter writes an extended M3U playlist of the Bandcamp pages of the
+// tracks.
+type m3uExporter struct{}
+
+func (e *m3uExporter) Name() string      { return "m3u" }
+func (e *m3uExporter) Extension() string { return ".m3u" }
+
+func (e *m3uExporter) Export(w io.Writer, p *Playlist) error {
+	sb := &strings.Builder{}
+	sb.WriteString("#EXTM3U\n")
+	if p.Title != "" {
+		fmt.Fprintf(sb, "#PLAYLIST:%s\n", p.Title)
+	}
+	for _, track := range p.Tracks {
+		duration := -1
+		if track.Duration > 0 {
+			duration = int(track.Duration + 0.5)
+		}
+		fmt.Fprintf(sb, "#EXTINF:%d,%s - %s\n%s\n", duration, track.BandName, track.Name, track.URL())
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// xspfExporter writes an XSPF playlist, see https://xspf.org/spec.
+type xspfExporter struct{}
+
+func (e *xspfExporter) Name() string      { return "xspf" }
+func (e *xspfExporter) Extension() string { return ".xspf" }
+
+type xspfPlaylist struct {
+	XMLName    xml.Name    `xml:"http://xspf.org/ns/0/ playlist"`
+	Version    string      `xml:"version,attr"`
+	Title      string      `xml:"title,omitempty"`
+	Annotation string      `xml:"annotation,omitempty"`
+	Tracks     []xspfTrack `xml:"trackList>track"`
+}
+
+type xspfTrack struct {
+	Location string `xml:"location"`
+	Title    string `xml:"title,omitempty"`
+	Creator  string `xml:"creator,omitempty"`
+	Image    string `xml:"image,omitempty"`
+	TrackNum int    `xml:"trackNum,omitempty"`
+	// Duration is in milliseconds.
+	Duration int64 `xml:"duration,omitempty"`
+}
+
+func (e *xspfExporter) Export(w io.Writer, p *Playlist) error {
+	playlist := xspfPlaylist{
+		Version:    "1",
+		Title:      p.Title,
+		Annotation: p.Description,
+		Tracks:     []xspfTrack{},
+	}
+	for _, track := range p.Tracks {
+		playlist.Tracks = append(playlist.Tracks, xspfTrack{
+			Location: track.URL(),
+			Title:    track.Name,
+			Creator:  track.BandName,
+			Image:    track.ArtworkURL,
+			TrackNum: track.TrackNumber,
+			Duration: int64(track.Duration * 1000),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(playlist); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+type csvExporter struct{}
+
+func (e *csvExporter) Name() string      { return "csv" }
+func (e *csvExporter) Extension() string { return ".csv" }
+
+func (e *csvExporter) Export(w io.Writer, p *Playlist) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{
+		"name", "band_name", "url", "album_id", "track_id", "track_number", "duration", "release_date", "tags",
+	})
+	if err != nil {
+		return err
+	}
+	for _, track := range p.Tracks {
+		releaseDate := ""
+		if track.ReleaseDate != nil {
+			releaseDate = track.ReleaseDate.Format("2006-01-02")
+		}
+		err := cw.Write([]string{
+			track.Name,
+			track.BandName,
+			track.URL(),
+			strconv.FormatInt(track.AlbumID, 10),
+			formatOptionalInt(track.TrackID),
+			formatOptionalInt(int64(track.TrackNumber)),
+			formatOptionalFloat(track.Duration),
+			releaseDate,
+			strings.Join(track.Tags, ";"),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatOptionalInt(i int64) string {
+	if i == 0 {
+		return ""
+	}
+	return strconv.FormatInt(i, 10)
+}
+
+func formatOptionalFloat(f float64) string {
+	if f == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
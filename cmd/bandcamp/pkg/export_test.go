@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testPlaylist() *Playlist {
+	releaseDate := time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC)
+	return &Playlist{
+		Title:       "Night [Mix]",
+		Description: "For driving",
+		Tracks: []*Track{
+			{
+				AlbumID: 987654321, TrackID: 2222, TrackNumber: 2, Duration: 184.653,
+				Name: "Overpass", BandName: "Night City",
+				ItemURLPath: "https://nightcity.bandcamp.com/track/overpass",
+				ReleaseDate: &releaseDate, Tags: []string{"synthwave", "electronic"},
+			},
+			{AlbumID: 42, Name: "Whole Album", BandName: "Other, Band", ItemURLPath: "other/album/whole-album"},
+		},
+	}
+}
+
+func export(t *testing.T, format string) string {
+	e, err := GetExporter(format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := &bytes.Buffer{}
+	if err := e.Export(buf, testPlaylist()); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestExporters(t *testing.T) {
+	if _, err := GetExporter("wav"); err == nil {
+		t.Fatal("expected an error for an unknown format")
+	}
+
+	expected := "# Night \\[Mix\\]\n\nFor driving\n\n" +
+		"1. [Overpass](https://nightcity.bandcamp.com/track/overpass) by Night City (3:05)\n" +
+		"2. [Whole Album](https://bandcamp.com/other/album/whole-album) by Other, Band\n\n" +
+		"Total runtime: 3:05\n"
+	if got := export(t, "markdown"); got != expected {
+		t.Errorf("expected markdown\n%s\ngot\n%s", expected, got)
+	}
+
+	expected = "#EXTM3U\n#PLAYLIST:Night [Mix]\n" +
+		"#EXTINF:185,Night City - Overpass\nhttps://nightcity.bandcamp.com/track/overpass\n" +
+		"#EXTINF:-1,Other, Band - Whole Album\nhttps://bandcamp.com/other/album/whole-album\n"
+	if got := export(t, "m3u"); got != expected {
+		t.Errorf("expected m3u\n%s\ngot\n%s", expected, got)
+	}
+
+	playlist := xspfPlaylist{}
+	if err := xml.Unmarshal([]byte(export(t, "xspf")), &playlist); err != nil {
+		t.Fatal(err)
+	}
+	if playlist.Title != "Night [Mix]" || len(playlist.Tracks) != 2 ||
+		playlist.Tracks[0].Duration != 184653 || playlist.Tracks[1].Location != "https://bandcamp.com/other/album/whole-album" {
+		t.Errorf("unexpected xspf %+v", playlist)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(export(t, "csv"))).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || strings.Join(records[1], "|") !=
+		"Overpass|Night City|https://nightcity.bandcamp.com/track/overpass|987654321|2222|2|184.653|2021-03-19|synthwave;electronic" {
+		t.Errorf("unexpected csv %v", records)
+	}
+
+	html := export(t, "html")
+	for _, s := range []string{"<h1>Night [Mix]</h1>", "<p>2 tracks, 3:05</p>", "EmbeddedPlayer/track=2222/"} {
+		if !strings.Contains(html, s) {
+			t.Errorf("expected %q in\n%s", s, html)
+		}
+	}
+}
+
+func TestHTMLExporter_EscapesTracks(t *testing.T) {
+	e, err := GetExporter("html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testPlaylist()
+	p.Title = "<b>Night</b>"
+	p.Tracks[0].Name = "<script>alert(1)</script>"
+	p.Tracks[0].BandName = "Salt & Pepper"
+	buf := &bytes.Buffer{}
+	if err := e.Export(buf, p); err != nil {
+		t.Fatal(err)
+	}
+	html := buf.String()
+	for _, s := range []string{"<script>", "<b>", "Salt & Pepper"} {
+		if strings.Contains(html, s) {
+			t.Errorf("unexpected %q in\n%s", s, html)
+		}
+	}
+	for _, s := range []string{"&lt;script&gt;", "&lt;b&gt;Night&lt;/b&gt;", "Salt &amp; Pepper", "<iframe"} {
+		if !strings.Contains(html, s) {
+			t.Errorf("expected %q in\n%s", s, html)
+		}
+	}
+}
+
+type failingExporter struct{}
+
+func (e *failingExporter) Name() string      { return "failing" }
+func (e *failingExporter) Extension() string { return ".txt" }
+func (e *failingExporter) Export(w io.Writer, p *Playlist) error {
+	_, _ = io.WriteString(w, "partial")
+	return errors.New("export failed")
+}
+
+func TestPlaylist_ExportToFile(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "night.m3u")
+	m3u, err := GetExporter("m3u")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := testPlaylist()
+
+	if err := p.ExportToFile(filename, m3u, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ExportToFile(filename, m3u, false); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("expected the existing file to be kept, got %v", err)
+	}
+
+	p.Title = "Day"
+	if err := p.ExportToFile(filename, &failingExporter{}, true); err == nil {
+		t.Fatal("expected the export to fail")
+	}
+	content, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "#EXTM3U\n#PLAYLIST:Night [Mix]\n") {
+		t.Fatalf("expected a failed export to leave the file untouched, got\n%s", content)
+	}
+
+	if err := p.ExportToFile(filename, m3u, true); err != nil {
+		t.Fatal(err)
+	}
+	content, err = os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "#EXTM3U\n#PLAYLIST:Day\n") {
+		t.Fatalf("expected the file to be overwritten, got\n%s", content)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected the temporary files to be removed, got %v", entries)
+	}
+}
@@ -0,0 +1,185 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ItemInfo is the metadata of an album or track page.
+type ItemInfo struct {
+	// Type is album or track.
+	Type        string
+	ID          int64
+	Title       string
+	Artist      string
+	URL         string
+	ReleaseDate *time.Time
+	Tags        []string
+	ArtID       int64
+	ArtworkURL  string
+	// Tracks are the tracks of an album, or the track of a track page.
+	Tracks []*TrackInfo
+}
+
+type TrackInfo struct {
+	ID          int64
+	Title       string
+	TrackNumber int
+	// Duration is in seconds.
+	Duration float64
+	URL      string
+}
+
+// Duration returns the sum of the durations of the tracks, in seconds.
+func (i *ItemInfo) Duration() float64 {
+	ret := 0.0
+	for _, t := range i.Tracks {
+		ret += t.Duration
+	}
+	return ret
+}
+
+// tralbum is the part of the data-tralbum attribute of item pages that we
+// use.
+type tralbum struct {
+	ItemType         string `json:"item_type"`
+	ID               int64  `json:"id"`
+	ArtID            int64  `json:"art_id"`
+	Artist           string `json:"artist"`
+	URL              string `json:"url"`
+	AlbumReleaseDate string `json:"album_release_date"`
+	Current          struct {
+		Title       string `json:"title"`
+		ReleaseDate string `json:"release_date"`
+		PublishDate string `json:"publish_date"`
+		TrackNumber int    `json:"track_number"`
+	} `json:"current"`
+	TrackInfo []struct {
+		ID        int64   `json:"id"`
+		TrackID   int64   `json:"track_id"`
+		Title     string  `json:"title"`
+		TrackNum  int     `json:"track_num"`
+		Duration  float64 `json:"duration"`
+		TitleLink string  `json:"title_link"`
+	} `json:"trackinfo"`
+}
+
+// ItemTimeout is how long to wait for an item page. Its metadata is only
+// nice to have, and the HTTP client has no timeout of its own.
+const ItemTimeout = 5 * time.Second
+
+// GetItem fetches the album or track page at itemURL and returns its
+// metadata.
+func (c *Client) GetItem(ctx context.Context, itemURL string) (*ItemInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, itemURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return ParseItemPage(itemURL, resp.Body)
+}
+
+// ParseItemPage parses the album or track page read from r, which was
+// fetched from itemURL.
+func ParseItemPage(itemURL string, r io.Reader) (*ItemInfo, error) {
+	doc, err := goquery.NewDocumentFromReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := doc.Find("[data-tralbum]").First().Attr("data-tralbum")
+	if !ok {
+		return nil, fmt.Errorf("no data-tralbum in %s", itemURL)
+	}
+	t := &tralbum{}
+	if err := json.Unmarshal([]byte(data), t); err != nil {
+		return nil, fmt.Errorf("could not parse data-tralbum of %s: %w", itemURL, err)
+	}
+
+	ret := &ItemInfo{
+		Type:   t.ItemType,
+		ID:     t.ID,
+		Title:  t.Current.Title,
+		Artist: t.Artist,
+		URL:    t.URL,
+		ArtID:  t.ArtID,
+	}
+	if ret.URL == "" {
+		ret.URL = itemURL
+	}
+	base, err := url.Parse(ret.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, date := range []string{t.Current.ReleaseDate, t.AlbumReleaseDate, t.Current.PublishDate} {
+		if d, err := time.Parse("02 Jan 2006 15:04:05 MST", date); err == nil {
+			ret.ReleaseDate = &d
+			break
+		}
+	}
+
+	doc.Find("a.tag").Each(func(_ int, s *goquery.Selection) {
+		if tag := strings.TrimSpace(s.Text()); tag != "" {
+			ret.Tags = append(ret.Tags, tag)
+		}
+	})
+
+	if href, ok := doc.Find("a.popupImage").First().Attr("href"); ok {
+		ret.ArtworkURL = href
+	} else if t.ArtID != 0 {
+		ret.ArtworkURL = fmt.Sprintf("https://f4.bcbits.com/img/a%010d_10.jpg", t.ArtID)
+	}
+
+	for _, ti := range t.TrackInfo {
+		track := &TrackInfo{
+			ID:          ti.TrackID,
+			Title:       ti.Title,
+			TrackNumber: ti.TrackNum,
+			Duration:    ti.Duration,
+		}
+		if track.ID == 0 {
+			track.ID = ti.ID
+		}
+		if track.TrackNumber == 0 && ret.Type == "track" {
+			track.TrackNumber = t.Current.TrackNumber
+		}
+		if ti.TitleLink != "" {
+			if u, err := base.Parse(ti.TitleLink); err == nil {
+				track.URL = u.String()
+			}
+		}
+		ret.Tracks = append(ret.Tracks, track)
+	}
+
+	return ret, nil
+}
+
+// FormatDuration formats a duration in seconds as 3:05 or 1:02:03.
+func FormatDuration(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second)).Round(time.Second)
+	h, m, s := int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,82 @@
+package pkg
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The fixtures are item pages trimmed down to the parts that are parsed.
+
+func parseFixture(t *testing.T, name string, url string) *ItemInfo {
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	item, err := ParseItemPage(url, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return item
+}
+
+func TestParseItemPage_Album(t *testing.T) {
+	item := parseFixture(t, "album.html", "https://nightcity.bandcamp.com/album/night-drive")
+
+	if item.Type != "album" || item.ID != 987654321 || item.Title != "Night Drive" || item.Artist != "Night City" {
+		t.Fatalf("unexpected item %+v", item)
+	}
+	if item.ReleaseDate == nil || !item.ReleaseDate.Equal(time.Date(2021, 3, 19, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected release date %v", item.ReleaseDate)
+	}
+	if expected := []string{"synthwave", "electronic", "Berlin"}; !reflect.DeepEqual(item.Tags, expected) {
+		t.Fatalf("expected tags %v, got %v", expected, item.Tags)
+	}
+	if item.ArtworkURL != "https://f4.bcbits.com/img/a1234567890_10.jpg" {
+		t.Fatalf("unexpected artwork %s", item.ArtworkURL)
+	}
+
+	got := []TrackInfo{}
+	for _, track := range item.Tracks {
+		got = append(got, *track)
+	}
+	expected := []TrackInfo{
+		{ID: 1111, Title: "Headlights", TrackNumber: 1, Duration: 215.347, URL: "https://nightcity.bandcamp.com/track/headlights"},
+		{ID: 2222, Title: "Overpass", TrackNumber: 2, Duration: 184.653, URL: "https://nightcity.bandcamp.com/track/overpass"},
+		{ID: 3333, Title: "Last Exit", TrackNumber: 3, Duration: 301, URL: "https://nightcity.bandcamp.com/track/last-exit"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected tracks %+v, got %+v", expected, got)
+	}
+	if FormatDuration(item.Duration()) != "11:41" {
+		t.Fatalf("unexpected album duration %s", FormatDuration(item.Duration()))
+	}
+}
+
+func TestParseItemPage_Track(t *testing.T) {
+	item := parseFixture(t, "track.html", "https://nightcity.bandcamp.com/track/overpass")
+
+	track := &Track{AlbumID: 987654321, Name: "Overpass", BandName: "Night City"}
+	track.SetItemInfo(item)
+	if track.TrackID != 2222 || track.TrackNumber != 2 || track.Duration != 184.653 {
+		t.Fatalf("unexpected track %+v", track)
+	}
+	// tracks without a release date of their own use the album's
+	if track.ReleaseDate == nil || track.ReleaseDate.Year() != 2021 {
+		t.Fatalf("unexpected release date %v", track.ReleaseDate)
+	}
+
+	playlist := &Playlist{Tracks: []*Track{track, {AlbumID: 1, Duration: 3600}}}
+	rendered, err := playlist.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"EmbeddedPlayer/track=2222/", "EmbeddedPlayer/album=1/", ">3:05<", "Total runtime: 1:03:05"} {
+		if !strings.Contains(rendered, s) {
+			t.Errorf("expected %q in\n%s", s, rendered)
+		}
+	}
+}
@@ -2,66 +2,220 @@ package machinery
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"github.com/go-go-golems/go-go-labs/cmd/bandcamp/pkg"
+	"html/template"
 	"net/http"
+	"sync"
+	"time"
 )
 
+// HTTPServer serves a preview page of the playlist, which is updated live
+// over server-sent events whenever HandlePlaylist or HandlePlaylistEvent is
+// called.
 type HTTPServer struct {
-	s        *http.Server
+	s *http.Server
+
+	mu       sync.Mutex
 	Playlist *pkg.Playlist
+	// clients are the channels of the connected /events streams
+	clients map[chan []byte]struct{}
 }
 
-func NewHTTPServer() *HTTPServer {
-	return &HTTPServer{
-		s: &http.Server{
-			Addr: ":8080",
-		},
-		Playlist: nil,
+func NewHTTPServer(addr string) *HTTPServer {
+	ret := &HTTPServer{
+		clients: map[chan []byte]struct{}{},
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", ret.handleIndex)
+	mux.HandleFunc("/events", ret.handleEvents)
+	ret.s = &http.Server{
+		Addr:    addr,
+		Handler: mux,
 	}
+	return ret
 }
 
+// Start serves until ctx is cancelled.
 func (s *HTTPServer) Start(ctx context.Context) error {
-	http.Handle("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
-		}
-		//fsys := os.DirFS(".")
-		//f, err := fsys.Open(indexFileName)
-		//if errors.Is(err, fs.ErrNotExist) {
-		//	http.Error(w, "index.html not found", http.StatusNotFound)
-		//	return
-		//}
-		//defer func(f fs.File) {
-		//	_ = f.Close()
-		//}(f)
-		//
-		//if s.Playlist == nil {
-		//	http.Error(w, "playlist not found", http.StatusNotFound)
-		//	return
-		//}
-		//rendered, err := s.Playlist.Render()
-		//if err != nil {
-		//	http.Error(w, err.Error(), http.StatusInternalServerError)
-		//	return
-		//}
-		//
-		//fileTemplate, err := template.ParseFiles(indexFileName) // assuming index.html is template file
-		//if err != nil {
-		//	http.Error(w, err.Error(), http.StatusInternalServerError)
-		//	return
-		//}
-		//
-		//// Execute the template with the rendered iframe strings
-		//err = fileTemplate.Execute(w, rendered)
-		//if err != nil {
-		//	http.Error(w, err.Error(), http.StatusInternalServerError)
-		//	return
-		//}
-	}))
-	return s.s.ListenAndServe()
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = s.s.Shutdown(shutdownCtx)
+	}()
+
+	err := s.s.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
 }
 
+// HandlePlaylist replaces the playlist shown by the server, and pushes it to
+// the open pages. The server modifies the playlist when handling events.
 func (s *HTTPServer) HandlePlaylist(playlist *pkg.Playlist) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
 	s.Playlist = playlist
+	s.broadcast()
+}
+
+// HandlePlaylistEvent applies an event to the playlist shown by the server,
+// and pushes it to the open pages.
+func (s *HTTPServer) HandlePlaylistEvent(event *pkg.PlaylistEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Playlist == nil {
+		s.Playlist = &pkg.Playlist{}
+	}
+	if err := event.Apply(s.Playlist); err != nil {
+		return err
+	}
+	s.broadcast()
+	return nil
+}
+
+// broadcast sends the current playlist to the open pages. s.mu must be held.
+func (s *HTTPServer) broadcast() {
+	update, err := s.renderUpdate()
+	if err != nil {
+		return
+	}
+	for client := range s.clients {
+		// clients that are not keeping up only get the latest playlist
+		select {
+		case client <- update:
+		default:
+			select {
+			case <-client:
+			default:
+			}
+			client <- update
+		}
+	}
+}
+
+// playlistUpdate is the data of the events sent to the page.
+type playlistUpdate struct {
+	Title       string        `json:"title"`
+	Description string        `json:"description"`
+	HTML        template.HTML `json:"html"`
+}
+
+func (s *HTTPServer) currentUpdate() (*playlistUpdate, error) {
+	if s.Playlist == nil {
+		return &playlistUpdate{}, nil
+	}
+	rendered, err := s.Playlist.Render()
+	if err != nil {
+		return nil, err
+	}
+	return &playlistUpdate{
+		Title:       s.Playlist.Title,
+		Description: s.Playlist.Description,
+		HTML:        template.HTML(rendered),
+	}, nil
+}
+
+// renderUpdate returns the current playlist as the JSON data of an event.
+func (s *HTTPServer) renderUpdate() ([]byte, error) {
+	update, err := s.currentUpdate()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(update)
+}
+
+func (s *HTTPServer) handleIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	s.mu.Lock()
+	update, err := s.currentUpdate()
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := indexTemplate.Execute(w, update); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
 }
+
+func (s *HTTPServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// the current playlist is sent first, so that reconnecting pages catch
+	// up with the updates they missed
+	client := make(chan []byte, 1)
+	s.mu.Lock()
+	if update, err := s.renderUpdate(); err == nil {
+		client <- update
+	}
+	s.clients[client] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case update := <-client:
+			if _, err := fmt.Fprintf(w, "event: playlist\ndata: %s\n\n", update); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>{{.Title}}</title>
+  <style>
+    body { font-family: sans-serif; max-width: 700px; margin: 2em auto; }
+  </style>
+</head>
+<body>
+  <h1 id="title">{{.Title}}</h1>
+  <p id="description">{{.Description}}</p>
+  <div id="playlist">
+{{.HTML}}
+  </div>
+  <script>
+    const events = new EventSource("/events");
+    events.addEventListener("playlist", (e) => {
+      const update = JSON.parse(e.data);
+      document.title = update.title;
+      document.getElementById("title").textContent = update.title;
+      document.getElementById("description").textContent = update.description;
+      document.getElementById("playlist").innerHTML = update.html;
+    });
+  </script>
+</body>
+</html>
+`))
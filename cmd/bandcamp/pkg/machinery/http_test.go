@@ -0,0 +1,59 @@
+package machinery
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/go-go-golems/go-go-labs/cmd/bandcamp/pkg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPServer_Events(t *testing.T) {
+	s := NewHTTPServer("")
+	s.HandlePlaylist(&pkg.Playlist{Title: "First", Tracks: []*pkg.Track{{AlbumID: 1, Name: "One"}}})
+	ts := httptest.NewServer(s.s.Handler)
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(body), "album=1/") || !strings.Contains(string(body), "<h1 id=\"title\">First</h1>") {
+		t.Fatalf("unexpected page %s", body)
+	}
+
+	resp, err = http.Get(ts.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	r := bufio.NewReader(resp.Body)
+	readUpdate := func() playlistUpdate {
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				update := playlistUpdate{}
+				if err := json.Unmarshal([]byte(data), &update); err != nil {
+					t.Fatal(err)
+				}
+				return update
+			}
+		}
+	}
+
+	if update := readUpdate(); update.Title != "First" {
+		t.Fatalf("expected the current playlist first, got %v", update)
+	}
+	s.HandlePlaylist(&pkg.Playlist{Title: "Second", Tracks: []*pkg.Track{{AlbumID: 2, Name: "Two"}}})
+	if update := readUpdate(); update.Title != "Second" || !strings.Contains(string(update.HTML), "album=2/") {
+		t.Fatalf("unexpected update %v", update)
+	}
+}
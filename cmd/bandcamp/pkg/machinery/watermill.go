@@ -11,9 +11,14 @@ type Machine struct {
 	PubSub *gochannel.GoChannel
 }
 
-func NewMachine() (*Machine, error) {
-	pubSub := gochannel.NewGoChannel(gochannel.Config{}, watermill.NewStdLogger(false, false))
-	r, err := message.NewRouter(message.RouterConfig{}, watermill.NewStdLogger(false, false))
+// NewMachine creates a gochannel pubsub and a router logging to logger. Use
+// watermill.NopLogger{} while a TUI owns the terminal.
+//
+// Publishing blocks until subscribers have handled the message, so that
+// they receive playlist events in order.
+func NewMachine(logger watermill.LoggerAdapter) (*Machine, error) {
+	pubSub := gochannel.NewGoChannel(gochannel.Config{BlockPublishUntilSubscriberAck: true}, logger)
+	r, err := message.NewRouter(message.RouterConfig{}, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -3,13 +3,15 @@ package pkg
 import (
 	"bytes"
 	"encoding/json"
-	"github.com/ThreeDotsLabs/watermill"
+	"fmt"
 	"github.com/ThreeDotsLabs/watermill/message"
 	"github.com/rs/zerolog/log"
+	"html/template"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"runtime"
-	"text/template"
+	"time"
 )
 
 type Track struct {
@@ -19,6 +21,31 @@ type Track struct {
 	Name            string `json:"name"`
 	BandName        string `json:"band_name"`
 	ItemURLPath     string `json:"item_url_path"`
+
+	// The following fields are set from the item page, see SetItemInfo.
+
+	// TrackID is set for single tracks, which are embedded instead of their
+	// album.
+	TrackID     int64      `json:"track_id,omitempty"`
+	TrackNumber int        `json:"track_number,omitempty"`
+	Duration    float64    `json:"duration,omitempty"`
+	ReleaseDate *time.Time `json:"release_date,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	ArtworkURL  string     `json:"artwork_url,omitempty"`
+}
+
+// SetItemInfo sets the metadata of the track from its item page. Tracks of
+// track pages become single tracks, while tracks of album pages last as long
+// as the whole album.
+func (t *Track) SetItemInfo(item *ItemInfo) {
+	t.ReleaseDate = item.ReleaseDate
+	t.Tags = item.Tags
+	t.ArtworkURL = item.ArtworkURL
+	if item.Type == "track" && len(item.Tracks) == 1 {
+		t.TrackID = item.Tracks[0].ID
+		t.TrackNumber = item.Tracks[0].TrackNumber
+	}
+	t.Duration = item.Duration()
 }
 
 type Playlist struct {
@@ -30,15 +57,20 @@ type Playlist struct {
 }
 
 const iframeTmpl = `<iframe
-   style="border: 0; width: 100%%; height: 42px; background-color: {{.BackgroundColor}};" 
-   src="https://bandcamp.com/EmbeddedPlayer/album={{.AlbumID}}/size=small/bgcol={{.BackgroundColor}}/linkcol={{.LinkColor}}/transparent=true/" seamless>
-     <a href="https://bandcamp.com/{{.ItemURLPath}}">{{.Name}} by {{.BandName}}</a>
-</iframe>`
+   style="border: 0; width: 100%; height: 42px; background-color: {{.BackgroundColor}};" 
+   src="https://bandcamp.com/EmbeddedPlayer/{{if .TrackID}}track={{.TrackID}}{{else}}album={{.AlbumID}}{{end}}/size=small/bgcol={{.BackgroundColor}}/linkcol={{.LinkColor}}/transparent=true/" seamless>
+     <a href="{{.URL}}">{{.Name}} by {{.BandName}}</a>
+</iframe>{{if .Duration}}
+<span class="duration">{{formatDuration .Duration}}</span>{{end}}`
 
+// Render returns the HTML of the embedded players of the playlist. The track
+// fields come from artists and are escaped.
 func (p *Playlist) Render() (string, error) {
 	var out bytes.Buffer
 
-	tmpl, err := template.New("playlist").Parse(iframeTmpl)
+	tmpl, err := template.New("playlist").
+		Funcs(template.FuncMap{"formatDuration": FormatDuration}).
+		Parse(iframeTmpl)
 	if err != nil {
 		return "", err
 	}
@@ -56,9 +88,23 @@ func (p *Playlist) Render() (string, error) {
 			return "", err
 		}
 	}
+
+	if duration := p.Duration(); duration > 0 {
+		_, _ = fmt.Fprintf(&out, "<p class=\"runtime\">Total runtime: %s</p>\n", FormatDuration(duration))
+	}
 	return out.String(), nil
 }
 
+// Duration returns the total runtime of the playlist in seconds, counting
+// the tracks whose duration is known.
+func (p *Playlist) Duration() float64 {
+	ret := 0.0
+	for _, track := range p.Tracks {
+		ret += track.Duration
+	}
+	return ret
+}
+
 func LoadFromFile(filename string) (*Playlist, error) {
 	b, err := os.ReadFile(filename)
 	if err != nil {
@@ -83,6 +129,40 @@ func (p *Playlist) SaveToFile(filename string) error {
 	return os.WriteFile(filename, b, 0644)
 }
 
+// ExportToFile writes the playlist to filename in the format of e. Unless
+// overwrite is set, it fails with an error matching os.ErrExist if filename
+// exists. The export is written to a temporary file that replaces filename
+// once complete, so that a failed export leaves filename untouched.
+func (p *Playlist) ExportToFile(filename string, e Exporter, overwrite bool) error {
+	if !overwrite {
+		if _, err := os.Lstat(filename); err == nil {
+			return &os.PathError{Op: "export", Path: filename, Err: os.ErrExist}
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = e.Export(f, p)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+	return nil
+}
+
 // MoveEntryUp moves an entry up in the playlist and returns the new index
 func (p *Playlist) MoveEntryUp(index int) int {
 	if index == 0 {
@@ -90,7 +170,7 @@ func (p *Playlist) MoveEntryUp(index int) int {
 	}
 
 	p.Tracks[index], p.Tracks[index-1] = p.Tracks[index-1], p.Tracks[index]
-	p.sendUpdate()
+	p.publish(&PlaylistEvent{Type: TrackMoved, Index: index, To: index - 1})
 	return index - 1
 }
 
@@ -100,7 +180,7 @@ func (p *Playlist) MoveEntryDown(index int) int {
 	}
 
 	p.Tracks[index], p.Tracks[index+1] = p.Tracks[index+1], p.Tracks[index]
-	p.sendUpdate()
+	p.publish(&PlaylistEvent{Type: TrackMoved, Index: index, To: index + 1})
 	return index + 1
 }
 
@@ -113,23 +193,53 @@ func (p *Playlist) ToJSON() []byte {
 	return b
 }
 
+// Clone returns a copy of the playlist and its tracks, without publisher.
+func (p *Playlist) Clone() *Playlist {
+	ret := &Playlist{
+		Title:       p.Title,
+		Description: p.Description,
+		Tracks:      make([]*Track, len(p.Tracks)),
+	}
+	for i, track := range p.Tracks {
+		track_ := *track
+		ret.Tracks[i] = &track_
+	}
+	return ret
+}
+
 func (p *Playlist) DeleteEntry(index int) {
+	track := p.Tracks[index]
 	p.Tracks = append(p.Tracks[:index], p.Tracks[index+1:]...)
-	p.sendUpdate()
+	p.publish(&PlaylistEvent{Type: TrackDeleted, Index: index, Track: track})
 }
 
 func (p *Playlist) InsertTrack(track *Track, index int) {
 	p.Tracks = append(p.Tracks[:index], append([]*Track{track}, p.Tracks[index:]...)...)
-	p.sendUpdate()
+	p.publish(&PlaylistEvent{Type: TrackInserted, Index: index, Track: track})
 }
 
-func (p *Playlist) sendUpdate() {
-	if p.publisher != nil {
-		err := p.publisher.Publish("playlist", message.NewMessage(watermill.NewUUID(), p.ToJSON()))
-		if err != nil {
-			log.Error().Err(err).Msg("failed to publish delete message")
-			return
-		}
+func (p *Playlist) SetMetadata(title string, description string) {
+	p.Title = title
+	p.Description = description
+	p.publish(&PlaylistEvent{Type: MetadataChanged, Title: title, Description: description})
+}
+
+// SetPublisher sets the publisher that the playlist sends a PlaylistEvent
+// to, on PlaylistTopic, whenever it is modified.
+func (p *Playlist) SetPublisher(publisher message.Publisher) {
+	p.publisher = publisher
+}
+
+func (p *Playlist) publish(event *PlaylistEvent) {
+	if p.publisher == nil {
+		return
+	}
+	msg, err := event.ToMessage()
+	if err == nil {
+		err = p.publisher.Publish(PlaylistTopic, msg)
+	}
+	if err != nil {
+		log.Error().Err(err).Str("type", string(event.Type)).Msg("failed to publish playlist event")
 	}
 }
 
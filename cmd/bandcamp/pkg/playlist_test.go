@@ -0,0 +1,30 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlaylist_RenderEscapesTracks(t *testing.T) {
+	playlist := &Playlist{Tracks: []*Track{{
+		BackgroundColor: "black",
+		AlbumID:         1,
+		Name:            "<script>alert(1)</script>",
+		BandName:        "Salt & Pepper",
+		ItemURLPath:     `album/x" onmouseover="alert(1)`,
+	}}}
+	rendered, err := playlist.Render()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"<script>", "Salt & Pepper", `" onmouseover`} {
+		if strings.Contains(rendered, s) {
+			t.Errorf("unexpected %q in\n%s", s, rendered)
+		}
+	}
+	for _, s := range []string{"&lt;script&gt;alert(1)&lt;/script&gt;", "Salt &amp; Pepper", "EmbeddedPlayer/album=1/", "bgcol=black/"} {
+		if !strings.Contains(rendered, s) {
+			t.Errorf("expected %q in\n%s", s, rendered)
+		}
+	}
+}
@@ -14,7 +14,9 @@ type KeyMap struct {
 	ShowFullHelp  key.Binding
 	CloseFullHelp key.Binding
 
-	CancelFilePicker key.Binding
+	CancelFilePicker   key.Binding
+	SelectExportFormat key.Binding
+	ConfirmOverwrite   key.Binding
 
 	ForceQuit key.Binding
 	Quit      key.Binding
@@ -121,5 +123,13 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("esc", "q"),
 			key.WithHelp("esc/q", "cancel file picker"),
 		),
+		SelectExportFormat: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "export"),
+		),
+		ConfirmOverwrite: key.NewBinding(
+			key.WithKeys("y"),
+			key.WithHelp("y", "overwrite"),
+		),
 	}
 }
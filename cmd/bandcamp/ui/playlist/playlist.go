@@ -1,6 +1,7 @@
 package playlist
 
 import (
+	"context"
 	"fmt"
 	"github.com/charmbracelet/bubbles/filepicker"
 	"github.com/charmbracelet/bubbles/key"
@@ -12,7 +13,10 @@ import (
 	"github.com/go-go-golems/go-go-labs/cmd/bandcamp/ui/search"
 	"github.com/pkg/errors"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
+	"unicode"
 )
 
 // states
@@ -23,6 +27,7 @@ const (
 	stateList             state = iota
 	stateFilePickerSave   state = iota
 	stateFilePickerExport state = iota
+	stateConfirmOverwrite state = iota
 	stateFilePickerLoad   state = iota
 	stateSearch           state = iota
 )
@@ -39,19 +44,52 @@ func (s *Track) Title() string {
 }
 
 func (s *Track) Description() string {
+	if s.Duration > 0 {
+		return fmt.Sprintf("%s - %s", pkg.FormatDuration(s.Duration), s.ItemURLPath)
+	}
 	return s.ItemURLPath
 }
 
+type exportFormat string
+
+func (f exportFormat) FilterValue() string {
+	return string(f)
+}
+
+func (f exportFormat) Title() string {
+	return string(f)
+}
+
+func (f exportFormat) Description() string {
+	e, err := pkg.GetExporter(string(f))
+	if err != nil {
+		return ""
+	}
+	return "*" + e.Extension()
+}
+
+type pendingExport struct {
+	path     string
+	exporter pkg.Exporter
+}
+
 type Model struct {
 	Playlist *pkg.Playlist
 
 	l list.Model
 
+	client     *pkg.Client
 	filepicker filepicker.Model
 	KeyMap     KeyMap
 	search     search.Model
 	state      state
 
+	// exportFormats is the list of formats shown in stateFilePickerExport
+	exportFormats list.Model
+	// pendingExport is the export waiting for confirmation in
+	// stateConfirmOverwrite, as its file already exists
+	pendingExport *pendingExport
+
 	selectedFile string
 
 	err error
@@ -74,6 +112,8 @@ func (m *Model) updateListItems() tea.Cmd {
 	m.KeyMap.DeleteEntry.SetEnabled(hasItems)
 	m.KeyMap.OpenEntry.SetEnabled(hasItems)
 
+	m.l.Title = listTitle(m.Playlist)
+
 	if m.l.Index() >= len(items) {
 		m.l.Select(len(items) - 1)
 	}
@@ -88,6 +128,17 @@ var (
 			Margin(1, 1, 1, 1)
 )
 
+func listTitle(playlist *pkg.Playlist) string {
+	ret := fmt.Sprintf(
+		"%s%s",
+		"Edit Playlist: ",
+		playlistNameStyle.Render(playlist.Title))
+	if duration := playlist.Duration(); duration > 0 {
+		ret += fmt.Sprintf(" (%s)", pkg.FormatDuration(duration))
+	}
+	return ret
+}
+
 func NewModel(playlist *pkg.Playlist) Model {
 	l := list.New(nil, list.NewDefaultDelegate(), 0, 0)
 
@@ -95,10 +146,7 @@ func NewModel(playlist *pkg.Playlist) Model {
 
 	l.DisableQuitKeybindings()
 	l.Styles.Title = titleStyle
-	l.Title = fmt.Sprintf(
-		"%s%s",
-		"Edit Playlist: ",
-		playlistNameStyle.Render(playlist.Title))
+	l.Title = listTitle(playlist)
 	l.SetShowStatusBar(false)
 	l.SetFilteringEnabled(false)
 	l.AdditionalShortHelpKeys = func() []key.Binding {
@@ -130,13 +178,26 @@ func NewModel(playlist *pkg.Playlist) Model {
 	client := pkg.NewClient()
 	s := search.NewModel(client, []*pkg.Result{})
 
+	formats := []list.Item{}
+	for _, name := range pkg.ExporterNames() {
+		formats = append(formats, exportFormat(name))
+	}
+	exportFormats := list.New(formats, list.NewDefaultDelegate(), 0, 0)
+	exportFormats.Styles.Title = titleStyle
+	exportFormats.SetShowStatusBar(false)
+	exportFormats.SetFilteringEnabled(false)
+	exportFormats.DisableQuitKeybindings()
+
 	m := Model{
 		Playlist:   playlist,
 		l:          l,
+		client:     client,
 		KeyMap:     keymap,
 		search:     s,
 		filepicker: fp,
 		state:      stateList,
+
+		exportFormats: exportFormats,
 	}
 	m.updateListItems()
 	return m
@@ -165,6 +226,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		newHeight := msg.Height - v
 		m.l.SetSize(newWidth, newHeight)
 		m.search.SetSize(newWidth, newHeight)
+		m.exportFormats.SetSize(newWidth, newHeight)
 		m.filepicker.Height = newHeight
 
 	case ui.InsertPlaylistEntryMsg:
@@ -182,7 +244,11 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		cmds_ := m.updateSearch(msg)
 		cmds = append(cmds, cmds_...)
 	case stateFilePickerExport:
-		fallthrough
+		cmds_ := m.updateExport(msg)
+		cmds = append(cmds, cmds_...)
+	case stateConfirmOverwrite:
+		cmds_ := m.updateConfirmOverwrite(msg)
+		cmds = append(cmds, cmds_...)
 	case stateFilePickerLoad:
 		fallthrough
 	case stateFilePickerSave:
@@ -229,7 +295,7 @@ func (m *Model) updateList(msg tea.Msg) []tea.Cmd {
 
 		case key.Matches(msg, m.KeyMap.Export):
 			m.state = stateFilePickerExport
-			cmds = append(cmds, m.filepicker.Init())
+			m.exportFormats.Title = "Export to " + m.filepicker.CurrentDirectory
 
 		case key.Matches(msg, m.KeyMap.Save):
 			// TODO(manuel, 2023-08-13) Handle save as new and normal save, for now always save as new
@@ -279,8 +345,15 @@ func (m *Model) updateSearch(msg tea.Msg) []tea.Cmd {
 		}
 		m.state = stateList
 		m.updateListItems()
+		client := m.client
 		return []tea.Cmd{
 			func() tea.Msg {
+				// without metadata, the track is embedded as its album
+				ctx, cancel := context.WithTimeout(context.Background(), pkg.ItemTimeout)
+				defer cancel()
+				if item, err := client.GetItem(ctx, track.ItemURLPath); err == nil {
+					track.SetItemInfo(item)
+				}
 				return ui.InsertPlaylistEntryMsg{Track: track}
 			},
 		}
@@ -328,6 +401,91 @@ func (m *Model) updateFilePicker(msg tea.Msg) []tea.Cmd {
 	return cmds
 }
 
+// updateExport lets the user pick an export format, and exports the
+// playlist to the current directory of the file picker, naming the file
+// after the playlist. Existing files are only overwritten after
+// confirmation, see updateConfirmOverwrite.
+func (m *Model) updateExport(msg tea.Msg) []tea.Cmd {
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch {
+		case key.Matches(msg, m.KeyMap.CancelFilePicker):
+			m.state = stateList
+			return nil
+
+		case key.Matches(msg, m.KeyMap.SelectExportFormat):
+			m.state = stateList
+			format, ok := m.exportFormats.SelectedItem().(exportFormat)
+			if !ok {
+				return nil
+			}
+			exporter, err := pkg.GetExporter(string(format))
+			if err != nil {
+				return []tea.Cmd{m.l.NewStatusMessage(err.Error())}
+			}
+			path := filepath.Join(m.filepicker.CurrentDirectory, exportFileName(m.Playlist.Title)+exporter.Extension())
+			err = m.Playlist.ExportToFile(path, exporter, false)
+			if errors.Is(err, os.ErrExist) {
+				m.state = stateConfirmOverwrite
+				m.pendingExport = &pendingExport{path: path, exporter: exporter}
+				m.exportFormats.Title = fmt.Sprintf("%s already exists, press %s to overwrite it",
+					filepath.Base(path), m.KeyMap.ConfirmOverwrite.Help().Key)
+				return nil
+			}
+			return []tea.Cmd{m.exportStatusMessage(path, err)}
+		}
+	}
+
+	exportFormats, cmd := m.exportFormats.Update(msg)
+	m.exportFormats = exportFormats
+	return []tea.Cmd{cmd}
+}
+
+// updateConfirmOverwrite overwrites the file of the pending export if the
+// user confirms, any other key cancels the export.
+func (m *Model) updateConfirmOverwrite(msg tea.Msg) []tea.Cmd {
+	msg_, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return nil
+	}
+	export := m.pendingExport
+	m.pendingExport = nil
+	m.state = stateList
+	if !key.Matches(msg_, m.KeyMap.ConfirmOverwrite) {
+		return []tea.Cmd{m.l.NewStatusMessage("Export cancelled")}
+	}
+	err := m.Playlist.ExportToFile(export.path, export.exporter, true)
+	return []tea.Cmd{m.exportStatusMessage(export.path, err)}
+}
+
+func (m *Model) exportStatusMessage(path string, err error) tea.Cmd {
+	if err != nil {
+		return m.l.NewStatusMessage(fmt.Sprintf("Could not export: %s", err))
+	}
+	return m.l.NewStatusMessage("Exported to " + path)
+}
+
+// exportFileName turns the title of a playlist into a file name without
+// extension, such as summer-playlist.
+func exportFileName(title string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	if sb.Len() == 0 {
+		return "playlist"
+	}
+	return sb.String()
+}
+
 func (m Model) View() string {
 	res := ""
 
@@ -338,8 +496,9 @@ func (m Model) View() string {
 	case stateSearch:
 		res = m.search.View()
 
-	case stateFilePickerExport:
-		fallthrough
+	case stateFilePickerExport, stateConfirmOverwrite:
+		res = m.exportFormats.View()
+
 	case stateFilePickerLoad:
 		fallthrough
 	case stateFilePickerSave:
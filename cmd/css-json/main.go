@@ -4,134 +4,158 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/tdewolff/parse/v2"
-	"github.com/tdewolff/parse/v2/css"
+	"github.com/go-go-golems/go-go-labs/pkg/csstree"
+	"github.com/spf13/cobra"
+	"golang.org/x/net/html"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
-
-	"golang.org/x/net/html"
 )
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("Usage: program <html file path>")
-		return
-	}
+var rootCmd = &cobra.Command{
+	Use:   "css-json [file]",
+	Short: "Convert CSS to a JSON tree and back",
+	Long: "Convert stylesheets to a JSON tree of rules, at-rules, declarations and comments, " +
+		"and render such trees back to CSS, so that stylesheets can be transformed by scripts:\n\n" +
+		"  css-json parse style.css | jq '...' | css-json render > transformed.css\n\n" +
+		"The tree keeps the formatting of the stylesheet in the raws of its nodes, " +
+		"so that rendering an unchanged tree returns the original stylesheet. " +
+		"Nodes without raws are rendered with a default formatting.\n\n" +
+		"css-json [file] is the same as css-json parse [file].",
+	Args: cobra.ExactArgs(1),
+	RunE: runParse,
+}
 
-	filePath := os.Args[1]
-	fileContent, err := os.ReadFile(filePath)
-	if err != nil {
-		fmt.Println("Error reading file:", err)
-		return
-	}
+var parseCmd = &cobra.Command{
+	Use:   "parse [file]",
+	Short: "Print the JSON tree of a CSS file, or of the <style> blocks of an HTML file",
+	Long: "Print the JSON tree of a CSS file (or of stdin if no file is given, or if it is -).\n\n" +
+		"For HTML files, print a JSON array with the tree of each <style> block. " +
+		"The positions of their nodes are relative to the start of the block.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: runParse,
+}
 
-	doc, err := html.Parse(strings.NewReader(string(fileContent)))
-	if err != nil {
-		fmt.Println("Error parsing HTML:", err)
-		return
-	}
+var renderCmd = &cobra.Command{
+	Use:   "render [file]",
+	Short: "Render a JSON tree back to CSS",
+	Long: "Render the JSON tree printed by parse (read from stdin if no file is given, or if it is -) to CSS. " +
+		"Arrays of trees are rendered one after the other, separated by a newline.",
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		file := "-"
+		if len(args) > 0 {
+			file = args[0]
+		}
+		content, err := readFile(file)
+		if err != nil {
+			return err
+		}
 
-	var cssContents []string
+		var trees []*csstree.Node
+		if trimmed := bytes.TrimSpace(content); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = json.Unmarshal(content, &trees)
+		} else {
+			tree := &csstree.Node{}
+			err = json.Unmarshal(content, tree)
+			trees = append(trees, tree)
+		}
+		if err != nil {
+			return fmt.Errorf("could not decode JSON tree from %s: %w", file, err)
+		}
 
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "style" {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if c.Type == html.TextNode {
-					cssContents = append(cssContents, c.Data)
+		for i, tree := range trees {
+			if i > 0 {
+				if _, err := io.WriteString(os.Stdout, "\n"); err != nil {
+					return err
 				}
 			}
+			if _, err := io.WriteString(os.Stdout, csstree.Render(tree)); err != nil {
+				return err
+			}
 		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
-		}
-	}
-	f(doc)
+		return nil
+	},
+}
 
-	for _, css := range cssContents {
-		fmt.Println("Inline CSS:")
-		fmt.Println("-----------")
-		v := parseCSS(css)
-		v_, err := json.MarshalIndent(v, "", "  ")
-		if err != nil {
-			fmt.Println("Error marshalling:", err)
-			return
-		}
-		_ = v
-		fmt.Println(string(v_))
-		//fmt.Println(css)
-		fmt.Println("-----------")
+func init() {
+	// the root command parses its argument, like css-json did before it had
+	// subcommands
+	for _, cmd := range []*cobra.Command{rootCmd, parseCmd} {
+		cmd.Flags().Bool("html", false, "Parse the input as HTML, regardless of its extension")
 	}
+	rootCmd.AddCommand(parseCmd, renderCmd)
 }
 
-func parseCSS(cssStr string) map[string]map[string]string {
-	// Initialize the CSS parser
-	p := css.NewParser(parse.NewInput(bytes.NewBufferString(cssStr)), false)
-
-	rules := make(map[string]map[string]string)
+func main() {
+	cobra.CheckErr(rootCmd.Execute())
+}
 
-	selector := ""
-	mediaRule := ""
+func runParse(cmd *cobra.Command, args []string) error {
+	file := "-"
+	if len(args) > 0 {
+		file = args[0]
+	}
+	content, err := readFile(file)
+	if err != nil {
+		return err
+	}
 
-	for {
-		gt, _, data := p.Next()
-		if gt == css.ErrorGrammar {
-			break
+	isHTML, _ := cmd.Flags().GetBool("html")
+	ext := strings.ToLower(filepath.Ext(file))
+	var v interface{}
+	if isHTML || ext == ".html" || ext == ".htm" {
+		v, err = parseHTML(file, content)
+		if err != nil {
+			return err
 		}
-		values := p.Values()
-
-		prop := string(data)
-
-		valStr := ""
-		for _, val := range values {
-			valStr += string(val.Data)
+	} else {
+		tree := csstree.Parse(string(content))
+		if file != "-" {
+			tree.Source = file
 		}
+		v = tree
+	}
 
-		switch gt {
-		case css.BeginRulesetGrammar:
-			if mediaRule != "" {
-				selector = mediaRule + " " + selector
-			} else {
-				selector = valStr
-
-			}
-			rules[selector] = make(map[string]string)
-		case css.EndRulesetGrammar:
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
 
-		case css.BeginAtRuleGrammar:
-			mediaRule = prop + valStr
-		case css.EndAtRuleGrammar:
-			fmt.Println("EndAtRuleGrammar:", valStr)
-			mediaRule = ""
+func readFile(file string) ([]byte, error) {
+	if file == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(file)
+}
 
-		case css.AtRuleGrammar,
-			css.QualifiedRuleGrammar:
-			fmt.Println("QualifiedRuleGrammar:", valStr)
+// parseHTML returns the trees of the <style> blocks of an HTML document,
+// named like css-use does: the file followed by #style-<n>.
+func parseHTML(file string, content []byte) ([]*csstree.Node, error) {
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse HTML %s: %w", file, err)
+	}
 
-		case css.DeclarationGrammar,
-			css.CustomPropertyGrammar:
-			if selector == "" {
-				selector = mediaRule
-				if selector == "@font-face" {
-					continue
+	ret := []*csstree.Node{}
+	var f func(*html.Node)
+	f = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "style" {
+			css := ""
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == html.TextNode {
+					css += c.Data
 				}
-				rules[selector] = make(map[string]string)
 			}
-			if selector == "@font-face" {
-				continue
-			}
-			rules[selector][prop] = valStr
-
-		case css.CommentGrammar:
-			continue
-
-		case css.ErrorGrammar, css.TokenGrammar:
-			fmt.Println("ErrorGrammar/TokenGrammar:", valStr)
-
-		default:
-			fmt.Println("Unknown grammar type:", gt)
+			tree := csstree.Parse(css)
+			tree.Source = fmt.Sprintf("%s#style-%d", file, len(ret)+1)
+			ret = append(ret, tree)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			f(c)
 		}
 	}
-
-	return rules
+	f(doc)
+	return ret, nil
 }
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/tdewolff/parse/v2/css"
+	"strings"
+)
+
+// AtRuleDefinition is a @keyframes or @font-face rule.
+type AtRuleDefinition struct {
+	// Type is keyframes or font-face.
+	Type string
+	// Name is the animation name or the font family.
+	Name       string
+	File       string
+	Stylesheet Stylesheet
+	AtRules    []string
+}
+
+// AtRuleIndex collects the @keyframes and @font-face rules of stylesheets,
+// along with the animations and font families their declarations reference.
+type AtRuleIndex struct {
+	Definitions []AtRuleDefinition
+
+	animations map[string]bool
+	// font families are case-insensitive, and stored lowercased
+	fonts map[string]bool
+
+	animationVariables map[string]bool
+	fontVariables      map[string]bool
+	customProperties   map[string][]string
+}
+
+func NewAtRuleIndex() *AtRuleIndex {
+	return &AtRuleIndex{
+		animations:         map[string]bool{},
+		fonts:              map[string]bool{},
+		animationVariables: map[string]bool{},
+		fontVariables:      map[string]bool{},
+		customProperties:   map[string][]string{},
+	}
+}
+
+func (i *AtRuleIndex) Add(file string, stylesheet Stylesheet, parsed *ParsedCSS) {
+	for _, keyframes := range parsed.Keyframes {
+		i.Definitions = append(i.Definitions, AtRuleDefinition{
+			Type:       "keyframes",
+			Name:       keyframes.Name,
+			File:       file,
+			Stylesheet: stylesheet,
+			AtRules:    keyframes.AtRules,
+		})
+	}
+	for _, fontFace := range parsed.FontFaces {
+		i.Definitions = append(i.Definitions, AtRuleDefinition{
+			Type:       "font-face",
+			Name:       fontFace.Family,
+			File:       file,
+			Stylesheet: stylesheet,
+			AtRules:    fontFace.AtRules,
+		})
+	}
+	for _, name := range parsed.AnimationNames {
+		i.animations[name] = true
+	}
+	for _, family := range parsed.FontFamilies {
+		i.fonts[strings.ToLower(family)] = true
+	}
+	for _, name := range parsed.AnimationVariables {
+		i.animationVariables[name] = true
+	}
+	for _, name := range parsed.FontVariables {
+		i.fontVariables[name] = true
+	}
+	for name, values := range parsed.CustomProperties {
+		i.customProperties[name] = append(i.customProperties[name], values...)
+	}
+}
+
+// IsReferenced returns true if a declaration of any of the added stylesheets
+// uses the animation or font family defined by d, directly or through the
+// custom properties of a var(), as in font-family: var(--font-sans).
+func (i *AtRuleIndex) IsReferenced(d AtRuleDefinition) bool {
+	if d.Type == "keyframes" {
+		if i.animations[d.Name] {
+			return true
+		}
+		for _, values := range i.variableValues(i.animationVariables) {
+			names := append(animationNames(values, true), animationNames(values, false)...)
+			for _, name := range names {
+				if name == d.Name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	if i.fonts[strings.ToLower(d.Name)] {
+		return true
+	}
+	for _, values := range i.variableValues(i.fontVariables) {
+		families := append(fontFamilies(values), fontShorthandFamilies(values)...)
+		for _, family := range families {
+			if strings.EqualFold(family, d.Name) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// variableValues returns the values of the custom properties of names, and
+// of the custom properties these values use in turn.
+func (i *AtRuleIndex) variableValues(names map[string]bool) [][]css.Token {
+	var ret [][]css.Token
+	seen := map[string]bool{}
+	var visit func(name string)
+	visit = func(name string) {
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		for _, value := range i.customProperties[name] {
+			tokens := tokenize(value)
+			ret = append(ret, tokens)
+			for _, used := range varNames(tokens) {
+				visit(used)
+			}
+		}
+	}
+	for name := range names {
+		visit(name)
+	}
+	return ret
+}
+
+func (i *AtRuleIndex) outputRows(ctx context.Context, gp middlewares.Processor, onlyUnreferenced bool) error {
+	for _, d := range i.Definitions {
+		referenced := i.IsReferenced(d)
+		if onlyUnreferenced && referenced {
+			continue
+		}
+		row := types.NewRow(
+			types.MRP("type", d.Type),
+			types.MRP("name", d.Name),
+			types.MRP("file", d.File),
+			types.MRP("stylesheet", d.Stylesheet.URL),
+			types.MRP("page", d.Stylesheet.Page),
+			types.MRP("at_rules", d.AtRules),
+			types.MRP("referenced", referenced),
+		)
+		if err := gp.AddRow(ctx, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type UnreferencedCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewUnreferencedCommand() (*UnreferencedCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, fmt.Errorf("could not create Glazed parameter layer: %w", err)
+	}
+
+	return &UnreferencedCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"unreferenced",
+			cmds.WithShort("Find the @keyframes and @font-face rules that no declaration uses."),
+			cmds.WithLong("List the animations defined with @keyframes and the font families defined with @font-face "+
+				"that are not referenced by any animation, animation-name, font or font-family declaration "+
+				"of the stylesheets of the given CSS files or HTML pages. Declarations using var() reference "+
+				"the animations and fonts of the custom properties they use, such as --font-sans: \"Inter\", sans-serif."),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"all",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("List all the @keyframes and @font-face rules, not only the unreferenced ones."),
+					parameters.WithDefault(false),
+				),
+				parameters.NewParameterDefinition(
+					"document-root",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Directory that root-relative stylesheet URLs of local HTML files are resolved against (defaults to the directory of the file)."),
+				),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"files",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("List of CSS or HTML files (or URLs)."),
+					parameters.WithDefault([]string{}),
+				),
+			),
+			cmds.WithLayers(glazedParameterLayer),
+		),
+	}, nil
+}
+
+func (c *UnreferencedCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	all, _ := ps["all"].(bool)
+	documentRoot, _ := ps["document-root"].(string)
+	urls := ps["files"].([]string)
+
+	loader := NewStylesheetLoader(documentRoot)
+	index := NewAtRuleIndex()
+	// a stylesheet shared by several pages is only indexed once
+	seen := map[string]bool{}
+
+	for _, url := range urls {
+		reader, err := ReaderUrlOrFile(url)
+		if err != nil {
+			return err
+		}
+		stylesheets, err := loader.Load(url, reader)
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, stylesheet := range stylesheets {
+			if seen[stylesheet.URL] {
+				continue
+			}
+			seen[stylesheet.URL] = true
+			index.Add(url, stylesheet, parseCSS(stylesheet.Content))
+		}
+	}
+
+	return index.outputRows(ctx, gp, !all)
+}
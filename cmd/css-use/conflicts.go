@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/go-go-labs/cmd/css-use/selector"
+	"sort"
+)
+
+type ConflictsCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewConflictsCommand() (*ConflictsCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, fmt.Errorf("could not create Glazed parameter layer: %w", err)
+	}
+
+	return &ConflictsCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"conflicts",
+			cmds.WithShort("Report duplicate selectors and conflicting declarations."),
+			cmds.WithLong("Report the problems of the stylesheets of CSS files or HTML pages, one row per problem:\n\n"+
+				"- duplicate_selector: a selector repeated within or across stylesheets\n"+
+				"- conflicting_value: a property set to different values by two rules with the same selector "+
+				"(a property repeated within a rule, such as a vendor prefixed fallback, is not a conflict)\n"+
+				"- overridden: a declaration overridden by a later rule of equal specificity "+
+				"targeting the same elements (the same last compound selector, such as .btn in .nav .btn)\n"+
+				"- important: a !important declaration\n\n"+
+				"Stylesheets are in cascade order, following the order of the files on the command line. "+
+				"The other_* columns point to the earlier of the two rules."),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"types",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Problems to report (duplicate_selector, conflicting_value, overridden, important)"),
+					parameters.WithDefault([]string{"duplicate_selector", "conflicting_value", "overridden", "important"}),
+				),
+				parameters.NewParameterDefinition(
+					"document-root",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Directory that root-relative stylesheet URLs of local HTML files are resolved against (defaults to the directory of the file)."),
+				),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"files",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("List of CSS or HTML files (or URLs)."),
+					parameters.WithDefault([]string{}),
+				),
+			),
+			cmds.WithLayers(glazedParameterLayer),
+		),
+	}, nil
+}
+
+func (c *ConflictsCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	types_, ok := ps["types"].([]string)
+	if !ok {
+		types_ = []string{"duplicate_selector", "conflicting_value", "overridden", "important"}
+	}
+	documentRoot, _ := ps["document-root"].(string)
+	urls := ps["files"].([]string)
+
+	loader := NewStylesheetLoader(documentRoot)
+	checker := &conflictChecker{}
+	seen := map[string]bool{}
+
+	for _, url := range urls {
+		reader, err := ReaderUrlOrFile(url)
+		if err != nil {
+			return err
+		}
+		stylesheets, err := loader.Load(url, reader)
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, stylesheet := range stylesheets {
+			if seen[stylesheet.URL] {
+				continue
+			}
+			seen[stylesheet.URL] = true
+			checker.Add(url, stylesheet, parseCSS(stylesheet.Content))
+		}
+	}
+
+	for _, problem := range checker.Conflicts(types_) {
+		if err := gp.AddRow(ctx, problem.row()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// cssLocation is a ruleset or declaration of a stylesheet. Locations are
+// ordered by stylesheet, then by offset, which is the cascade order.
+type cssLocation struct {
+	stylesheet *conflictStylesheet
+	ruleset    *Ruleset
+	offset     int
+}
+
+func (l cssLocation) before(o cssLocation) bool {
+	if l.stylesheet.index != o.stylesheet.index {
+		return l.stylesheet.index < o.stylesheet.index
+	}
+	return l.offset < o.offset
+}
+
+type conflictStylesheet struct {
+	index      int
+	file       string
+	stylesheet Stylesheet
+	parsed     *ParsedCSS
+}
+
+// conflict is a problem found at location. other is the earlier ruleset or
+// declaration involved, if any.
+type conflict struct {
+	type_       string
+	property    string
+	value       string
+	otherValue  string
+	location    cssLocation
+	other       *cssLocation
+	hasProperty bool
+}
+
+func (c conflict) row() types.Row {
+	line, column := c.location.stylesheet.parsed.Position(c.location.offset)
+	row := types.NewRow(
+		types.MRP("type", c.type_),
+		types.MRP("file", c.location.stylesheet.file),
+		types.MRP("stylesheet", c.location.stylesheet.stylesheet.URL),
+		types.MRP("line", line),
+		types.MRP("column", column),
+		types.MRP("at_rule", c.location.ruleset.AtRule),
+		types.MRP("selector", c.location.ruleset.Selector),
+	)
+	if c.hasProperty {
+		row.Set("property", c.property)
+		row.Set("value", c.value)
+	}
+	if c.other != nil {
+		otherLine, _ := c.other.stylesheet.parsed.Position(c.other.offset)
+		row.Set("other_file", c.other.stylesheet.file)
+		row.Set("other_stylesheet", c.other.stylesheet.stylesheet.URL)
+		row.Set("other_line", otherLine)
+		row.Set("other_selector", c.other.ruleset.Selector)
+		if c.hasProperty {
+			row.Set("other_value", c.otherValue)
+		}
+	}
+	return row
+}
+
+type conflictChecker struct {
+	stylesheets []*conflictStylesheet
+}
+
+func (c *conflictChecker) Add(file string, stylesheet Stylesheet, parsed *ParsedCSS) {
+	c.stylesheets = append(c.stylesheets, &conflictStylesheet{
+		index:      len(c.stylesheets),
+		file:       file,
+		stylesheet: stylesheet,
+		parsed:     parsed,
+	})
+}
+
+type cssDeclaration struct {
+	cssLocation
+	declaration *Declaration
+}
+
+// Conflicts returns the conflicts of the given types, grouped by type.
+func (c *conflictChecker) Conflicts(types_ []string) []conflict {
+	// rulesets with the same at-rules and selector, across stylesheets
+	bySelector := map[string][]cssLocation{}
+	var keys []string
+	// complex selectors with the same at-rules and subject
+	type complexRuleset struct {
+		complex_    string
+		specificity selector.Specificity
+		ruleset     *Ruleset
+		stylesheet  *conflictStylesheet
+	}
+	bySubject := map[string][]complexRuleset{}
+	var subjects []string
+
+	for _, s := range c.stylesheets {
+		for p := s.parsed.Rulesets.Oldest(); p != nil; p = p.Next() {
+			ruleset := p.Value
+			if ruleset.Selector == "" {
+				continue
+			}
+			l, err := selector.Parse(ruleset.Selector)
+			selectorKey := ruleset.Selector
+			if err == nil {
+				selectorKey = l.String()
+			}
+			key := ruleset.AtRule + "\x00" + selectorKey
+			if _, ok := bySelector[key]; !ok {
+				keys = append(keys, key)
+			}
+			for _, n := range ruleset.Nodes {
+				bySelector[key] = append(bySelector[key], cssLocation{s, ruleset, n.Start.Offset})
+			}
+
+			for _, complex_ := range l {
+				subject := complex_.Parts[len(complex_.Parts)-1].Compound.String()
+				key := ruleset.AtRule + "\x00" + subject
+				if _, ok := bySubject[key]; !ok {
+					subjects = append(subjects, key)
+				}
+				bySubject[key] = append(bySubject[key], complexRuleset{
+					complex_:    complex_.String(),
+					specificity: complex_.Specificity(),
+					ruleset:     ruleset,
+					stylesheet:  s,
+				})
+			}
+		}
+	}
+
+	var ret []conflict
+	for _, type_ := range types_ {
+		switch type_ {
+		case "duplicate_selector":
+			for _, key := range keys {
+				locations := bySelector[key]
+				for i := 1; i < len(locations); i++ {
+					ret = append(ret, conflict{
+						type_:    type_,
+						location: locations[i],
+						other:    &locations[0],
+					})
+				}
+			}
+
+		case "conflicting_value":
+			for _, key := range keys {
+				var declarations []cssDeclaration
+				for _, ruleset := range uniqueRulesets(bySelector[key]) {
+					declarations = append(declarations, ruleset.declarations()...)
+				}
+				sort.SliceStable(declarations, func(i, j int) bool {
+					return declarations[i].before(declarations[j].cssLocation)
+				})
+				// only the value a rule ends up with is compared to the
+				// earlier rules, repeating a property within a rule
+				// (display: -webkit-box; display: flex) is a fallback
+				type ruleProperty struct {
+					stylesheet int
+					rule       int
+					property   string
+				}
+				final := map[ruleProperty]*Declaration{}
+				for _, d := range declarations {
+					final[ruleProperty{d.stylesheet.index, d.rule(), d.declaration.Property}] = d.declaration
+				}
+				last := map[string]cssDeclaration{}
+				for _, d := range declarations {
+					if final[ruleProperty{d.stylesheet.index, d.rule(), d.declaration.Property}] != d.declaration {
+						continue
+					}
+					if previous, ok := last[d.declaration.Property]; ok && previous.declaration.Value != d.declaration.Value {
+						ret = append(ret, newDeclarationConflict(type_, d, previous))
+					}
+					last[d.declaration.Property] = d
+				}
+			}
+
+		case "overridden":
+			for _, subject := range subjects {
+				complexes := bySubject[subject]
+				// a pair of declarations is reported once, even if the
+				// rulesets share several complex selectors
+				reported := map[[2]*Declaration]bool{}
+				for i, a := range complexes {
+					for _, b := range complexes[i+1:] {
+						if a.complex_ == b.complex_ || a.specificity.Compare(b.specificity) != 0 {
+							continue
+						}
+						as := cssLocation{stylesheet: a.stylesheet, ruleset: a.ruleset}
+						bs := cssLocation{stylesheet: b.stylesheet, ruleset: b.ruleset}
+						for _, da := range as.declarations() {
+							for _, db := range bs.declarations() {
+								if da.declaration.Property != db.declaration.Property ||
+									da.declaration.Value == db.declaration.Value {
+									continue
+								}
+								earlier, later := da, db
+								if db.before(da.cssLocation) {
+									earlier, later = db, da
+								}
+								if earlier.declaration.Important && !later.declaration.Important {
+									continue
+								}
+								pair := [2]*Declaration{earlier.declaration, later.declaration}
+								if reported[pair] {
+									continue
+								}
+								reported[pair] = true
+								ret = append(ret, newDeclarationConflict(type_, later, earlier))
+							}
+						}
+					}
+				}
+			}
+
+		case "important":
+			for _, key := range keys {
+				for _, ruleset := range uniqueRulesets(bySelector[key]) {
+					for _, d := range ruleset.declarations() {
+						if d.declaration.Important {
+							ret = append(ret, newDeclarationConflict(type_, d, cssDeclaration{}))
+						}
+					}
+				}
+			}
+		}
+	}
+
+	return ret
+}
+
+func newDeclarationConflict(type_ string, d cssDeclaration, other cssDeclaration) conflict {
+	ret := conflict{
+		type_:       type_,
+		property:    d.declaration.Property,
+		value:       d.declaration.Value,
+		location:    d.cssLocation,
+		hasProperty: true,
+	}
+	if other.declaration != nil {
+		ret.otherValue = other.declaration.Value
+		ret.other = &other.cssLocation
+	}
+	return ret
+}
+
+// uniqueRulesets returns the rulesets of locations once, as a ruleset
+// repeated in a stylesheet has one location per occurrence.
+func uniqueRulesets(locations []cssLocation) []cssLocation {
+	seen := map[*Ruleset]bool{}
+	var ret []cssLocation
+	for _, l := range locations {
+		if !seen[l.ruleset] {
+			seen[l.ruleset] = true
+			ret = append(ret, l)
+		}
+	}
+	return ret
+}
+
+func (l cssLocation) declarations() []cssDeclaration {
+	ret := make([]cssDeclaration, len(l.ruleset.Declarations))
+	for i, d := range l.ruleset.Declarations {
+		ret[i] = cssDeclaration{
+			cssLocation: cssLocation{l.stylesheet, l.ruleset, d.Offset},
+			declaration: d,
+		}
+	}
+	return ret
+}
+
+// rule returns the offset of the rule containing the declaration, as a
+// ruleset repeated in a stylesheet has several rules.
+func (d cssDeclaration) rule() int {
+	for _, n := range d.ruleset.Nodes {
+		if n.Start != nil && n.End != nil && n.Start.Offset <= d.offset && d.offset < n.End.Offset {
+			return n.Start.Offset
+		}
+	}
+	return -1
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestConflictsCommand(t *testing.T) {
+	cmd, err := NewConflictsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, map[string]interface{}{
+		"files": []string{"testdata/conflicts/base.css", "testdata/conflicts/theme.css"},
+	}, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := [][]interface{}{}
+	for _, row := range gp.rows {
+		values := []interface{}{}
+		for _, column := range []string{"type", "stylesheet", "line", "selector", "property", "value", "other_line", "other_value"} {
+			value, _ := row.Get(column)
+			values = append(values, value)
+		}
+		got = append(got, values)
+	}
+	base, theme := "testdata/conflicts/base.css", "testdata/conflicts/theme.css"
+	// the display fallback of .box in theme.css is not a conflict
+	expected := [][]interface{}{
+		{"duplicate_selector", theme, 1, ".btn", nil, nil, 1, nil},
+		{"duplicate_selector", theme, 6, ".btn", nil, nil, 1, nil},
+		{"conflicting_value", theme, 2, ".btn", "color", "green", 1, "red"},
+		{"conflicting_value", theme, 6, ".btn", "color", "yellow", 2, "green"},
+		{"overridden", theme, 5, ".menu .link", "color", "black", 2, "blue"},
+		{"important", base, 3, ".card", "margin", "0 !important", nil, nil},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, got)
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/rs/zerolog/log"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CrawledPage is an HTML page visited by a Crawler.
+type CrawledPage struct {
+	URL   string
+	Depth int
+	// Classes are the first occurrence of each class used in the page,
+	// sorted alphabetically.
+	Classes []ClassUsage
+	// Stylesheets are the stylesheets of the page, including those already
+	// returned for previously crawled pages.
+	Stylesheets []Stylesheet
+}
+
+// Crawler visits the pages of a site or of a directory tree.
+//
+// A URL or an HTML file is crawled by following the links of each page to
+// other pages of the same origin (the same host for URLs, the same directory
+// tree for files). A directory is crawled by visiting every HTML file in it.
+// In both cases, pages deeper than MaxDepth are skipped, and the crawl stops
+// after MaxPages pages.
+type Crawler struct {
+	MaxDepth int
+	MaxPages int
+	Loader   *StylesheetLoader
+}
+
+type crawlItem struct {
+	url   string
+	depth int
+}
+
+// Crawl visits the pages reachable from root breadth-first and calls
+// callback for each of them. Pages that can't be fetched are logged and
+// skipped, except for root itself.
+func (c *Crawler) Crawl(ctx context.Context, root string, callback func(page CrawledPage) error) error {
+	var queue []crawlItem
+	follow := true
+	scope := ""
+
+	if isURL(root) {
+		queue = append(queue, crawlItem{url: root})
+		rootURL, err := url.Parse(root)
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", root, err)
+		}
+		scope = rootURL.Scheme + "://" + rootURL.Host + "/"
+	} else {
+		fi, err := os.Stat(root)
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			follow = false
+			queue, err = findHTMLFiles(root, c.MaxDepth)
+			if err != nil {
+				return err
+			}
+			if len(queue) == 0 {
+				return fmt.Errorf("no HTML files found in %s", root)
+			}
+		} else {
+			queue = append(queue, crawlItem{url: filepath.Clean(root)})
+			scope = c.Loader.DocumentRoot
+			if scope == "" {
+				scope = filepath.Dir(root)
+			}
+			scope, err = filepath.Abs(scope)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	visited := map[string]bool{}
+	for _, item := range queue {
+		visited[item.url] = true
+	}
+
+	pageCount := 0
+	for len(queue) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if c.MaxPages > 0 && pageCount >= c.MaxPages {
+			log.Info().Int("max-pages", c.MaxPages).Int("remaining", len(queue)).Msg("Reached the maximum number of pages")
+			break
+		}
+
+		item := queue[0]
+		queue = queue[1:]
+
+		content, err := readAll(item.url)
+		if err != nil {
+			if follow && item.depth == 0 {
+				return err
+			}
+			log.Warn().Err(err).Str("page", item.url).Msg("Could not load page")
+			continue
+		}
+		pageCount++
+
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(content))
+		if err != nil {
+			return fmt.Errorf("could not parse %s: %w", item.url, err)
+		}
+		stylesheets, err := c.Loader.LoadHTML(item.url, bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+
+		err = callback(CrawledPage{
+			URL:         item.url,
+			Depth:       item.depth,
+			Classes:     getHTMLClassUsages(item.url, string(content)),
+			Stylesheets: stylesheets,
+		})
+		if err != nil {
+			return err
+		}
+
+		if !follow || item.depth >= c.MaxDepth {
+			continue
+		}
+		doc.Find("a[href]").Each(func(_ int, a *goquery.Selection) {
+			href, _ := a.Attr("href")
+			link, ok := c.resolveLink(item.url, href, scope)
+			if !ok || visited[link] {
+				return
+			}
+			visited[link] = true
+			queue = append(queue, crawlItem{url: link, depth: item.depth + 1})
+		})
+	}
+
+	return nil
+}
+
+// resolveLink resolves the href of a link on page, and returns false if the
+// link points outside of scope or to something that isn't an HTML page.
+func (c *Crawler) resolveLink(page string, href string, scope string) (string, bool) {
+	href = strings.TrimSpace(href)
+	if href == "" || strings.HasPrefix(href, "#") {
+		return "", false
+	}
+	hrefURL, err := url.Parse(href)
+	if err != nil {
+		return "", false
+	}
+
+	if isURL(page) {
+		if hrefURL.Scheme != "" && hrefURL.Scheme != "http" && hrefURL.Scheme != "https" {
+			return "", false
+		}
+		link := c.Loader.resolve(page, href)
+		linkURL, err := url.Parse(link)
+		if err != nil {
+			return "", false
+		}
+		linkURL.Fragment = ""
+		link = linkURL.String()
+		if !strings.HasPrefix(link, scope) {
+			return "", false
+		}
+		ext := strings.ToLower(filepath.Ext(linkURL.Path))
+		return link, ext == "" || ext == ".html" || ext == ".htm"
+	}
+
+	// links from local files can only point to other local files
+	if hrefURL.Scheme != "" || hrefURL.Host != "" {
+		return "", false
+	}
+	link := c.Loader.resolve(page, href)
+	if !isHTMLFile(link) {
+		return "", false
+	}
+	abs, err := filepath.Abs(link)
+	if err != nil {
+		return "", false
+	}
+	if abs != scope && !strings.HasPrefix(abs, scope+string(filepath.Separator)) {
+		return "", false
+	}
+	return link, true
+}
+
+// findHTMLFiles returns the HTML files in the directory tree under root, at
+// most maxDepth directories deep.
+func findHTMLFiles(root string, maxDepth int) ([]crawlItem, error) {
+	var ret []crawlItem
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		// the number of directories between root and path
+		depth := len(strings.Split(rel, string(filepath.Separator)))
+		if d.IsDir() {
+			if depth > maxDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if isHTMLFile(path) {
+			ret = append(ret, crawlItem{url: path, depth: depth - 1})
+		}
+		return nil
+	})
+	return ret, err
+}
+
+func isHTMLFile(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".html" || ext == ".htm"
+}
+
+func readAll(url string) ([]byte, error) {
+	reader, err := ReaderUrlOrFile(url)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = reader.Close()
+	}()
+	return io.ReadAll(reader)
+}
+
+type CrawlCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewCrawlCommand() (*CrawlCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, fmt.Errorf("could not create Glazed parameter layer: %w", err)
+	}
+
+	return &CrawlCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"crawl",
+			cmds.WithShort("Crawl a site or a directory and write the used and defined classes."),
+			cmds.WithLong("Crawl a site starting from a URL or an HTML file, following the links to pages "+
+				"of the same origin, or visit all the HTML files of a directory. "+
+				"The classes used in the pages are written to the --used file and the classes defined in "+
+				"their stylesheets to the --defined file, in the formats of the used and defined commands, "+
+				"ready for find-unused. Stylesheets shared by several pages are only listed once.\n\n"+
+				"One row is output per crawled page."),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"max-depth",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Maximum number of links followed from the root (or directory depth)."),
+					parameters.WithDefault(3),
+				),
+				parameters.NewParameterDefinition(
+					"max-pages",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Maximum number of pages to crawl (0 for no limit)."),
+					parameters.WithDefault(100),
+				),
+				parameters.NewParameterDefinition(
+					"document-root",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Directory that root-relative URLs of local HTML files are resolved against (defaults to the directory of the root file)."),
+				),
+				parameters.NewParameterDefinition(
+					"used",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("File to write the used classes to."),
+					parameters.WithDefault("used.json"),
+				),
+				parameters.NewParameterDefinition(
+					"defined",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("File to write the defined classes to."),
+					parameters.WithDefault("defined.json"),
+				),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"root",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("URL, HTML file or directory to start from."),
+					parameters.WithRequired(true),
+				),
+			),
+			cmds.WithLayers(glazedParameterLayer),
+		),
+	}, nil
+}
+
+func (c *CrawlCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	root := ps["root"].(string)
+	documentRoot, _ := ps["document-root"].(string)
+	maxDepth, _ := ps["max-depth"].(int)
+	maxPages, _ := ps["max-pages"].(int)
+	usedFile, _ := ps["used"].(string)
+	definedFile, _ := ps["defined"].(string)
+
+	if documentRoot == "" && !isURL(root) {
+		if fi, err := os.Stat(root); err == nil && fi.IsDir() {
+			documentRoot = root
+		}
+	}
+
+	crawler := &Crawler{
+		MaxDepth: maxDepth,
+		MaxPages: maxPages,
+		Loader:   NewStylesheetLoader(documentRoot),
+	}
+
+	used := []map[string]interface{}{}
+	defined := []map[string]interface{}{}
+	seenStylesheets := map[string]bool{}
+
+	err := crawler.Crawl(ctx, root, func(page CrawledPage) error {
+		for _, usage := range page.Classes {
+			used = append(used, map[string]interface{}{
+				"class":  usage.Class,
+				"file":   page.URL,
+				"line":   usage.Line,
+				"column": usage.Column,
+			})
+		}
+
+		newStylesheets := 0
+		for _, stylesheet := range page.Stylesheets {
+			if seenStylesheets[stylesheet.URL] {
+				continue
+			}
+			seenStylesheets[stylesheet.URL] = true
+			newStylesheets++
+
+			parsed := parseCSS(stylesheet.Content)
+			classOffsets := getClassOffsets(parsed)
+			// the stylesheet is the file defining the classes, so that
+			// find-unused --check-all-unused counts them per stylesheet
+			for _, class := range getDefinedClasses(getRules(parsed)) {
+				line, column := stylesheet.Position(parsed.Position(classOffsets[class]))
+				defined = append(defined, map[string]interface{}{
+					"class":      class,
+					"file":       stylesheet.URL,
+					"stylesheet": stylesheet.URL,
+					"page":       stylesheet.Page,
+					"line":       line,
+					"column":     column,
+				})
+			}
+		}
+
+		row := types.NewRow(
+			types.MRP("page", page.URL),
+			types.MRP("depth", page.Depth),
+			types.MRP("used_classes", len(page.Classes)),
+			types.MRP("stylesheets", len(page.Stylesheets)),
+			types.MRP("new_stylesheets", newStylesheets),
+		)
+		return gp.AddRow(ctx, row)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := writeJSON(usedFile, used); err != nil {
+		return err
+	}
+	return writeJSON(definedFile, defined)
+}
+
+func writeJSON(path string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
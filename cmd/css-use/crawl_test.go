@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func readJSONRows(t *testing.T, path string) []map[string]interface{} {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rows []map[string]interface{}
+	if err := json.Unmarshal(b, &rows); err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func TestCrawlCommand_FollowsLinksAndDeduplicatesStylesheets(t *testing.T) {
+	s := httptest.NewServer(http.FileServer(http.Dir("testdata/site")))
+	defer s.Close()
+
+	cmd, err := NewCrawlCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, map[string]interface{}{
+		"root":      s.URL + "/index.html",
+		"max-depth": 3,
+		"max-pages": 100,
+		"used":      filepath.Join(dir, "used.json"),
+		"defined":   filepath.Join(dir, "defined.json"),
+	}, gp)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	pages := []string{}
+	for _, row := range gp.rows {
+		page, _ := row.Get("page")
+		pages = append(pages, page.(string))
+	}
+	if expected := []string{s.URL + "/index.html", s.URL + "/blog/post.html"}; !reflect.DeepEqual(pages, expected) {
+		t.Fatalf("expected pages %v, got %v", expected, pages)
+	}
+
+	used := map[string][]string{}
+	for _, row := range readJSONRows(t, filepath.Join(dir, "used.json")) {
+		used[row["file"].(string)] = append(used[row["file"].(string)], row["class"].(string))
+	}
+	if expected := []string{"button", "post"}; !reflect.DeepEqual(used[s.URL+"/blog/post.html"], expected) {
+		t.Fatalf("expected post.html to use %v, got %v", expected, used[s.URL+"/blog/post.html"])
+	}
+
+	// site.css is linked from both pages but only listed once
+	defined := map[string]int{}
+	for _, row := range readJSONRows(t, filepath.Join(dir, "defined.json")) {
+		defined[row["class"].(string)]++
+		if row["file"] != row["stylesheet"] {
+			t.Errorf("expected the file to be the stylesheet, got %v", row)
+		}
+	}
+	expected := map[string]int{"container": 1, "row": 1, "button": 1, "page": 1, "primary": 1, "hero": 1}
+	if !reflect.DeepEqual(defined, expected) {
+		t.Fatalf("expected %v, got %v", expected, defined)
+	}
+}
+
+func TestCrawler_Directory(t *testing.T) {
+	for _, tc := range []struct {
+		maxDepth int
+		expected []string
+	}{
+		{0, []string{"testdata/site/index.html"}},
+		{1, []string{"testdata/site/blog/post.html", "testdata/site/index.html"}},
+	} {
+		crawler := &Crawler{
+			MaxDepth: tc.maxDepth,
+			Loader:   NewStylesheetLoader("testdata/site"),
+		}
+		pages := []string{}
+		err := crawler.Crawl(context.Background(), "testdata/site", func(page CrawledPage) error {
+			pages = append(pages, page.URL)
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pages, tc.expected) {
+			t.Errorf("max-depth %d: expected %v, got %v", tc.maxDepth, tc.expected, pages)
+		}
+	}
+}
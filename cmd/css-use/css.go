@@ -0,0 +1,452 @@
+package main
+
+import (
+	"github.com/go-go-golems/go-go-labs/pkg/csstree"
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/css"
+	orderedmap "github.com/wk8/go-ordered-map/v2"
+	"strings"
+)
+
+type Rules = *orderedmap.OrderedMap[string, string]
+
+// Ruleset is a selector and its declarations. Rulesets without a selector
+// hold the declarations of an at-rule, such as @page.
+type Ruleset struct {
+	// AtRules are the at-rules enclosing the ruleset, outermost first, such as
+	// `@media screen` and `@supports (display: grid)`.
+	AtRules []string
+	// AtRule is AtRules joined with spaces.
+	AtRule   string
+	Selector string
+	Rules    Rules
+	// Nodes are the rules of every occurrence of the ruleset in the
+	// stylesheet.
+	Nodes []*csstree.Node
+	// Declarations are all the declarations of the ruleset, in order,
+	// including those that Rules overwrites.
+	Declarations []*Declaration
+}
+
+type Declaration struct {
+	Property string
+	Value    string
+	// Important is set for !important declarations. The value still ends
+	// with !important, as in `0 !important`.
+	Important bool
+	Offset    int
+}
+
+// Selectors maps the selector of each ruleset, prefixed by its at-rules, to
+// the ruleset.
+type Selectors = *orderedmap.OrderedMap[string, *Ruleset]
+
+// Keyframes is a @keyframes rule.
+type Keyframes struct {
+	Name string
+	// Keyword is @keyframes, or a vendor prefixed version of it.
+	Keyword string
+	AtRules []string
+	// Steps are the keyframe selectors: from, 50%, to.
+	Steps []string
+}
+
+// FontFace is a @font-face rule.
+type FontFace struct {
+	Family  string
+	AtRules []string
+	Rules   Rules
+}
+
+// ParsedCSS is the content of a stylesheet.
+type ParsedCSS struct {
+	Content   string
+	Tree      *csstree.Node
+	Rulesets  Selectors
+	Keyframes []*Keyframes
+	FontFaces []*FontFace
+	// AnimationNames are the animations referenced by animation and
+	// animation-name declarations.
+	AnimationNames []string
+	// FontFamilies are the font families referenced by font and font-family
+	// declarations, except for generic families like sans-serif.
+	FontFamilies []string
+	// AnimationVariables and FontVariables are the custom properties that
+	// animation and font declarations use with var(), such as --font-sans.
+	AnimationVariables []string
+	FontVariables      []string
+	// CustomProperties are the values of the custom property declarations
+	// by name, such as "Inter", sans-serif for --font-sans.
+	CustomProperties map[string][]string
+
+	lines lineIndex
+}
+
+func parseCSS(cssStr string) *ParsedCSS {
+	ret := &ParsedCSS{
+		Content:          cssStr,
+		Tree:             csstree.Parse(cssStr),
+		Rulesets:         orderedmap.New[string, *Ruleset](),
+		CustomProperties: map[string][]string{},
+	}
+	ret.add(ret.Tree.Children, []string{})
+	return ret
+}
+
+// Position returns the 1-based line and column (in characters) of the byte
+// offset of the stylesheet.
+func (c *ParsedCSS) Position(offset int) (int, int) {
+	if c.lines == nil {
+		c.lines = newLineIndex(c.Content)
+	}
+	return c.lines.position(c.Content, offset)
+}
+
+// add adds the rules of nodes, which are nested in atRules, to c.
+func (c *ParsedCSS) add(nodes []*csstree.Node, atRules []string) {
+	atRule := strings.Join(atRules, " ")
+	for _, n := range nodes {
+		switch n.Type {
+		case csstree.TypeRule:
+			selector := normalize(n.Selector)
+			key := selector
+			if atRule != "" {
+				key = atRule + " " + selector
+			}
+			ruleset := c.ruleset(key, atRules)
+			ruleset.Selector = selector
+			ruleset.Nodes = append(ruleset.Nodes, n)
+			for _, d := range n.Children {
+				if d.Type == csstree.TypeDeclaration {
+					c.addDeclaration(ruleset, d)
+				}
+			}
+
+		case csstree.TypeDeclaration:
+			// declarations directly inside an at-rule, such as @page
+			c.addDeclaration(c.ruleset(atRule, atRules), n)
+
+		case csstree.TypeAtRule:
+			name := "@" + strings.ToLower(n.Name)
+			prelude := normalize(n.Prelude)
+			switch {
+			case unprefixed(name) == "@keyframes":
+				keyframes := &Keyframes{
+					Name:    unquote(prelude),
+					Keyword: name,
+					AtRules: atRules,
+				}
+				for _, step := range n.Children {
+					if step.Type != csstree.TypeRule {
+						continue
+					}
+					for _, s := range strings.Split(normalize(step.Selector), ",") {
+						keyframes.Steps = append(keyframes.Steps, strings.TrimSpace(s))
+					}
+				}
+				c.Keyframes = append(c.Keyframes, keyframes)
+
+			case name == "@font-face":
+				fontFace := &FontFace{
+					AtRules: atRules,
+					Rules:   orderedmap.New[string, string](),
+				}
+				for _, d := range n.Children {
+					if d.Type != csstree.TypeDeclaration {
+						continue
+					}
+					fontFace.Rules.Set(d.Property, declarationValue(d))
+					if strings.ToLower(d.Property) == "font-family" {
+						if families := fontFamilies(tokenize(d.Value)); len(families) > 0 {
+							fontFace.Family = families[0]
+						}
+					}
+				}
+				c.FontFaces = append(c.FontFaces, fontFace)
+
+			case groupAtRules[unprefixed(name)] || name == "@page":
+				nested := append(append([]string{}, atRules...), strings.TrimSpace(name+" "+prelude))
+				c.add(n.Children, nested)
+			}
+		}
+	}
+}
+
+// ruleset returns the ruleset of key, creating it if needed.
+func (c *ParsedCSS) ruleset(key string, atRules []string) *Ruleset {
+	ret, ok := c.Rulesets.Get(key)
+	if !ok {
+		ret = &Ruleset{
+			AtRules: atRules,
+			AtRule:  strings.Join(atRules, " "),
+			Rules:   orderedmap.New[string, string](),
+		}
+		c.Rulesets.Set(key, ret)
+	}
+	return ret
+}
+
+func (c *ParsedCSS) addDeclaration(ruleset *Ruleset, d *csstree.Node) {
+	value := declarationValue(d)
+	ruleset.Rules.Set(d.Property, value)
+	ruleset.Declarations = append(ruleset.Declarations, &Declaration{
+		Property:  d.Property,
+		Value:     value,
+		Important: d.Important,
+		Offset:    d.Start.Offset,
+	})
+	if strings.HasPrefix(d.Property, "--") {
+		c.CustomProperties[d.Property] = append(c.CustomProperties[d.Property], d.Value)
+	} else {
+		c.addReferences(d.Property, tokenize(d.Value))
+	}
+}
+
+// declarationValue returns the normalized value of a declaration, ending with
+// !important if it is set.
+func declarationValue(d *csstree.Node) string {
+	value := normalize(d.Value)
+	if d.Important {
+		value = strings.TrimSpace(value + " !important")
+	}
+	return value
+}
+
+// tokenize returns the tokens of s, without comments.
+func tokenize(s string) []css.Token {
+	var ret []css.Token
+	l := css.NewLexer(parse.NewInputString(s))
+	for {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			return ret
+		}
+		if tt != css.CommentToken {
+			ret = append(ret, css.Token{TokenType: tt, Data: []byte(string(data))})
+		}
+	}
+}
+
+// normalize strips the comments of a selector, prelude or value, and
+// collapses its whitespace.
+func normalize(s string) string {
+	sb := strings.Builder{}
+	space := false
+	l := css.NewLexer(parse.NewInputString(s))
+	for {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			return sb.String()
+		}
+		if tt == css.WhitespaceToken || tt == css.CommentToken {
+			space = sb.Len() > 0
+			continue
+		}
+		if space {
+			sb.WriteByte(' ')
+			space = false
+		}
+		sb.Write(data)
+	}
+}
+
+// unprefixed strips the vendor prefix of an at-rule or property name:
+// @-webkit-keyframes becomes @keyframes, -moz-animation becomes animation.
+func unprefixed(name string) string {
+	at := ""
+	if strings.HasPrefix(name, "@") {
+		at, name = "@", name[1:]
+	}
+	if strings.HasPrefix(name, "-") && !strings.HasPrefix(name, "--") {
+		if i := strings.IndexByte(name[1:], '-'); i >= 0 {
+			name = name[i+2:]
+		}
+	}
+	return at + name
+}
+
+func (c *ParsedCSS) addReferences(prop string, values []css.Token) {
+	switch unprefixed(strings.ToLower(prop)) {
+	case "animation-name":
+		c.AnimationNames = append(c.AnimationNames, animationNames(values, true)...)
+		c.AnimationVariables = append(c.AnimationVariables, varNames(values)...)
+	case "animation":
+		c.AnimationNames = append(c.AnimationNames, animationNames(values, false)...)
+		c.AnimationVariables = append(c.AnimationVariables, varNames(values)...)
+	case "font-family":
+		c.FontFamilies = append(c.FontFamilies, fontFamilies(values)...)
+		c.FontVariables = append(c.FontVariables, varNames(values)...)
+	case "font":
+		c.FontFamilies = append(c.FontFamilies, fontShorthandFamilies(values)...)
+		c.FontVariables = append(c.FontVariables, varNames(values)...)
+	}
+}
+
+// varNames returns the custom properties that values use with var().
+func varNames(values []css.Token) []string {
+	var ret []string
+	for i, v := range values {
+		if v.TokenType != css.FunctionToken || strings.ToLower(string(v.Data)) != "var(" {
+			continue
+		}
+		rest := trimWhitespace(values[i+1:])
+		if len(rest) > 0 && strings.HasPrefix(string(rest[0].Data), "--") {
+			ret = append(ret, string(rest[0].Data))
+		}
+	}
+	return ret
+}
+
+var cssWideKeywords = map[string]bool{
+	"inherit": true, "initial": true, "unset": true, "revert": true, "revert-layer": true,
+}
+
+// animationKeywords are the keywords of the animation shorthand that are not
+// animation names.
+var animationKeywords = map[string]bool{
+	"none": true,
+	// timing functions
+	"linear": true, "ease": true, "ease-in": true, "ease-out": true, "ease-in-out": true,
+	"step-start": true, "step-end": true,
+	// iteration count, direction, fill mode and play state
+	"infinite": true, "normal": true, "reverse": true, "alternate": true, "alternate-reverse": true,
+	"forwards": true, "backwards": true, "both": true, "running": true, "paused": true,
+}
+
+var genericFontFamilies = map[string]bool{
+	"serif": true, "sans-serif": true, "monospace": true, "cursive": true, "fantasy": true,
+	"system-ui": true, "ui-serif": true, "ui-sans-serif": true, "ui-monospace": true, "ui-rounded": true,
+	"emoji": true, "math": true, "fangsong": true,
+}
+
+var fontSizeKeywords = map[string]bool{
+	"xx-small": true, "x-small": true, "small": true, "medium": true, "large": true,
+	"x-large": true, "xx-large": true, "xxx-large": true, "smaller": true, "larger": true,
+}
+
+// splitLayers splits values on top-level commas, and drops whitespace and the
+// content of functions, keeping their function token.
+func splitLayers(values []css.Token) [][]css.Token {
+	ret := [][]css.Token{{}}
+	depth := 0
+	for _, v := range values {
+		switch v.TokenType {
+		case css.FunctionToken, css.LeftParenthesisToken:
+			if depth == 0 {
+				ret[len(ret)-1] = append(ret[len(ret)-1], v)
+			}
+			depth++
+			continue
+		case css.RightParenthesisToken:
+			depth--
+			continue
+		}
+		if depth > 0 || v.TokenType == css.WhitespaceToken {
+			continue
+		}
+		if v.TokenType == css.CommaToken {
+			ret = append(ret, []css.Token{})
+			continue
+		}
+		ret[len(ret)-1] = append(ret[len(ret)-1], v)
+	}
+	return ret
+}
+
+func animationNames(values []css.Token, isNameProperty bool) []string {
+	var ret []string
+	for _, layer := range splitLayers(values) {
+		for _, v := range layer {
+			name := ""
+			switch v.TokenType {
+			case css.StringToken:
+				name = unquote(string(v.Data))
+			case css.IdentToken:
+				name = string(v.Data)
+				lower := strings.ToLower(name)
+				if cssWideKeywords[lower] || lower == "none" || (!isNameProperty && animationKeywords[lower]) {
+					name = ""
+				}
+			}
+			if name != "" {
+				ret = append(ret, name)
+				break
+			}
+			if isNameProperty {
+				break
+			}
+		}
+	}
+	return ret
+}
+
+func fontFamilies(values []css.Token) []string {
+	var ret []string
+	for _, layer := range splitLayers(values) {
+		var parts []string
+		for _, v := range layer {
+			switch v.TokenType {
+			case css.StringToken:
+				parts = append(parts, unquote(string(v.Data)))
+			case css.IdentToken:
+				parts = append(parts, string(v.Data))
+			default:
+				// var() and the like
+				parts = nil
+			}
+			if parts == nil {
+				break
+			}
+		}
+		family := strings.Join(parts, " ")
+		lower := strings.ToLower(family)
+		if family == "" || genericFontFamilies[lower] || cssWideKeywords[lower] {
+			continue
+		}
+		ret = append(ret, family)
+	}
+	return ret
+}
+
+// fontShorthandFamilies returns the families of a font shorthand, which come
+// after the font size and the optional line height: italic bold 12px/1.5 Inter, serif.
+func fontShorthandFamilies(values []css.Token) []string {
+	depth := 0
+	for i, v := range values {
+		switch v.TokenType {
+		case css.FunctionToken, css.LeftParenthesisToken:
+			depth++
+			continue
+		case css.RightParenthesisToken:
+			depth--
+			continue
+		}
+		if depth > 0 {
+			continue
+		}
+		isSize := v.TokenType == css.DimensionToken || v.TokenType == css.PercentageToken ||
+			(v.TokenType == css.IdentToken && fontSizeKeywords[strings.ToLower(string(v.Data))])
+		if !isSize {
+			continue
+		}
+
+		rest := values[i+1:]
+		rest = trimWhitespace(rest)
+		if len(rest) > 0 && rest[0].TokenType == css.DelimToken && string(rest[0].Data) == "/" {
+			// skip the line height
+			rest = trimWhitespace(rest[1:])
+			if len(rest) > 0 {
+				rest = rest[1:]
+			}
+		}
+		return fontFamilies(rest)
+	}
+	return nil
+}
+
+func trimWhitespace(values []css.Token) []css.Token {
+	for len(values) > 0 && values[0].TokenType == css.WhitespaceToken {
+		values = values[1:]
+	}
+	return values
+}
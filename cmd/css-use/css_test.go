@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestParseCSS_NestedAtRules(t *testing.T) {
+	content, err := os.ReadFile("testdata/atrules.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed := parseCSS(string(content))
+
+	got := map[string][]string{}
+	for p := parsed.Rulesets.Oldest(); p != nil; p = p.Next() {
+		got[p.Value.Selector] = p.Value.AtRules
+	}
+	expected := map[string][]string{
+		"body":       {},
+		".grid":      {"@media screen", "@supports (display: grid)"},
+		".card":      {"@layer components"},
+		".card-wide": {"@layer components", "@container (min-width: 400px)"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	keyframes := []string{}
+	for _, k := range parsed.Keyframes {
+		keyframes = append(keyframes, k.Keyword+" "+k.Name)
+	}
+	if expected := []string{"@keyframes fade", "@-webkit-keyframes spin"}; !reflect.DeepEqual(keyframes, expected) {
+		t.Fatalf("expected keyframes %v, got %v", expected, keyframes)
+	}
+	if expected := []string{"fade"}; !reflect.DeepEqual(parsed.AnimationNames, expected) {
+		t.Fatalf("expected animations %v, got %v", expected, parsed.AnimationNames)
+	}
+	if expected := []string{"inter"}; !reflect.DeepEqual(parsed.FontFamilies, expected) {
+		t.Fatalf("expected font families %v, got %v", expected, parsed.FontFamilies)
+	}
+}
+
+func TestUnreferencedCommand(t *testing.T) {
+	cmd, err := NewUnreferencedCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, map[string]interface{}{
+		"files": []string{"testdata/atrules.css"},
+	}, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := [][]interface{}{}
+	for _, row := range gp.rows {
+		type_, _ := row.Get("type")
+		name, _ := row.Get("name")
+		got = append(got, []interface{}{type_, name})
+	}
+	expected := [][]interface{}{
+		{"keyframes", "spin"},
+		{"font-face", "Unused Sans"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestUnreferencedCommand_Variables(t *testing.T) {
+	cmd, err := NewUnreferencedCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, map[string]interface{}{
+		"files": []string{"testdata/variables.css"},
+	}, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := [][]interface{}{}
+	for _, row := range gp.rows {
+		type_, _ := row.Get("type")
+		name, _ := row.Get("name")
+		got = append(got, []interface{}{type_, name})
+	}
+	expected := [][]interface{}{
+		{"keyframes", "spin"},
+		{"font-face", "Unused Sans"},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
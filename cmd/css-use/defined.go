@@ -9,7 +9,9 @@ import (
 	"github.com/go-go-golems/glazed/pkg/middlewares"
 	"github.com/go-go-golems/glazed/pkg/settings"
 	"github.com/go-go-golems/glazed/pkg/types"
-	"golang.org/x/net/html"
+	"github.com/go-go-golems/go-go-labs/cmd/css-use/selector"
+	"github.com/go-go-golems/go-go-labs/pkg/csstree"
+	"github.com/rs/zerolog/log"
 	"io"
 	"sort"
 	"strings"
@@ -19,10 +21,6 @@ type DefinedCommand struct {
 	*cmds.CommandDescription
 }
 
-// This would be good to transform into something that keeps track of the output
-// processor, the flags, probably as a helper class, to avoid having to pass arguments all
-// over.
-
 func NewDefinedCommand() (*DefinedCommand, error) {
 	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
 	if err != nil {
@@ -33,6 +31,7 @@ func NewDefinedCommand() (*DefinedCommand, error) {
 		CommandDescription: cmds.NewCommandDescription(
 			"defined",
 			cmds.WithShort("Parse provided HTML files and extract CSS classes."),
+			cmds.WithLong("Extract the CSS classes defined in CSS files, or in the <style> blocks, linked stylesheets and @imports of HTML pages."),
 			cmds.WithFlags(
 				parameters.NewParameterDefinition(
 					"with_selector",
@@ -46,7 +45,19 @@ func NewDefinedCommand() (*DefinedCommand, error) {
 					parameters.WithHelp("Include CSS rules in output."),
 					parameters.WithDefault(false),
 				),
+				parameters.NewParameterDefinition(
+					"with_at_rules",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Also output @keyframes and @font-face rows, with whether any declaration references them, and a type column."),
+					parameters.WithDefault(false),
+				),
+				parameters.NewParameterDefinition(
+					"document-root",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Directory that root-relative stylesheet URLs of local HTML files are resolved against (defaults to the directory of the file)."),
+				),
 			),
+			cmds.WithFlags(renderFlags()...),
 			cmds.WithArguments(
 				parameters.NewParameterDefinition(
 					"files",
@@ -68,197 +79,297 @@ func (c *DefinedCommand) Run(
 ) error {
 	withSelector := ps["with_selector"].(bool)
 	withRules := ps["with_rules"].(bool)
+	withAtRules, _ := ps["with_at_rules"].(bool)
+	documentRoot, _ := ps["document-root"].(string)
 
 	urls := ps["files"].([]string)
 
+	loader := NewStylesheetLoader(documentRoot)
+	out := &definedOutput{
+		gp:            gp,
+		withSelectors: withSelector,
+		withRules:     withRules,
+	}
+	if withAtRules {
+		out.atRules = NewAtRuleIndex()
+	}
+
+	renderer, err := newRendererFromParameters(ctx, ps, loader)
+	if err != nil {
+		return err
+	}
+	if renderer != nil {
+		defer renderer.Close()
+	}
+
 	for _, url := range urls {
+		if renderer != nil && !strings.HasSuffix(url, ".css") {
+			page, err := renderer.Render(url)
+			if err != nil {
+				return err
+			}
+			err = out.outputStylesheets(ctx, url, page.Stylesheets)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
 		reader, err := ReaderUrlOrFile(url)
 		if err != nil {
 			return err
 		}
-		defer func() {
-			_ = reader.Close()
-		}()
-
-		err = ParseAndOutputFile(
-			ctx,
-			url,
-			reader,
-			gp,
-			withSelector,
-			withRules,
-		)
+
+		err = ParseAndOutputFile(ctx, loader, url, reader, out)
+		_ = reader.Close()
 		if err != nil {
 			return err
 		}
 
 	}
 
+	if out.atRules != nil {
+		// references can be in any of the stylesheets, so at-rules are
+		// output once everything is parsed
+		return out.atRules.outputRows(ctx, gp, false)
+	}
+
 	return nil
 }
 
+// definedOutput outputs the classes defined in stylesheets, and collects
+// their @keyframes and @font-face rules.
+type definedOutput struct {
+	gp            middlewares.Processor
+	withSelectors bool
+	withRules     bool
+	// atRules is nil unless @keyframes and @font-face rows are output
+	atRules *AtRuleIndex
+}
+
+// ParseAndOutputFile outputs the classes defined in a CSS file, or in all
+// the stylesheets that apply to an HTML page.
 func ParseAndOutputFile(
 	ctx context.Context,
+	loader *StylesheetLoader,
 	url string,
 	reader io.Reader,
-	gp middlewares.Processor,
-	withSelector bool,
-	withRules bool,
+	out *definedOutput,
 ) error {
-	// if url ends with css, go straight to parsing CSS
-	if strings.HasSuffix(url, ".css") {
-		cssContent, err := io.ReadAll(reader)
-		if err != nil {
-			return fmt.Errorf("error reading CSS from %s: %w", url, err)
-		}
-		rules, err := GetRules(string(cssContent))
-		if err != nil {
-			return err
-		}
-
-		err = outputRules(ctx, rules, url, gp, withSelector, withRules)
-		if err != nil {
-			return err
-		}
-
-		return nil
-	}
-
-	rules, err := GetRulesFromHTML(reader)
+	stylesheets, err := loader.Load(url, reader)
 	if err != nil {
 		return err
 	}
 
-	err = outputRules(ctx, rules, url, gp, withSelector, withRules)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return out.outputStylesheets(ctx, url, stylesheets)
 }
 
-func GetRulesFromHTML(
-	reader io.Reader,
-) ([]CSSRule, error) {
-	ret := []CSSRule{}
-
-	doc, err := html.Parse(reader)
-	if err != nil {
-		return nil, err
-	}
-
-	var cssContents []string
-	var f func(*html.Node)
-	f = func(n *html.Node) {
-		if n.Type == html.ElementNode && n.Data == "style" {
-			for c := n.FirstChild; c != nil; c = c.NextSibling {
-				if c.Type == html.TextNode {
-					cssContents = append(cssContents, c.Data)
-				}
-			}
-		}
-		for c := n.FirstChild; c != nil; c = c.NextSibling {
-			f(c)
+func (o *definedOutput) outputStylesheets(ctx context.Context, url string, stylesheets []Stylesheet) error {
+	for _, stylesheet := range stylesheets {
+		parsed := parseCSS(stylesheet.Content)
+		if o.atRules != nil {
+			o.atRules.Add(url, stylesheet, parsed)
 		}
-	}
-	f(doc)
 
-	for _, cssContent := range cssContents {
-		rules, err := GetRules(cssContent)
+		err := o.outputRules(ctx, parsed, url, stylesheet)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		ret = append(ret, rules...)
 	}
 
-	return ret, nil
+	return nil
 }
 
-func outputRules(
+func (o *definedOutput) outputRules(
 	ctx context.Context,
-	rules []CSSRule,
+	parsed *ParsedCSS,
 	path string,
-	gp middlewares.Processor,
-	withSelectors bool,
-	withRules bool,
+	stylesheet Stylesheet,
 ) error {
-	classes := map[string]interface{}{}
-
-	for _, rule := range rules {
-		if withSelectors {
+	rules := getRules(parsed)
+	if o.withSelectors {
+		for _, rule := range rules {
+			line, column := stylesheet.Position(rule.Line, rule.Column)
 			row := types.NewRow(
 				types.MRP("file", path),
 				types.MRP("selector", rule.Selector),
+				types.MRP("stylesheet", stylesheet.URL),
+				types.MRP("page", stylesheet.Page),
+				types.MRP("line", line),
+				types.MRP("column", column),
 			)
-			if withRules {
+			if o.withRules {
 				row.Set("rules", rule.Rules)
 			}
-			if err := gp.AddRow(ctx, row); err != nil {
+			if err := o.gp.AddRow(ctx, row); err != nil {
 				return err
 			}
-			continue
 		}
+		return nil
+	}
 
-		selectorParts := strings.FieldsFunc(rule.Selector, func(r rune) bool {
-			return r == '>' || r == ':' || r == ' '
-		})
-
-		for _, selectorPart := range selectorParts {
-			startsWithDot := strings.HasPrefix(selectorPart, ".")
-			dotParts := strings.Split(selectorPart, ".")
-			for idx, dotPart := range dotParts {
-				if idx == 0 && !startsWithDot {
-					continue
-				}
-				if dotPart != "" {
-					classes[dotPart] = true
-				}
-			}
+	classAtRules := getClassAtRules(rules)
+	classOffsets := getClassOffsets(parsed)
+	for _, class := range getDefinedClasses(rules) {
+		line, column := stylesheet.Position(parsed.Position(classOffsets[class]))
+		row := types.NewRow(
+			types.MRP("class", class),
+			types.MRP("file", path),
+			types.MRP("stylesheet", stylesheet.URL),
+			types.MRP("page", stylesheet.Page),
+			types.MRP("line", line),
+			types.MRP("column", column),
+			types.MRP("at_rules", classAtRules[class]),
+		)
+		if o.atRules != nil {
+			row.Set("type", "class")
+		}
+		if err := o.gp.AddRow(ctx, row); err != nil {
+			return err
 		}
 	}
 
-	if !withSelectors {
-		classes_ := []string{}
-		for class := range classes {
-			classes_ = append(classes_, class)
+	return nil
+}
+
+// getDefinedClasses returns the classes used in the selectors of rules,
+// sorted alphabetically.
+func getDefinedClasses(rules []CSSRule) []string {
+	classes := map[string]interface{}{}
+
+	for _, rule := range rules {
+		for _, class := range rule.Selectors.Classes() {
+			classes[class] = true
 		}
+	}
+
+	classes_ := []string{}
+	for class := range classes {
+		classes_ = append(classes_, class)
+	}
+	sort.Strings(classes_)
 
-		sort.Strings(classes_)
+	return classes_
+}
 
-		for _, class := range classes_ {
-			row := types.NewRow(
-				types.MRP("class", class),
-				types.MRP("file", path),
-			)
-			if err := gp.AddRow(ctx, row); err != nil {
-				return err
+// getClassAtRules returns the at-rules (such as `@media print`) each class is
+// defined in. Classes defined at the top level of the stylesheet have no
+// entry for it.
+func getClassAtRules(rules []CSSRule) map[string][]string {
+	ret := map[string][]string{}
+	seen := map[string]bool{}
+	for _, rule := range rules {
+		if rule.AtRule == "" {
+			continue
+		}
+		for _, class := range rule.Selectors.Classes() {
+			if !seen[class+"\x00"+rule.AtRule] {
+				seen[class+"\x00"+rule.AtRule] = true
+				ret[class] = append(ret[class], rule.AtRule)
 			}
 		}
 	}
-
-	return nil
+	return ret
 }
 
 type CSSRule struct {
+	// Selector is the selector prefixed by the enclosing at-rule, if any.
 	Selector string
-	Rules    string
+	AtRule   string
+	// Line and Column are the position of the first occurrence of the
+	// selector in the stylesheet, or of the first declaration of at-rules
+	// without a selector.
+	Line   int
+	Column int
+	// Selectors is the parsed selector, nil for at-rules without a selector
+	// and for selectors that couldn't be parsed.
+	Selectors selector.SelectorList
+	Rules     string
 }
 
 func GetRules(cssContent string) ([]CSSRule, error) {
-	selectors := parseCSS(cssContent)
+	return getRules(parseCSS(cssContent)), nil
+}
 
+func getRules(parsed *ParsedCSS) []CSSRule {
 	var cssRules []CSSRule
-	for p := selectors.Oldest(); p != nil; p = p.Next() {
-		selector, rules := p.Key, p.Value
+	for p := parsed.Rulesets.Oldest(); p != nil; p = p.Next() {
+		key, ruleset := p.Key, p.Value
 		ruleBody := ""
-		for r := rules.Oldest(); r != nil; r = r.Next() {
+		for r := ruleset.Rules.Oldest(); r != nil; r = r.Next() {
 			ruleBody += fmt.Sprintf("%s: %s; ", r.Key, r.Value)
 		}
-		cssRules = append(cssRules, CSSRule{
-			Selector: selector,
+		rule := CSSRule{
+			Selector: key,
+			AtRule:   ruleset.AtRule,
 			Rules:    ruleBody,
-		})
+		}
+		switch {
+		case len(ruleset.Nodes) > 0:
+			rule.Line, rule.Column = ruleset.Nodes[0].Start.Line, ruleset.Nodes[0].Start.Column
+		case len(ruleset.Declarations) > 0:
+			rule.Line, rule.Column = parsed.Position(ruleset.Declarations[0].Offset)
+		}
+		if ruleset.Selector != "" {
+			l, err := selector.Parse(ruleset.Selector)
+			if err != nil {
+				log.Warn().Err(err).Msg("Could not parse selector")
+			}
+			rule.Selectors = l
+		}
+		cssRules = append(cssRules, rule)
+	}
+
+	return cssRules
+}
+
+// getClassOffsets returns the offset of the first occurrence of each class in
+// the selectors of the stylesheet.
+func getClassOffsets(parsed *ParsedCSS) map[string]int {
+	ret := map[string]int{}
+	for p := parsed.Rulesets.Oldest(); p != nil; p = p.Next() {
+		ruleset := p.Value
+		if ruleset.Selector == "" {
+			continue
+		}
+		l, err := selector.Parse(ruleset.Selector)
+		if err != nil {
+			continue
+		}
+		for _, class := range l.Classes() {
+			for _, n := range ruleset.Nodes {
+				offset := findClass(n, class)
+				if previous, ok := ret[class]; !ok || offset < previous {
+					ret[class] = offset
+				}
+			}
+		}
 	}
+	return ret
+}
+
+// findClass returns the offset of class in the selector of the rule n, or the
+// offset of the rule if it can't be found, for example because it is escaped
+// in an unusual way.
+func findClass(n *csstree.Node, class string) int {
+	offset, prelude := n.Start.Offset, n.Selector
+	for _, s := range []string{(&selector.Class{Name: class}).String(), "." + class} {
+		for i := 0; i < len(prelude); {
+			j := strings.Index(prelude[i:], s)
+			if j < 0 {
+				break
+			}
+			after := i + j + len(s)
+			if after == len(prelude) || !isClassChar(prelude[after]) {
+				return offset + i + j
+			}
+			i += j + 1
+		}
+	}
+	return offset
+}
 
-	return cssRules, nil
+func isClassChar(c byte) bool {
+	return c == '-' || c == '_' || c == '\\' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
 }
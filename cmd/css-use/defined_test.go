@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// rowCollector is a middlewares.Processor that keeps every row it is given.
+type rowCollector struct {
+	rows []types.Row
+}
+
+func (c *rowCollector) AddRow(ctx context.Context, row types.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Close(ctx context.Context) error {
+	return nil
+}
+
+func runDefined(t *testing.T, ps map[string]interface{}) []types.Row {
+	cmd, err := NewDefinedCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ps["with_selector"]; !ok {
+		ps["with_selector"] = false
+	}
+	if _, ok := ps["with_rules"]; !ok {
+		ps["with_rules"] = false
+	}
+	gp := &rowCollector{}
+	if err := cmd.Run(context.Background(), nil, ps, gp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return gp.rows
+}
+
+// classStylesheets maps every class to the stylesheet it was defined in.
+func classStylesheets(rows []types.Row) map[string]string {
+	ret := map[string]string{}
+	for _, row := range rows {
+		class, _ := row.Get("class")
+		stylesheet, _ := row.Get("stylesheet")
+		ret[class.(string)] = stylesheet.(string)
+	}
+	return ret
+}
+
+func TestDefinedCommand_FollowsLinksAndImports(t *testing.T) {
+	rows := runDefined(t, map[string]interface{}{
+		"files":         []string{"testdata/site/index.html"},
+		"document-root": "testdata/site",
+	})
+
+	expected := map[string]string{
+		"container": "testdata/site/css/base.css",
+		"row":       "testdata/site/css/base.css",
+		"button":    "testdata/site/css/site.css",
+		"page":      "testdata/site/css/site.css",
+		"primary":   "testdata/site/css/theme.css",
+		"hero":      "testdata/site/index.html#style-1",
+	}
+	if got := classStylesheets(rows); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	for _, row := range rows {
+		if page, _ := row.Get("page"); page != "testdata/site/index.html" {
+			t.Fatalf("expected every class to come from index.html, got %v", page)
+		}
+	}
+}
+
+func TestDefinedCommand_FollowsLinksOverHTTP(t *testing.T) {
+	s := httptest.NewServer(http.FileServer(http.Dir("testdata/site")))
+	defer s.Close()
+
+	rows := runDefined(t, map[string]interface{}{
+		"files": []string{s.URL + "/blog/post.html"},
+	})
+
+	classes := []string{}
+	for class, stylesheet := range classStylesheets(rows) {
+		classes = append(classes, class)
+		if stylesheet != s.URL+"/css/site.css" && stylesheet != s.URL+"/css/base.css" {
+			t.Errorf("unexpected stylesheet %s for class %s", stylesheet, class)
+		}
+	}
+	sort.Strings(classes)
+	if expected := []string{"button", "container", "page", "row"}; !reflect.DeepEqual(classes, expected) {
+		t.Fatalf("expected %v, got %v", expected, classes)
+	}
+}
+
+func TestDefinedCommand_Positions(t *testing.T) {
+	rows := runDefined(t, map[string]interface{}{
+		"files":         []string{"testdata/site/index.html"},
+		"document-root": "testdata/site",
+	})
+
+	got := map[string][2]interface{}{}
+	for _, row := range rows {
+		class, _ := row.Get("class")
+		line, _ := row.Get("line")
+		column, _ := row.Get("column")
+		got[class.(string)] = [2]interface{}{line, column}
+	}
+	expected := map[string][2]interface{}{
+		"container": {4, 1},
+		"row":       {4, 14},
+		"button":    {4, 1},
+		"page":      {3, 1},
+		"primary":   {1, 1},
+		// inline <style> blocks are positioned in the page
+		"hero": {8, 9},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestStylesheetLoader_ImportedTwice(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"main.css":   `@import "a.css"; @import "b.css"; .main {}`,
+		"a.css":      `@import "shared.css"; .a {}`,
+		"b.css":      `@import url(shared.css); .b {}`,
+		"shared.css": `.shared {}`,
+		"index.html": `<link rel="stylesheet" href="main.css"><link rel="stylesheet" href="shared.css">`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	urls := func(stylesheets []Stylesheet) []string {
+		ret := []string{}
+		for _, s := range stylesheets {
+			ret = append(ret, filepath.Base(s.URL))
+		}
+		return ret
+	}
+
+	stylesheets, err := NewStylesheetLoader("").LoadCSS(filepath.Join(dir, "main.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"shared.css", "a.css", "b.css", "main.css"}
+	if got := urls(stylesheets); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+	f, err := os.Open(filepath.Join(dir, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	stylesheets, err = NewStylesheetLoader("").LoadHTML(filepath.Join(dir, "index.html"), f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := urls(stylesheets); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
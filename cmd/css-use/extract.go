@@ -0,0 +1,405 @@
+package main
+
+import (
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ClassUsage is a class name found in a template or component source file.
+type ClassUsage struct {
+	Class  string `json:"class"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// Uncertain is set for class names that are built at runtime, such as
+	// btn-{{ .Size }} or 'btn-' + size. Class is then a glob, btn-* in both
+	// examples.
+	Uncertain bool `json:"uncertain"`
+	// Dynamic is set for values that can be any class, such as
+	// {{ .Classes }} or className={className}. Class is then *, which is
+	// not a glob: these values are usually passed through from elsewhere,
+	// and matching every class would hide all the unused ones.
+	Dynamic bool `json:"dynamic"`
+	// Source is the construct the class was found in: class, className,
+	// :class or classList.
+	Source string `json:"source"`
+}
+
+var (
+	// class="..." in HTML, Go templates and PHP, className="..." and
+	// className={...} in JSX. Matches end at the opening quote or brace.
+	classAttrRe = regexp.MustCompile(`(?:^|[^\w\-:.@$])(class|className)\s*=\s*["'{]`)
+	// :class="..." and v-bind:class="..." in Vue templates.
+	vueClassRe = regexp.MustCompile(`(?:^|\s)(?:v-bind)?:class\s*=\s*["']`)
+	// el.classList.add(...) and friends.
+	classListRe = regexp.MustCompile(`\bclassList\s*\.\s*(?:add|remove|toggle|replace|contains)\s*\(`)
+
+	templateActionRe        = regexp.MustCompile(`(?s)\{\{.*?\}\}|\{%.*?%\}|<\?.*?\?>|\$\{[^}]*\}`)
+	leadingTemplateActionRe = regexp.MustCompile(`^(?:` + templateActionRe.String() + `)`)
+)
+
+// dynamic marks the bytes of a class attribute or string literal that are
+// computed at runtime.
+const dynamic = '\x00'
+
+// ExtractClassUsages returns the classes used in content, in order.
+//
+// The extraction is lexical: class attributes are split on whitespace, and
+// the string literals of className={...}, :class bindings and classList
+// calls are taken as class names, along with the keys of object literals
+// ({active: isActive}). Template actions and string concatenation are
+// replaced by a wildcard and the resulting class flagged as uncertain.
+// Values that are entirely dynamic, such as class="{{ .Classes }}" or
+// :class="classes", give a * flagged as dynamic.
+func ExtractClassUsages(file string, content string) []ClassUsage {
+	e := &classExtractor{
+		file:    file,
+		content: content,
+		lines:   newLineIndex(content),
+	}
+
+	for _, m := range classAttrRe.FindAllStringSubmatchIndex(content, -1) {
+		source := content[m[2]:m[3]]
+		open := m[1] - 1
+		if content[open] == '{' {
+			end := matchingBracket(content, open)
+			if end < 0 {
+				continue
+			}
+			e.expression(open+1, end, source, true)
+			continue
+		}
+		end := attributeEnd(content, open)
+		if end < 0 {
+			continue
+		}
+		e.attribute(open+1, end, source)
+	}
+
+	for _, m := range vueClassRe.FindAllStringIndex(content, -1) {
+		open := m[1] - 1
+		end := strings.IndexByte(content[open+1:], content[open])
+		if end < 0 {
+			continue
+		}
+		e.expression(open+1, open+1+end, ":class", true)
+	}
+
+	for _, m := range classListRe.FindAllStringIndex(content, -1) {
+		open := m[1] - 1
+		end := matchingBracket(content, open)
+		if end < 0 {
+			continue
+		}
+		e.expression(open+1, end, "classList", false)
+	}
+
+	sort.SliceStable(e.usages, func(i, j int) bool {
+		if e.usages[i].Line != e.usages[j].Line {
+			return e.usages[i].Line < e.usages[j].Line
+		}
+		return e.usages[i].Column < e.usages[j].Column
+	})
+	return e.usages
+}
+
+type classExtractor struct {
+	file    string
+	content string
+	lines   lineIndex
+	usages  []ClassUsage
+}
+
+// attribute extracts the classes of the attribute value content[start:end],
+// which can contain template actions.
+func (e *classExtractor) attribute(start int, end int, source string) {
+	value := []byte(e.content[start:end])
+	for _, m := range templateActionRe.FindAllIndex(value, -1) {
+		fill := byte(dynamic)
+		if isControlAction(string(value[m[0]:m[1]])) {
+			fill = ' '
+		}
+		for i := m[0]; i < m[1]; i++ {
+			value[i] = fill
+		}
+	}
+	e.addTokens(start, value, false, false, source)
+}
+
+// attributeEnd returns the index of the quote closing the attribute value
+// opened by the quote at s[open], skipping over template actions, which can
+// contain quotes too, or -1.
+func attributeEnd(s string, open int) int {
+	for i := open + 1; i < len(s); i++ {
+		if s[i] == s[open] {
+			return i
+		}
+		if s[i] == '{' || s[i] == '<' || s[i] == '$' {
+			if loc := leadingTemplateActionRe.FindStringIndex(s[i:]); loc != nil {
+				i += loc[1] - 1
+			}
+		}
+	}
+	return -1
+}
+
+// controlActionRe matches the template actions that don't output anything,
+// such as {{ if .Active }}, {{ end }}, {% if %} or <?php endif; ?>.
+var controlActionRe = regexp.MustCompile(
+	`^(?:\{\{-?\s*(?:if|else|end|range|with|block|define|break|continue|/\*|[#/])` +
+		`|\{%` +
+		`|<\?php\s+(?:if|else|elseif|endif|foreach|endforeach|for|endfor|while|endwhile)\b)`,
+)
+
+func isControlAction(action string) bool {
+	return controlActionRe.MatchString(action)
+}
+
+type jsToken struct {
+	kind  byte // '"' for string literals, 'a' for identifiers, else the punctuation
+	start int
+	end   int
+	value []byte
+}
+
+// expression extracts the classes of the JavaScript expression
+// content[start:end]. If withKeys is set, the keys of object literals are
+// classes too, as in the classnames library and Vue class bindings.
+func (e *classExtractor) expression(start int, end int, source string, withKeys bool) {
+	s := e.content
+	usageCount := len(e.usages)
+	var tokens []jsToken
+	for i := start; i < end; {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '\'' || c == '"' || c == '`':
+			j, value := readStringLiteral(s, i, end)
+			tokens = append(tokens, jsToken{kind: '"', start: i + 1, end: j - 1, value: value})
+			i = j
+		case isIdentStart(c):
+			j := i + 1
+			for j < end && (isIdentStart(s[j]) || (s[j] >= '0' && s[j] <= '9')) {
+				j++
+			}
+			tokens = append(tokens, jsToken{kind: 'a', start: i, end: j})
+			i = j
+		default:
+			tokens = append(tokens, jsToken{kind: c, start: i, end: i + 1})
+			i++
+		}
+	}
+
+	kind := func(i int) byte {
+		if i < 0 || i >= len(tokens) {
+			return 0
+		}
+		return tokens[i].kind
+	}
+
+	for i, t := range tokens {
+		isKey := withKeys && kind(i+1) == ':' && (kind(i-1) == '{' || kind(i-1) == ',')
+		switch t.kind {
+		case '"':
+			e.addTokens(t.start, t.value, kind(i-1) == '+', kind(i+1) == '+', source)
+		case 'a':
+			if isKey {
+				e.addTokens(t.start, []byte(s[t.start:t.end]), false, false, source)
+			}
+		}
+	}
+
+	// an expression without any class literal, such as a variable, can
+	// evaluate to any class
+	if len(e.usages) == usageCount {
+		for _, t := range tokens {
+			if t.kind == 'a' {
+				e.addDynamic(start, source)
+				break
+			}
+		}
+	}
+}
+
+// readStringLiteral reads the string literal starting with the quote at
+// s[start], and returns the index after its closing quote along with its
+// content. The ${...} substitutions of template literals are replaced by
+// dynamic bytes.
+func readStringLiteral(s string, start int, end int) (int, []byte) {
+	quote := s[start]
+	var value []byte
+	i := start + 1
+	for i < end {
+		c := s[i]
+		switch {
+		case c == quote:
+			return i + 1, value
+		case c == '\\' && i+1 < end:
+			value = append(value, s[i], s[i+1])
+			i += 2
+		case quote == '`' && c == '$' && i+1 < end && s[i+1] == '{':
+			j := matchingBracket(s, i+1)
+			if j < 0 || j >= end {
+				j = end - 1
+			}
+			for k := i; k <= j; k++ {
+				value = append(value, dynamic)
+			}
+			i = j + 1
+		default:
+			value = append(value, c)
+			i++
+		}
+	}
+	return end, value
+}
+
+// matchingBracket returns the index of the bracket closing the one at
+// s[open], skipping string literals, or -1.
+func matchingBracket(s string, open int) int {
+	closing := map[byte]byte{'{': '}', '(': ')', '[': ']'}[s[open]]
+	depth := 0
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; c {
+		case s[open]:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return i
+			}
+		case '\'', '"', '`':
+			j, _ := readStringLiteral(s, i, len(s))
+			i = j - 1
+		}
+	}
+	return -1
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '$' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// addTokens adds the whitespace separated classes of value, which starts at
+// offset start in the file. Dynamic parts become wildcards, as do the start
+// and end of a string concatenated to something else. Concatenating to
+// whitespace or to an empty string ('btn ' + extra) adds a dynamic *.
+func (e *classExtractor) addTokens(start int, value []byte, concatBefore bool, concatAfter bool, source string) {
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+
+	if concatBefore && (len(value) == 0 || isSpace(value[0])) {
+		e.addDynamic(start, source)
+	}
+	if concatAfter && len(value) > 0 && isSpace(value[len(value)-1]) ||
+		concatAfter && len(value) == 0 && !concatBefore {
+		e.addDynamic(start+len(value), source)
+	}
+
+	for i := 0; i < len(value); {
+		if isSpace(value[i]) {
+			i++
+			continue
+		}
+		j := i
+		for j < len(value) && !isSpace(value[j]) {
+			j++
+		}
+		token := value[i:j]
+		uncertain := false
+		if concatBefore && i == 0 {
+			token = append([]byte{dynamic}, token...)
+		}
+		if concatAfter && j == len(value) {
+			token = append(token[:len(token):len(token)], dynamic)
+		}
+
+		class := ""
+		if strings.IndexByte(string(token), dynamic) >= 0 {
+			uncertain = true
+			class = toGlob(token)
+		} else {
+			class = string(token)
+		}
+
+		if class == "*" {
+			e.addDynamic(start+i, source)
+		} else if class != "" {
+			line, column := e.lines.position(e.content, start+i)
+			e.usages = append(e.usages, ClassUsage{
+				Class:     class,
+				File:      e.file,
+				Line:      line,
+				Column:    column,
+				Uncertain: uncertain,
+				Source:    source,
+			})
+		}
+		i = j
+	}
+}
+
+// addDynamic adds a dynamic * at offset, for a value that can be any class.
+func (e *classExtractor) addDynamic(offset int, source string) {
+	line, column := e.lines.position(e.content, offset)
+	e.usages = append(e.usages, ClassUsage{
+		Class:   "*",
+		File:    e.file,
+		Line:    line,
+		Column:  column,
+		Dynamic: true,
+		Source:  source,
+	})
+}
+
+// toGlob turns the dynamic parts of token into *, and escapes the rest for
+// path.Match.
+func toGlob(token []byte) string {
+	var sb strings.Builder
+	for i, c := range token {
+		switch c {
+		case dynamic:
+			if i == 0 || token[i-1] != dynamic {
+				sb.WriteByte('*')
+			}
+		case '*', '?', '[', '\\':
+			sb.WriteByte('\\')
+			sb.WriteByte(c)
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// findSourceFiles returns the files under root with one of extensions,
+// skipping the directories matching one of the excludeDirs globs.
+func findSourceFiles(root string, extensions []string, excludeDirs []string) ([]string, error) {
+	exts := map[string]bool{}
+	for _, ext := range extensions {
+		exts["."+strings.TrimPrefix(strings.ToLower(ext), ".")] = true
+	}
+
+	var ret []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && containsGlob(excludeDirs, d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if exts[strings.ToLower(filepath.Ext(path))] {
+			ret = append(ret, path)
+		}
+		return nil
+	})
+	return ret, err
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestExtractClassUsages(t *testing.T) {
+	for _, tc := range []struct {
+		file     string
+		expected []ClassUsage
+	}{
+		{
+			file: "testdata/src/page.gohtml",
+			expected: []ClassUsage{
+				{Class: "card", Line: 1, Column: 13, Source: "class"},
+				{Class: "card--active", Line: 1, Column: 34, Source: "class"},
+				{Class: "btn", Line: 2, Column: 20, Source: "class"},
+				{Class: "btn-*", Line: 2, Column: 24, Uncertain: true, Source: "class"},
+			},
+		},
+		{
+			file: "testdata/src/components/Button.tsx",
+			expected: []ClassUsage{
+				{Class: "btn", Line: 4, Column: 28, Source: "className"},
+				{Class: "btn-*", Line: 4, Column: 35, Uncertain: true, Source: "className"},
+				{Class: "btn-active", Line: 4, Column: 52, Source: "className"},
+				{Class: "disabled", Line: 4, Column: 73, Source: "className"},
+				{Class: "clicked", Line: 5, Column: 60, Source: "classList"},
+				{Class: "icon-*", Line: 5, Column: 71, Uncertain: true, Source: "classList"},
+				{Class: "label", Line: 7, Column: 30, Source: "className"},
+			},
+		},
+		{
+			file: "testdata/src/components/Alert.vue",
+			expected: []ClassUsage{
+				{Class: "alert", Line: 2, Column: 15, Source: "class"},
+				{Class: "alert-error", Line: 2, Column: 33, Source: ":class"},
+				{Class: "open", Line: 2, Column: 57, Source: ":class"},
+				{Class: "big", Line: 3, Column: 32, Source: ":class"},
+			},
+		},
+		{
+			file: "testdata/src/list.php",
+			expected: []ClassUsage{
+				{Class: "list", Line: 1, Column: 12, Source: "class"},
+				{Class: "list-compact", Line: 1, Column: 40, Source: "class"},
+				{Class: "item", Line: 2, Column: 16, Source: "class"},
+				{Class: "item-*", Line: 2, Column: 21, Uncertain: true, Source: "class"},
+			},
+		},
+	} {
+		content, err := os.ReadFile(tc.file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range tc.expected {
+			tc.expected[i].File = tc.file
+		}
+		if got := ExtractClassUsages(tc.file, string(content)); !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: expected\n%+v\ngot\n%+v", tc.file, tc.expected, got)
+		}
+	}
+}
+
+func TestExtractClassUsages_Concatenation(t *testing.T) {
+	usages := ExtractClassUsages("x.js", `el.classList.toggle(prefix + '-open extra ' + suffix, "w-[10px]")`)
+
+	classes := []string{}
+	uncertain := []bool{}
+	dynamic := []bool{}
+	for _, usage := range usages {
+		classes = append(classes, usage.Class)
+		uncertain = append(uncertain, usage.Uncertain)
+		dynamic = append(dynamic, usage.Dynamic)
+	}
+	// suffix comes after a space, so it can be any class
+	if expected := []string{"*-open", "extra", "*", "w-[10px]"}; !reflect.DeepEqual(classes, expected) {
+		t.Fatalf("expected %v, got %v", expected, classes)
+	}
+	if expected := []bool{true, false, false, false}; !reflect.DeepEqual(uncertain, expected) {
+		t.Fatalf("expected uncertain %v, got %v", expected, uncertain)
+	}
+	if expected := []bool{false, false, true, false}; !reflect.DeepEqual(dynamic, expected) {
+		t.Fatalf("expected dynamic %v, got %v", expected, dynamic)
+	}
+}
+
+func TestExtractClassUsages_Dynamic(t *testing.T) {
+	for content, expected := range map[string][]ClassUsage{
+		`<div class="{{ .Classes }}">`: {
+			{Class: "*", Line: 1, Column: 13, Dynamic: true, Source: "class"},
+		},
+		`<div :class="cls">`: {
+			{Class: "*", Line: 1, Column: 14, Dynamic: true, Source: ":class"},
+		},
+		`<div className={'' + x}>`: {
+			{Class: "*", Line: 1, Column: 18, Dynamic: true, Source: "className"},
+		},
+		`<div className={styles.card}>`: {
+			{Class: "*", Line: 1, Column: 17, Dynamic: true, Source: "className"},
+		},
+		// a dynamic part next to literal classes is still any class
+		`<div className={'btn ' + extra}>`: {
+			{Class: "btn", Line: 1, Column: 18, Source: "className"},
+			{Class: "*", Line: 1, Column: 22, Dynamic: true, Source: "className"},
+		},
+		// conditions of object literals are not classes
+		`<div className={cx({active: isActive})}>`: {
+			{Class: "active", Line: 1, Column: 21, Source: "className"},
+		},
+	} {
+		for i := range expected {
+			expected[i].File = "x.html"
+		}
+		if got := ExtractClassUsages("x.html", content); !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected\n%+v\ngot\n%+v", content, expected, got)
+		}
+	}
+}
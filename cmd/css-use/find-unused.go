@@ -10,10 +10,14 @@ import (
 	"github.com/go-go-golems/glazed/pkg/settings"
 	"github.com/go-go-golems/glazed/pkg/types"
 	"github.com/pkg/errors"
+	"io"
+	"os"
 )
 
 type FindUnusedClassesCommand struct {
 	*cmds.CommandDescription
+	// sarifWriter receives the SARIF log when --output-file is not set
+	sarifWriter io.Writer
 }
 
 func NewFindUnusedClassesCommand() (*FindUnusedClassesCommand, error) {
@@ -26,6 +30,14 @@ func NewFindUnusedClassesCommand() (*FindUnusedClassesCommand, error) {
 		CommandDescription: cmds.NewCommandDescription(
 			"find-unused",
 			cmds.WithShort("Find unused classes command"),
+			cmds.WithLong("Find the classes of the --defined file that are not in the --used file.\n\n"+
+				"Uncertain entries of the --used file, the globs output by used for classes built at runtime "+
+				"(btn-*), are matched against the defined classes. Classes that only match such a glob "+
+				"are possibly used and left out, unless --include-uncertain is set. "+
+				"Dynamic entries, output by used for values such as className={className}, are ignored.\n\n"+
+				"With --format sarif, the unused classes are written as a SARIF log instead of rows, to the "+
+				"--output-file or the standard output, located at the line and column of the --defined file entries. "+
+				"Relative paths are relative to %SRCROOT%, the working directory."),
 			cmds.WithFlags(
 				parameters.NewParameterDefinition(
 					"used",
@@ -45,6 +57,19 @@ func NewFindUnusedClassesCommand() (*FindUnusedClassesCommand, error) {
 					parameters.WithHelp("Check if all classes in a file are unused"),
 					parameters.WithDefault(false),
 				),
+				parameters.NewParameterDefinition(
+					"include-uncertain",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Also output the classes that match an uncertain used class, with possibly_used set"),
+					parameters.WithDefault(false),
+				),
+				parameters.NewParameterDefinition(
+					"format",
+					parameters.ParameterTypeChoice,
+					parameters.WithHelp("Output rows, or a SARIF log for editors and code review tools"),
+					parameters.WithChoices([]string{"rows", "sarif"}),
+					parameters.WithDefault("rows"),
+				),
 				parameters.NewParameterDefinition(
 					"filter-defining-files",
 					parameters.ParameterTypeStringList,
@@ -68,6 +93,7 @@ func NewFindUnusedClassesCommand() (*FindUnusedClassesCommand, error) {
 				glazedParameterLayer,
 			),
 		),
+		sarifWriter: os.Stdout,
 	}, nil
 }
 
@@ -87,10 +113,17 @@ func (c *FindUnusedClassesCommand) Run(
 	if !ok {
 		return errors.New("could not cast defined")
 	}
+	used = classEntries(used)
+	defined = classEntries(defined)
 
 	usedMap := make(map[string]bool)
+	uncertainGlobs := []string{}
 	for _, entry := range used {
 		class := entry["class"].(string)
+		if uncertain, _ := entry["uncertain"].(bool); uncertain {
+			uncertainGlobs = append(uncertainGlobs, class)
+			continue
+		}
 		usedMap[class] = true
 	}
 
@@ -116,15 +149,31 @@ func (c *FindUnusedClassesCommand) Run(
 			}
 		}
 		class_ := entry["class"].(string)
-		for _, file := range definedClassesToFiles[class_] {
-			if _, found := fileToUsedClasses[file]; !found {
-				fileToUsedClasses[file] = make(map[string]interface{})
+		classes_ := []string{class_}
+		if uncertain, _ := entry["uncertain"].(bool); uncertain {
+			// count possibly used classes as used, so that a file is never
+			// reported as all unused because of them
+			classes_ = []string{}
+			for definedClass := range definedClassesToFiles {
+				if containsGlob([]string{class_}, definedClass) {
+					classes_ = append(classes_, definedClass)
+				}
+			}
+		}
+		for _, class__ := range classes_ {
+			for _, file := range definedClassesToFiles[class__] {
+				if _, found := fileToUsedClasses[file]; !found {
+					fileToUsedClasses[file] = make(map[string]interface{})
+				}
+				fileToUsedClasses[file][class__] = true
 			}
-			fileToUsedClasses[file][class_] = true
 		}
 	}
 
 	checkAllUnused := ps["check-all-unused"].(bool)
+	includeUncertain, _ := ps["include-uncertain"].(bool)
+	format, _ := ps["format"].(string)
+	var sarifRows []types.Row
 
 	filterDefiningFiles := ps["filter-defining-files"].([]string)
 	filterClasses := ps["filter-classes"].([]string)
@@ -143,10 +192,22 @@ func (c *FindUnusedClassesCommand) Run(
 					continue
 				}
 			}
+			possiblyUsed := containsGlob(uncertainGlobs, class)
+			if possiblyUsed && !includeUncertain {
+				continue
+			}
 			row := types.NewRow(
 				types.MRP("class", class),
 				types.MRP("file", filename),
 			)
+			for _, key := range []string{"stylesheet", "line", "column"} {
+				if v, ok := entry[key]; ok {
+					row.Set(key, v)
+				}
+			}
+			if includeUncertain {
+				row.Set("possibly_used", possiblyUsed)
+			}
 
 			if checkAllUnused {
 				totalClasses := fileToTotalClasses[filename]
@@ -155,10 +216,60 @@ func (c *FindUnusedClassesCommand) Run(
 				row.Set("used_classes", usedClasses)
 				row.Set("all_unused", usedClasses == 0)
 			}
+			if format == "sarif" {
+				sarifRows = append(sarifRows, row)
+				continue
+			}
 			if err := gp.AddRow(ctx, row); err != nil {
 				return err
 			}
 		}
 	}
+
+	if format == "sarif" {
+		if err := c.writeSARIF(ps, sarifRows); err != nil {
+			return err
+		}
+		// the SARIF log replaces the glazed output, which would otherwise
+		// print an empty table or overwrite the --output-file
+		return &cmds.ExitWithoutGlazeError{}
+	}
 	return nil
 }
+
+func (c *FindUnusedClassesCommand) writeSARIF(ps map[string]interface{}, rows []types.Row) error {
+	outputFile, _ := ps["output-file"].(string)
+	if outputFile == "" {
+		return writeUnusedClassesSARIF(c.sarifWriter, rows)
+	}
+
+	f, err := os.Create(outputFile)
+	if err != nil {
+		return err
+	}
+	if err := writeUnusedClassesSARIF(f, rows); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// classEntries drops the entries that are not classes, such as the keyframes
+// and font-face rows that defined outputs with --with-at-rules, and the
+// dynamic values that used outputs for expressions such as {{ .Classes }}.
+func classEntries(entries []map[string]interface{}) []map[string]interface{} {
+	ret := []map[string]interface{}{}
+	for _, entry := range entries {
+		if type_, ok := entry["type"].(string); ok && type_ != "class" {
+			continue
+		}
+		if dynamic, _ := entry["dynamic"].(bool); dynamic {
+			continue
+		}
+		if _, ok := entry["class"].(string); !ok {
+			continue
+		}
+		ret = append(ret, entry)
+	}
+	return ret
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindUnusedClassesCommand_UncertainClasses(t *testing.T) {
+	cmd, err := NewFindUnusedClassesCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	used := []interface{}{
+		map[string]interface{}{"class": "btn", "file": "page.gohtml"},
+		map[string]interface{}{"class": "btn-*", "file": "page.gohtml", "uncertain": true},
+	}
+	run := func(used []interface{}, includeUncertain bool) map[string]interface{} {
+		gp := &rowCollector{}
+		err := cmd.Run(context.Background(), nil, map[string]interface{}{
+			"used": used,
+			"defined": []interface{}{
+				map[string]interface{}{"class": "btn", "file": "site.css"},
+				map[string]interface{}{"class": "btn-large", "file": "site.css"},
+				map[string]interface{}{"class": "card", "file": "site.css"},
+			},
+			"check-all-unused":      false,
+			"include-uncertain":     includeUncertain,
+			"filter-defining-files": []string{},
+			"filter-using-files":    []string{},
+			"filter-classes":        []string{},
+		}, gp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret := map[string]interface{}{}
+		for _, row := range gp.rows {
+			class, _ := row.Get("class")
+			possiblyUsed, _ := row.Get("possibly_used")
+			ret[class.(string)] = possiblyUsed
+		}
+		return ret
+	}
+
+	if got, expected := run(used, false), map[string]interface{}{"card": nil}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+	if got, expected := run(used, true), map[string]interface{}{"card": false, "btn-large": true}; !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+
+}
+
+func TestFindUnusedAndPrune_DynamicClassName(t *testing.T) {
+	// className={className} passes the classes of the parent through, and
+	// must not protect every class
+	content := `<button className={className}><span className="btn">{label}</span></button>`
+	used := []interface{}{}
+	for _, usage := range ExtractClassUsages("Button.tsx", content) {
+		used = append(used, map[string]interface{}{
+			"class":     usage.Class,
+			"file":      usage.File,
+			"uncertain": usage.Uncertain,
+			"dynamic":   usage.Dynamic,
+		})
+	}
+	if len(used) != 2 {
+		t.Fatalf("expected a dynamic and a literal class, got %v", used)
+	}
+
+	findUnused, err := NewFindUnusedClassesCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp := &rowCollector{}
+	err = findUnused.Run(context.Background(), nil, map[string]interface{}{
+		"used": used,
+		"defined": []interface{}{
+			map[string]interface{}{"class": "btn", "file": "style.css"},
+			map[string]interface{}{"class": "legacy", "file": "style.css"},
+		},
+		"check-all-unused":      false,
+		"include-uncertain":     true,
+		"filter-defining-files": []string{},
+		"filter-using-files":    []string{},
+		"filter-classes":        []string{},
+	}, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(gp.rows) != 1 {
+		t.Fatalf("expected legacy to be unused, got %d rows", len(gp.rows))
+	}
+	if class, _ := gp.rows[0].Get("class"); class != "legacy" {
+		t.Fatalf("expected legacy to be unused, got %v", class)
+	}
+	if possiblyUsed, _ := gp.rows[0].Get("possibly_used"); possiblyUsed != false {
+		t.Fatalf("expected legacy not to be possibly used, got %v", possiblyUsed)
+	}
+
+	prune, err := NewPruneCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputDir := t.TempDir()
+	gp = &rowCollector{}
+	err = prune.Run(context.Background(), nil, map[string]interface{}{
+		"used":       used,
+		"safelist":   []string{},
+		"output-dir": outputDir,
+		"files":      []string{"testdata/prune/style.css"},
+	}, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pruned, err := os.ReadFile(filepath.Join(outputDir, "style.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(pruned), ".legacy, .old-legacy") {
+		t.Fatalf("expected the legacy rule to be pruned, got\n%s", pruned)
+	}
+}
+
+func TestWriteUnusedClassesSARIF(t *testing.T) {
+	rows := []types.Row{
+		types.NewRow(
+			types.MRP("class", "card"),
+			types.MRP("file", "index.html"),
+			types.MRP("stylesheet", "index.html#style-1"),
+			types.MRP("line", float64(8)),
+			types.MRP("column", float64(9)),
+		),
+		types.NewRow(
+			types.MRP("class", "btn-large"),
+			types.MRP("file", "css/site.css"),
+			types.MRP("possibly_used", true),
+		),
+		types.NewRow(
+			types.MRP("class", "hero"),
+			types.MRP("file", "/srv/www/css/hero banner.css"),
+		),
+	}
+	sb := &strings.Builder{}
+	if err := writeUnusedClassesSARIF(sb, rows); err != nil {
+		t.Fatal(err)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal([]byte(sb.String()), &log); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	srcRoot := log.Runs[0].OriginalURIBaseIDs["%SRCROOT%"].URI
+	if srcRoot != "file://"+filepath.ToSlash(wd)+"/" {
+		t.Fatalf("expected %%SRCROOT%% to be the working directory, got %s", srcRoot)
+	}
+	results := log.Runs[0].Results
+	if len(results) != 3 {
+		t.Fatalf("expected 3 results, got %d", len(results))
+	}
+	location := results[0].Locations[0].PhysicalLocation
+	if results[0].RuleID != "unused-class" ||
+		location.ArtifactLocation != (sarifArtifactLocation{URI: "index.html", URIBaseID: "%SRCROOT%"}) ||
+		!reflect.DeepEqual(location.Region, &sarifRegion{StartLine: 8, StartColumn: 9}) {
+		t.Fatalf("unexpected result %+v at %+v", results[0], location)
+	}
+	location = results[1].Locations[0].PhysicalLocation
+	if results[1].RuleID != "possibly-unused-class" || results[1].Level != "note" ||
+		location.ArtifactLocation != (sarifArtifactLocation{URI: "css/site.css", URIBaseID: "%SRCROOT%"}) ||
+		location.Region != nil {
+		t.Fatalf("unexpected result %+v at %+v", results[1], location)
+	}
+	location = results[2].Locations[0].PhysicalLocation
+	if location.ArtifactLocation != (sarifArtifactLocation{URI: "file:///srv/www/css/hero%20banner.css"}) {
+		t.Fatalf("unexpected result %+v at %+v", results[2], location)
+	}
+}
+
+func TestFindUnusedClassesCommand_SARIF(t *testing.T) {
+	cmd, err := NewFindUnusedClassesCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(outputFile string) string {
+		sb := &strings.Builder{}
+		cmd.sarifWriter = sb
+		gp := &rowCollector{}
+		err := cmd.Run(context.Background(), nil, map[string]interface{}{
+			"used": []interface{}{
+				map[string]interface{}{"class": "btn", "file": "page.gohtml"},
+			},
+			"defined": []interface{}{
+				map[string]interface{}{"class": "btn", "file": "site.css"},
+				map[string]interface{}{"class": "card", "file": "site.css", "line": float64(3), "column": float64(1)},
+			},
+			"check-all-unused":      false,
+			"include-uncertain":     false,
+			"format":                "sarif",
+			"output-file":           outputFile,
+			"filter-defining-files": []string{},
+			"filter-using-files":    []string{},
+			"filter-classes":        []string{},
+		}, gp)
+		if _, ok := err.(*cmds.ExitWithoutGlazeError); !ok {
+			t.Fatalf("expected the glazed output to be skipped, got %v", err)
+		}
+		if len(gp.rows) != 0 {
+			t.Fatalf("expected no rows, got %d", len(gp.rows))
+		}
+		return sb.String()
+	}
+
+	checkLog := func(s string) {
+		var log sarifLog
+		if err := json.Unmarshal([]byte(s), &log); err != nil {
+			t.Fatal(err)
+		}
+		results := log.Runs[0].Results
+		if len(results) != 1 || results[0].Message.Text == "" ||
+			results[0].Locations[0].PhysicalLocation.ArtifactLocation.URI != "site.css" {
+			t.Fatalf("unexpected results %+v", results)
+		}
+	}
+
+	checkLog(run(""))
+
+	outputFile := filepath.Join(t.TempDir(), "unused.sarif")
+	if s := run(outputFile); s != "" {
+		t.Fatalf("expected nothing on the writer, got %s", s)
+	}
+	content, err := os.ReadFile(outputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkLog(string(content))
+}
@@ -38,6 +38,42 @@ func main() {
 	cobra.CheckErr(err)
 	rootCmd.AddCommand(command)
 
+	selectorsCmd, err := NewSelectorsCommand()
+	cobra.CheckErr(err)
+	command, err = cli.BuildCobraCommandFromGlazeCommand(selectorsCmd)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(command)
+
+	conflictsCmd, err := NewConflictsCommand()
+	cobra.CheckErr(err)
+	command, err = cli.BuildCobraCommandFromGlazeCommand(conflictsCmd)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(command)
+
+	pruneCmd, err := NewPruneCommand()
+	cobra.CheckErr(err)
+	command, err = cli.BuildCobraCommandFromGlazeCommand(pruneCmd)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(command)
+
+	unreferencedCmd, err := NewUnreferencedCommand()
+	cobra.CheckErr(err)
+	command, err = cli.BuildCobraCommandFromGlazeCommand(unreferencedCmd)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(command)
+
+	watchCmd, err := NewWatchCommand()
+	cobra.CheckErr(err)
+	command, err = cli.BuildCobraCommandFromWriterCommand(watchCmd)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(command)
+
+	crawlCmd, err := NewCrawlCommand()
+	cobra.CheckErr(err)
+	command, err = cli.BuildCobraCommandFromGlazeCommand(crawlCmd)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(command)
+
 	UsageCmd, err := NewFindUsageClassesCommand()
 	cobra.CheckErr(err)
 	command, err = cli.BuildCobraCommandFromGlazeCommand(UsageCmd)
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"golang.org/x/net/html"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// lineIndex holds the offsets at which the lines of a text start.
+type lineIndex []int
+
+func newLineIndex(content string) lineIndex {
+	ret := lineIndex{0}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			ret = append(ret, i+1)
+		}
+	}
+	return ret
+}
+
+// position returns the 1-based line and column (in characters) of offset in
+// content, which the index was built from.
+func (l lineIndex) position(content string, offset int) (int, int) {
+	if offset > len(content) {
+		offset = len(content)
+	}
+	line := sort.Search(len(l), func(i int) bool {
+		return l[i] > offset
+	})
+	lineStart := l[line-1]
+	return line, utf8.RuneCountInString(content[lineStart:offset]) + 1
+}
+
+// htmlToken is a token of an HTML document, with the offset of its raw text.
+type htmlToken struct {
+	html.Token
+	Raw    string
+	Offset int
+}
+
+// tokenizeHTML returns the tokens of content with their offsets. The
+// tokenizer doesn't keep track of positions, so offsets are the sum of the
+// lengths of the raw text of the previous tokens.
+func tokenizeHTML(content string) []htmlToken {
+	z := html.NewTokenizer(strings.NewReader(content))
+	var ret []htmlToken
+	offset := 0
+	for {
+		// the tokenizer only fails at the end of the input, as reading
+		// from a strings.Reader can't fail
+		if z.Next() == html.ErrorToken {
+			return ret
+		}
+		raw := string(z.Raw())
+		ret = append(ret, htmlToken{Token: z.Token(), Raw: raw, Offset: offset})
+		offset += len(raw)
+	}
+}
+
+// rawAttribute is an attribute of the raw text of a start tag, with the
+// offsets of its value, excluding quotes.
+type rawAttribute struct {
+	Name       string
+	ValueStart int
+	ValueEnd   int
+}
+
+// rawAttributes parses the attributes of the raw text of a start tag, such
+// as `<div id=main class="a b">`, the way the HTML tokenizer does.
+func rawAttributes(raw string) []rawAttribute {
+	isSpace := func(c byte) bool {
+		return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+	}
+	i := 1
+	// tag name
+	for i < len(raw) && !isSpace(raw[i]) && raw[i] != '/' && raw[i] != '>' {
+		i++
+	}
+
+	var ret []rawAttribute
+	for i < len(raw) {
+		for i < len(raw) && (isSpace(raw[i]) || raw[i] == '/') {
+			i++
+		}
+		if i >= len(raw) || raw[i] == '>' {
+			break
+		}
+		nameStart := i
+		// an attribute name can start with =
+		i++
+		for i < len(raw) && !isSpace(raw[i]) && raw[i] != '/' && raw[i] != '>' && raw[i] != '=' {
+			i++
+		}
+		attr := rawAttribute{Name: strings.ToLower(raw[nameStart:i]), ValueStart: i, ValueEnd: i}
+		j := i
+		for j < len(raw) && isSpace(raw[j]) {
+			j++
+		}
+		if j < len(raw) && raw[j] == '=' {
+			j++
+			for j < len(raw) && isSpace(raw[j]) {
+				j++
+			}
+			switch {
+			case j < len(raw) && (raw[j] == '"' || raw[j] == '\''):
+				end := strings.IndexByte(raw[j+1:], raw[j])
+				if end < 0 {
+					end = len(raw) - j - 1
+				}
+				attr.ValueStart, attr.ValueEnd = j+1, j+1+end
+				i = j + 1 + end + 1
+			default:
+				start := j
+				for j < len(raw) && !isSpace(raw[j]) && raw[j] != '>' {
+					j++
+				}
+				attr.ValueStart, attr.ValueEnd = start, j
+				i = j
+			}
+		}
+		ret = append(ret, attr)
+	}
+	return ret
+}
+
+// getHTMLClassUsages returns the first occurrence of each class used in the
+// class attributes of the HTML document content, sorted alphabetically.
+func getHTMLClassUsages(file string, content string) []ClassUsage {
+	lines := newLineIndex(content)
+	seen := map[string]bool{}
+	var ret []ClassUsage
+	for _, token := range tokenizeHTML(content) {
+		if token.Type != html.StartTagToken && token.Type != html.SelfClosingTagToken {
+			continue
+		}
+		for _, attr := range rawAttributes(token.Raw) {
+			if attr.Name != "class" {
+				continue
+			}
+			value := token.Raw[attr.ValueStart:attr.ValueEnd]
+			for _, field := range fieldsWithOffsets(value) {
+				class := html.UnescapeString(field.text)
+				if class == "" || seen[class] {
+					continue
+				}
+				seen[class] = true
+				line, column := lines.position(content, token.Offset+attr.ValueStart+field.offset)
+				ret = append(ret, ClassUsage{
+					Class:  class,
+					File:   file,
+					Line:   line,
+					Column: column,
+					Source: "class",
+				})
+			}
+			// like browsers, only the first class attribute counts
+			break
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Class < ret[j].Class
+	})
+	return ret
+}
+
+type textField struct {
+	text   string
+	offset int
+}
+
+// fieldsWithOffsets splits s on whitespace like strings.Fields, keeping the
+// offset of each field.
+func fieldsWithOffsets(s string) []textField {
+	var ret []textField
+	start := -1
+	for i := 0; i <= len(s); i++ {
+		if i == len(s) || strings.IndexByte(" \t\n\r\f", s[i]) >= 0 {
+			if start >= 0 {
+				ret = append(ret, textField{s[start:i], start})
+				start = -1
+			}
+			continue
+		}
+		if start < 0 {
+			start = i
+		}
+	}
+	return ret
+}
+
+// styleBlock is the content of a <style> element of an HTML document.
+type styleBlock struct {
+	Text   string
+	Offset int
+}
+
+// getStyleBlocks returns the <style> elements of the HTML document content,
+// in document order.
+func getStyleBlocks(content string) []styleBlock {
+	var ret []styleBlock
+	tokens := tokenizeHTML(content)
+	for i, token := range tokens {
+		if token.Type != html.StartTagToken || token.Data != "style" {
+			continue
+		}
+		block := styleBlock{Offset: token.Offset + len(token.Raw)}
+		if i+1 < len(tokens) && tokens[i+1].Type == html.TextToken {
+			block.Text = tokens[i+1].Data
+		}
+		ret = append(ret, block)
+	}
+	return ret
+}
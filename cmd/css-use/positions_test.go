@@ -0,0 +1,26 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetHTMLClassUsages(t *testing.T) {
+	content := "<div data-x='class=\"fake\"' class=\"a  b\">\n" +
+		"  <p class=b>é <span CLASS = 'c&amp;d a'></span>\n" +
+		"  <img class=\"e\" class=\"ignored\"/>\n"
+
+	got := [][]interface{}{}
+	for _, usage := range getHTMLClassUsages("page.html", content) {
+		got = append(got, []interface{}{usage.Class, usage.Line, usage.Column})
+	}
+	expected := [][]interface{}{
+		{"a", 1, 35},
+		{"b", 1, 38},
+		{"c&d", 2, 31},
+		{"e", 3, 15},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/helpers/cast"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/go-go-labs/cmd/css-use/selector"
+	"github.com/go-go-golems/go-go-labs/pkg/csstree"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+type PruneCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewPruneCommand() (*PruneCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, fmt.Errorf("could not create Glazed parameter layer: %w", err)
+	}
+
+	return &PruneCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"prune",
+			cmds.WithShort("Write CSS files without the rules that only match unused classes."),
+			cmds.WithLong("Write a copy of each CSS file without the rules whose selectors can only match classes "+
+				"that are not in the --used file, and output the bytes saved per file.\n\n"+
+				"A rule is removed when every selector of its selector list requires an unused class. "+
+				"Rules that also match used elements, comments and at-rules are kept as is. "+
+				"Uncertain entries of the --used file (btn-*) and the --safelist globs protect the classes they match, "+
+				"dynamic entries (className={className}) are ignored.\n\n"+
+				"The pruned copy of style.css is written to style.pruned.css, or to style.css in --output-dir. "+
+				"Files are never pruned in place, and inputs with the same name are refused with --output-dir."),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"used",
+					parameters.ParameterTypeObjectListFromFile,
+					parameters.WithHelp("Path to the used.json file"),
+					parameters.WithRequired(true),
+				),
+				parameters.NewParameterDefinition(
+					"safelist",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Classes to keep even if unused (can be glob)"),
+					parameters.WithDefault([]string{}),
+				),
+				parameters.NewParameterDefinition(
+					"output-dir",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Directory to write the pruned files to (defaults to writing <name>.pruned.css next to each file)"),
+				),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"files",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("List of CSS files (or URLs) to prune."),
+					parameters.WithDefault([]string{}),
+				),
+			),
+			cmds.WithLayers(glazedParameterLayer),
+		),
+	}, nil
+}
+
+func (c *PruneCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	used_ := ps["used"].([]interface{})
+	used, ok := cast.CastList2[map[string]interface{}, interface{}](used_)
+	if !ok {
+		return fmt.Errorf("could not cast used")
+	}
+	safelist, _ := ps["safelist"].([]string)
+	outputDir, _ := ps["output-dir"].(string)
+	urls := ps["files"].([]string)
+
+	usedMap := map[string]bool{}
+	// uncertain classes (btn-*) are globs, and protect the classes they match
+	// like the safelist does
+	globs := append([]string{}, safelist...)
+	for _, entry := range classEntries(used) {
+		class := entry["class"].(string)
+		if uncertain, _ := entry["uncertain"].(bool); uncertain {
+			globs = append(globs, class)
+			continue
+		}
+		usedMap[class] = true
+	}
+	isUsed := func(class string) bool {
+		return usedMap[class] || containsGlob(globs, class)
+	}
+
+	outputs, err := prunedFileNames(urls, outputDir)
+	if err != nil {
+		return err
+	}
+
+	for i, url := range urls {
+		reader, err := ReaderUrlOrFile(url)
+		if err != nil {
+			return err
+		}
+		content, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+
+		output := outputs[i]
+		pruned, removed := pruneCSS(string(content), isUsed)
+		if err := writeFileAtomic(output, []byte(pruned)); err != nil {
+			return err
+		}
+
+		saved := len(content) - len(pruned)
+		savedPercent := 0.0
+		if len(content) > 0 {
+			savedPercent = math.Round(float64(saved)*1000/float64(len(content))) / 10
+		}
+		row := types.NewRow(
+			types.MRP("file", url),
+			types.MRP("output", output),
+			types.MRP("removed_rules", removed),
+			types.MRP("original_bytes", len(content)),
+			types.MRP("pruned_bytes", len(pruned)),
+			types.MRP("saved_bytes", saved),
+			types.MRP("saved_percent", savedPercent),
+		)
+		if err := gp.AddRow(ctx, row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// prunedFileNames returns the output file of each of urls, and fails before
+// anything is written if one would overwrite its input, or if two inputs
+// would be written to the same file, such as a/style.css and b/style.css
+// with --output-dir.
+func prunedFileNames(urls []string, outputDir string) ([]string, error) {
+	ret := make([]string, len(urls))
+	inputs := map[string]string{}
+	for i, url := range urls {
+		output, err := prunedFileName(url, outputDir)
+		if err != nil {
+			return nil, err
+		}
+		absOutput, err := filepath.Abs(output)
+		if err != nil {
+			return nil, err
+		}
+		if !isURL(url) {
+			absInput, err := filepath.Abs(url)
+			if err != nil {
+				return nil, err
+			}
+			if absInput == absOutput {
+				return nil, fmt.Errorf("pruning %s would overwrite it, use another --output-dir", url)
+			}
+		}
+		if other, ok := inputs[absOutput]; ok {
+			return nil, fmt.Errorf("%s and %s would both be pruned to %s", other, url, output)
+		}
+		inputs[absOutput] = url
+		ret[i] = output
+	}
+	return ret, nil
+}
+
+func prunedFileName(url string, outputDir string) (string, error) {
+	if isURL(url) {
+		if outputDir == "" {
+			return "", fmt.Errorf("--output-dir is required to prune %s", url)
+		}
+		name := path.Base(strings.SplitN(strings.SplitN(url, "?", 2)[0], "#", 2)[0])
+		if name == "" || name == "/" || name == "." {
+			name = "style.css"
+		}
+		return filepath.Join(outputDir, name), nil
+	}
+	if outputDir != "" {
+		return filepath.Join(outputDir, filepath.Base(url)), nil
+	}
+	ext := filepath.Ext(url)
+	return strings.TrimSuffix(url, ext) + ".pruned" + ext, nil
+}
+
+// groupAtRules are the at-rules whose block contains rulesets that can be
+// pruned. The blocks of other at-rules, such as @font-face and @keyframes,
+// are kept as is.
+var groupAtRules = map[string]bool{
+	"@media":          true,
+	"@supports":       true,
+	"@document":       true,
+	"@layer":          true,
+	"@container":      true,
+	"@scope":          true,
+	"@starting-style": true,
+}
+
+// pruneCSS removes the rulesets of content whose selectors can only match
+// classes for which isUsed returns false. Everything else, including
+// comments, at-rules and formatting, is left untouched. It returns the pruned
+// stylesheet and the number of rulesets removed.
+func pruneCSS(content string, isUsed func(class string) bool) (string, int) {
+	tree := csstree.Parse(content)
+	removed := pruneChildren(tree, isUsed)
+	if removed == 0 {
+		return content, 0
+	}
+	return csstree.Render(tree), removed
+}
+
+// pruneChildren removes the dead rulesets of the children of n, and returns
+// how many were removed. The whitespace before a removed ruleset goes with
+// it, except for the first child, whose whitespace the next child takes.
+func pruneChildren(n *csstree.Node, isUsed func(string) bool) int {
+	removed := 0
+	var kept []*csstree.Node
+	var firstBefore *string
+	for _, c := range n.Children {
+		switch c.Type {
+		case csstree.TypeAtRule:
+			if groupAtRules[unprefixed("@"+strings.ToLower(c.Name))] {
+				removed += pruneChildren(c, isUsed)
+			}
+		case csstree.TypeRule:
+			if isDeadSelector(normalize(c.Selector), isUsed) {
+				removed++
+				if len(kept) == 0 && firstBefore == nil {
+					firstBefore = &c.Raws.Before
+				}
+				continue
+			}
+		}
+		if len(kept) == 0 && firstBefore != nil && c.Raws != nil {
+			c.Raws.Before = *firstBefore
+		}
+		kept = append(kept, c)
+	}
+	n.Children = kept
+	return removed
+}
+
+// isDeadSelector returns true if every selector of the list requires an
+// unused class. Selectors that can't be parsed are kept.
+func isDeadSelector(s string, isUsed func(string) bool) bool {
+	l, err := selector.Parse(strings.TrimSpace(s))
+	if err != nil || len(l) == 0 {
+		return false
+	}
+	return isDeadList(l, isUsed)
+}
+
+func isDeadList(l selector.SelectorList, isUsed func(string) bool) bool {
+	for _, c := range l {
+		dead := false
+		for _, part := range c.Parts {
+			if isDeadCompound(part.Compound, isUsed) {
+				dead = true
+				break
+			}
+		}
+		if !dead {
+			return false
+		}
+	}
+	return true
+}
+
+func isDeadCompound(c *selector.Compound, isUsed func(string) bool) bool {
+	for _, s := range c.Simples {
+		switch s := s.(type) {
+		case *selector.Class:
+			if !isUsed(s.Name) {
+				return true
+			}
+		case *selector.PseudoClass:
+			// :is(.a, .b) requires one of its arguments, while :not() and
+			// :has() don't require the classes they mention
+			switch s.Name {
+			case "is", "where", "matches", "-webkit-any", "-moz-any":
+				if len(s.Selectors) > 0 && isDeadList(s.Selectors, isUsed) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so that a failed write never leaves a truncated file behind.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(0644)
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("could not write %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPruneCommand(t *testing.T) {
+	cmd, err := NewPruneCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputDir := t.TempDir()
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, map[string]interface{}{
+		"used": []interface{}{
+			map[string]interface{}{"class": "btn", "file": "index.html"},
+			map[string]interface{}{"class": "btn-*", "file": "page.gohtml", "uncertain": true},
+			map[string]interface{}{"class": "sidebar", "file": "index.html"},
+		},
+		"safelist":   []string{"card-*"},
+		"output-dir": outputDir,
+		"files":      []string{"testdata/prune/style.css"},
+	}, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := os.ReadFile(filepath.Join(outputDir, "style.css"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile("testdata/prune/style.expected.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(expected) {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, got)
+	}
+
+	if len(gp.rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(gp.rows))
+	}
+	original, err := os.ReadFile("testdata/prune/style.css")
+	if err != nil {
+		t.Fatal(err)
+	}
+	removed, _ := gp.rows[0].Get("removed_rules")
+	saved, _ := gp.rows[0].Get("saved_bytes")
+	if removed != 3 || saved != len(original)-len(expected) {
+		t.Fatalf("expected 3 removed rules and %d saved bytes, got %v and %v",
+			len(original)-len(expected), removed, saved)
+	}
+}
+
+func TestPruneCommand_OutputConflicts(t *testing.T) {
+	cmd, err := NewPruneCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, name, "style.css"), []byte(".unused { color: red }\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	run := func(outputDir string, files ...string) error {
+		return cmd.Run(context.Background(), nil, map[string]interface{}{
+			"used":       []interface{}{},
+			"safelist":   []string{},
+			"output-dir": outputDir,
+			"files":      files,
+		}, &rowCollector{})
+	}
+
+	a := filepath.Join(dir, "a", "style.css")
+	if err := run(filepath.Join(dir, "a"), a); err == nil || !strings.Contains(err.Error(), "overwrite") {
+		t.Fatalf("expected an error for an output overwriting its input, got %v", err)
+	}
+	outputDir := t.TempDir()
+	if err := run(outputDir, a, filepath.Join(dir, "b", "style.css")); err == nil || !strings.Contains(err.Error(), "both") {
+		t.Fatalf("expected an error for two inputs with the same output, got %v", err)
+	}
+	if entries, _ := os.ReadDir(outputDir); len(entries) != 0 {
+		t.Fatalf("expected nothing to be written, got %v", entries)
+	}
+	content, err := os.ReadFile(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != ".unused { color: red }\n" {
+		t.Fatalf("expected the input to be untouched, got %s", content)
+	}
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/chromedp/cdproto/runtime"
+	"github.com/chromedp/chromedp"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/rs/zerolog/log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RenderedPage is what a page looks like in the browser after its scripts
+// have run.
+type RenderedPage struct {
+	// Classes are the classes of the live DOM, sorted alphabetically.
+	Classes []string
+	// Stylesheets are the stylesheets of document.styleSheets, in the same
+	// order as StylesheetLoader.LoadHTML.
+	Stylesheets []Stylesheet
+}
+
+// Renderer loads pages in headless Chrome.
+type Renderer struct {
+	// IdleTime is how long the DOM has to stay unchanged before the page is
+	// considered rendered.
+	IdleTime time.Duration
+	// Timeout is the maximum time spent on a page.
+	Timeout time.Duration
+	// Loader fetches the stylesheets whose rules the browser doesn't expose,
+	// such as cross-origin stylesheets.
+	Loader *StylesheetLoader
+
+	ctx    context.Context
+	cancel func()
+}
+
+// NewRenderer starts a headless Chrome that is stopped by Close or when ctx
+// is canceled. If chromePath is empty, Chrome is looked up in the usual
+// locations.
+func NewRenderer(ctx context.Context, chromePath string, loader *StylesheetLoader) (*Renderer, error) {
+	opts := append(chromedp.DefaultExecAllocatorOptions[:],
+		chromedp.Flag("headless", true),
+		chromedp.Flag("ignore-certificate-errors", true),
+		chromedp.WindowSize(1920, 1080),
+	)
+	if chromePath != "" {
+		opts = append(opts, chromedp.ExecPath(chromePath))
+	}
+
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(ctx, opts...)
+	browserCtx, cancelBrowser := chromedp.NewContext(allocCtx)
+	cancel := func() {
+		cancelBrowser()
+		cancelAlloc()
+	}
+
+	// start the browser, so that each page gets opened in its own tab
+	if err := chromedp.Run(browserCtx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("could not start Chrome: %w", err)
+	}
+
+	return &Renderer{
+		IdleTime: 500 * time.Millisecond,
+		Timeout:  30 * time.Second,
+		Loader:   loader,
+		ctx:      browserCtx,
+		cancel:   cancel,
+	}, nil
+}
+
+func (r *Renderer) Close() {
+	r.cancel()
+}
+
+// waitForIdleJS resolves once the DOM hasn't changed for the idle time, or at
+// the latest after the deadline. Both are formatted in, in milliseconds.
+const waitForIdleJS = `new Promise(resolve => {
+	let timer;
+	let deadline;
+	const observer = new MutationObserver(() => {
+		clearTimeout(timer);
+		timer = setTimeout(done, %d);
+	});
+	function done() {
+		observer.disconnect();
+		clearTimeout(timer);
+		clearTimeout(deadline);
+		resolve(true);
+	}
+	observer.observe(document, {attributes: true, childList: true, characterData: true, subtree: true});
+	timer = setTimeout(done, %d);
+	deadline = setTimeout(done, %d);
+})`
+
+const classesJS = `Array.from(new Set(
+	Array.from(document.querySelectorAll('[class]')).flatMap(e => Array.from(e.classList))
+)).sort()`
+
+// stylesheetsJS lists the enabled stylesheets of the document, imported
+// stylesheets first. Inline stylesheets are named #style-<n> like in
+// StylesheetLoader.LoadHTML. The rules of stylesheets the page isn't allowed
+// to read are left out.
+const stylesheetsJS = `(() => {
+	const ret = [];
+	let styleCount = 0;
+	const visit = (sheet, importedFrom) => {
+		let name = sheet.href || '';
+		if (!name && sheet.ownerNode && sheet.ownerNode.nodeName === 'STYLE') {
+			styleCount++;
+			name = '#style-' + styleCount;
+		}
+		const s = {name: name, importedFrom: importedFrom, readable: true, cssText: ''};
+		let rules = [];
+		try {
+			rules = Array.from(sheet.cssRules);
+		} catch (e) {
+			s.readable = false;
+		}
+		const texts = [];
+		for (const rule of rules) {
+			if (rule instanceof CSSImportRule) {
+				if (rule.styleSheet) {
+					visit(rule.styleSheet, name);
+				}
+				continue;
+			}
+			texts.push(rule.cssText);
+		}
+		s.cssText = texts.join('\n');
+		ret.push(s);
+	};
+	for (const sheet of Array.from(document.styleSheets)) {
+		if (!sheet.disabled) {
+			visit(sheet, '');
+		}
+	}
+	return ret;
+})()`
+
+type renderedStylesheet struct {
+	Name         string `json:"name"`
+	ImportedFrom string `json:"importedFrom"`
+	Readable     bool   `json:"readable"`
+	CSSText      string `json:"cssText"`
+}
+
+// Render loads page, which is a URL or a path to a local file, waits for it
+// to be idle and returns its classes and stylesheets.
+func (r *Renderer) Render(page string) (*RenderedPage, error) {
+	pageURL, err := toBrowserURL(page)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := chromedp.NewContext(r.ctx)
+	defer cancel()
+	ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+	defer cancel()
+	start := time.Now()
+
+	idle := r.IdleTime.Milliseconds()
+	var classes []string
+	var sheets []renderedStylesheet
+	err = chromedp.Run(ctx,
+		chromedp.Navigate(pageURL),
+		chromedp.ActionFunc(func(ctx context.Context) error {
+			// pages that never stop changing, such as the ones with a clock,
+			// are read at the deadline, which leaves a quarter of the timeout
+			// to read the classes and stylesheets
+			deadline := r.Timeout*3/4 - time.Since(start)
+			if deadline < 0 {
+				deadline = 0
+			}
+			return chromedp.Evaluate(
+				fmt.Sprintf(waitForIdleJS, idle, idle, deadline.Milliseconds()),
+				nil,
+				func(p *runtime.EvaluateParams) *runtime.EvaluateParams {
+					return p.WithAwaitPromise(true)
+				},
+			).Do(ctx)
+		}),
+		chromedp.Evaluate(classesJS, &classes),
+		chromedp.Evaluate(stylesheetsJS, &sheets),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("could not render %s: %w", page, err)
+	}
+
+	ret := &RenderedPage{Classes: classes}
+	name := func(n string) string {
+		if strings.HasPrefix(n, "#style-") {
+			return page + n
+		}
+		return fromBrowserURL(n, page)
+	}
+	state := newLoadState()
+	for _, sheet := range sheets {
+		if state.loaded[name(sheet.Name)] {
+			continue
+		}
+		if !sheet.Readable {
+			stylesheets, err := r.Loader.load(name(sheet.Name), page, "", state)
+			if err != nil {
+				return nil, err
+			}
+			ret.Stylesheets = append(ret.Stylesheets, stylesheets...)
+			continue
+		}
+
+		stylesheet := Stylesheet{
+			URL:     name(sheet.Name),
+			Page:    page,
+			Content: sheet.CSSText,
+		}
+		if sheet.ImportedFrom != "" {
+			stylesheet.ImportedFrom = name(sheet.ImportedFrom)
+		}
+		state.loaded[stylesheet.URL] = true
+		ret.Stylesheets = append(ret.Stylesheets, stylesheet)
+	}
+
+	return ret, nil
+}
+
+// toBrowserURL turns a local path into a file:// URL.
+func toBrowserURL(page string) (string, error) {
+	if isURL(page) {
+		return page, nil
+	}
+	abs, err := filepath.Abs(page)
+	if err != nil {
+		return "", err
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(abs)}).String(), nil
+}
+
+// fromBrowserURL turns a file:// URL back into a path, relative to the
+// working directory if page is a relative path.
+func fromBrowserURL(u string, page string) string {
+	parsed, err := url.Parse(u)
+	if err != nil || parsed.Scheme != "file" {
+		return u
+	}
+	path := filepath.FromSlash(parsed.Path)
+	if filepath.IsAbs(page) {
+		return path
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(wd, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+func renderFlags() []*parameters.ParameterDefinition {
+	return []*parameters.ParameterDefinition{
+		parameters.NewParameterDefinition(
+			"render",
+			parameters.ParameterTypeBool,
+			parameters.WithHelp("Load HTML pages in headless Chrome and use the live DOM and document.styleSheets, to take classes added by scripts into account."),
+			parameters.WithDefault(false),
+		),
+		parameters.NewParameterDefinition(
+			"render-idle",
+			parameters.ParameterTypeInteger,
+			parameters.WithHelp("Milliseconds the DOM has to stay unchanged before a rendered page is considered done."),
+			parameters.WithDefault(500),
+		),
+		parameters.NewParameterDefinition(
+			"render-timeout",
+			parameters.ParameterTypeInteger,
+			parameters.WithHelp("Maximum number of seconds spent rendering a page."),
+			parameters.WithDefault(30),
+		),
+		parameters.NewParameterDefinition(
+			"chrome-path",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Path to the Chrome executable (looked up in the usual locations by default)."),
+		),
+	}
+}
+
+// newRendererFromParameters returns nil if --render is not set.
+func newRendererFromParameters(ctx context.Context, ps map[string]interface{}, loader *StylesheetLoader) (*Renderer, error) {
+	if render, _ := ps["render"].(bool); !render {
+		return nil, nil
+	}
+	chromePath, _ := ps["chrome-path"].(string)
+
+	renderer, err := NewRenderer(ctx, chromePath, loader)
+	if err != nil {
+		return nil, err
+	}
+	if idle, ok := ps["render-idle"].(int); ok && idle > 0 {
+		renderer.IdleTime = time.Duration(idle) * time.Millisecond
+	}
+	if timeout, ok := ps["render-timeout"].(int); ok && timeout > 0 {
+		renderer.Timeout = time.Duration(timeout) * time.Second
+	}
+	log.Debug().Str("chrome-path", chromePath).Msg("Started headless Chrome")
+
+	return renderer, nil
+}
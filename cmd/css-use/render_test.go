@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// newTestRenderer starts Chrome from $CHROME_PATH or the usual locations,
+// and skips the test if there is none.
+func newTestRenderer(t *testing.T) *Renderer {
+	chromePath := os.Getenv("CHROME_PATH")
+	if chromePath == "" {
+		found := false
+		for _, name := range []string{"headless-shell", "chromium", "chromium-browser", "google-chrome", "chrome"} {
+			if _, err := exec.LookPath(name); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Skip("Chrome not found, set CHROME_PATH to run this test")
+		}
+	}
+
+	renderer, err := NewRenderer(context.Background(), chromePath, NewStylesheetLoader(""))
+	if err != nil {
+		t.Skipf("could not start Chrome: %v", err)
+	}
+	t.Cleanup(renderer.Close)
+	return renderer
+}
+
+func TestRenderer_ClassesAddedByScripts(t *testing.T) {
+	renderer := newTestRenderer(t)
+
+	page, err := renderer.Render("testdata/render/index.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := []string{"js-added", "late", "static"}; !reflect.DeepEqual(page.Classes, expected) {
+		t.Fatalf("expected classes %v, got %v", expected, page.Classes)
+	}
+
+	stylesheets := map[string][]string{}
+	for _, stylesheet := range page.Stylesheets {
+		rules, err := GetRules(stylesheet.Content)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stylesheets[stylesheet.URL] = getDefinedClasses(rules)
+	}
+	expected := map[string][]string{
+		"testdata/render/render.css":         {"js-added", "late"},
+		"testdata/render/index.html#style-1": {"static"},
+		"testdata/render/index.html#style-2": {"injected"},
+	}
+	if !reflect.DeepEqual(stylesheets, expected) {
+		t.Fatalf("expected stylesheets %v, got %v", expected, stylesheets)
+	}
+}
+
+func TestRenderer_PageThatNeverIdles(t *testing.T) {
+	renderer := newTestRenderer(t)
+	renderer.Timeout = 2 * time.Second
+
+	page, err := renderer.Render("testdata/render/clock.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	classes := map[string]bool{}
+	for _, class := range page.Classes {
+		classes[class] = true
+	}
+	if !classes["clock"] || !classes["ticking"] {
+		t.Fatalf("expected clock and ticking in %v", page.Classes)
+	}
+}
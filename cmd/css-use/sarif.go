@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The subset of SARIF 2.1.0 used to report unused classes, see
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	ColumnKind         string                           `json:"columnKind"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// sarifSourceRoot is the base of the relative paths of the files given to
+// css-use, which are relative to the working directory.
+const sarifSourceRoot = "%SRCROOT%"
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+// writeUnusedClassesSARIF writes the rows output by find-unused as a SARIF
+// log. Rows locate the class in their stylesheet column if set, or in their
+// file column.
+func writeUnusedClassesSARIF(w io.Writer, rows []types.Row) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name: "css-use",
+			Rules: []sarifRule{
+				{ID: "unused-class", ShortDescription: sarifMessage{Text: "CSS class defined but never used"}},
+				{ID: "possibly-unused-class", ShortDescription: sarifMessage{Text: "CSS class only used by classes built at runtime"}},
+			},
+		}},
+		ColumnKind: "unicodeCodePoints",
+		Results:    []sarifResult{},
+	}
+	if wd, err := os.Getwd(); err == nil {
+		run.OriginalURIBaseIDs = map[string]sarifArtifactLocation{
+			sarifSourceRoot: {URI: strings.TrimSuffix(fileURI(wd), "/") + "/"},
+		}
+	}
+
+	for _, row := range rows {
+		class, _ := row.Get("class")
+		result := sarifResult{
+			RuleID:  "unused-class",
+			Level:   "warning",
+			Message: sarifMessage{Text: fmt.Sprintf("CSS class .%s is defined but never used", class)},
+		}
+		if possiblyUsed, _ := row.Get("possibly_used"); possiblyUsed == true {
+			result.RuleID = "possibly-unused-class"
+			result.Level = "note"
+			result.Message.Text = fmt.Sprintf("CSS class .%s is only matched by classes built at runtime", class)
+		}
+
+		uri, _ := row.Get("stylesheet")
+		if uri_, _ := uri.(string); uri_ == "" {
+			uri, _ = row.Get("file")
+		}
+		uri_, _ := uri.(string)
+		// inline <style> blocks are positioned in their page
+		uri_ = strings.SplitN(uri_, "#", 2)[0]
+		artifact := sarifArtifactLocation{URI: uri_}
+		switch {
+		case isURL(uri_):
+		case filepath.IsAbs(uri_):
+			artifact.URI = fileURI(uri_)
+		default:
+			artifact.URI = (&url.URL{Path: filepath.ToSlash(uri_)}).String()
+			artifact.URIBaseID = sarifSourceRoot
+		}
+		location := sarifPhysicalLocation{ArtifactLocation: artifact}
+		line, _ := row.Get("line")
+		column, _ := row.Get("column")
+		if line_, ok := toInt(line); ok && line_ > 0 {
+			location.Region = &sarifRegion{StartLine: line_}
+			location.Region.StartColumn, _ = toInt(column)
+		}
+		result.Locations = []sarifLocation{{PhysicalLocation: location}}
+
+		if allUnused, ok := row.Get("all_unused"); ok {
+			result.Properties = map[string]interface{}{"allUnused": allUnused}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// fileURI returns the file:// URI of the absolute path p.
+func fileURI(p string) string {
+	p = filepath.ToSlash(p)
+	// windows paths like C:/x.css
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// toInt converts the numbers of rows and of JSON files, which are decoded
+// as float64, to int.
+func toInt(v interface{}) (int, bool) {
+	switch v := v.(type) {
+	case int:
+		return v, true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	}
+	return 0, false
+}
@@ -0,0 +1,557 @@
+package selector
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// legacyPseudoElements can be written with a single colon.
+var legacyPseudoElements = map[string]bool{
+	"before":       true,
+	"after":        true,
+	"first-line":   true,
+	"first-letter": true,
+}
+
+// selectorArguments are the functional pseudo-classes and pseudo-elements
+// taking a selector list. The arguments of :has() are relative selectors.
+var selectorArguments = map[string]bool{
+	"not":          true,
+	"is":           true,
+	"where":        true,
+	"has":          true,
+	"matches":      true,
+	"-webkit-any":  true,
+	"-moz-any":     true,
+	"host":         true,
+	"host-context": true,
+	"slotted":      true,
+}
+
+// Parse parses a comma separated list of selectors.
+func Parse(s string) (SelectorList, error) {
+	p := &parser{s: s}
+	l, err := p.selectorList(false)
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+	if !p.eof() {
+		return nil, p.errorf("unexpected %q", p.peek())
+	}
+	return l, nil
+}
+
+// MustParse is like Parse but panics on errors.
+func MustParse(s string) SelectorList {
+	l, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return l
+}
+
+type parser struct {
+	s   string
+	pos int
+}
+
+type Error struct {
+	Selector string
+	Offset   int
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("invalid selector %q at offset %d: %s", e.Selector, e.Offset, e.Message)
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return &Error{Selector: p.s, Offset: p.pos, Message: fmt.Sprintf(format, args...)}
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.s)
+}
+
+// peek returns the next rune, or 0 at the end of the input.
+func (p *parser) peek() rune {
+	if p.eof() {
+		return 0
+	}
+	r, _ := utf8.DecodeRuneInString(p.s[p.pos:])
+	return r
+}
+
+func (p *parser) peekAt(offset int) byte {
+	if p.pos+offset >= len(p.s) {
+		return 0
+	}
+	return p.s[p.pos+offset]
+}
+
+// skipWhitespace skips whitespace and comments, and returns true if there
+// was any.
+func (p *parser) skipWhitespace() bool {
+	start := p.pos
+	for !p.eof() {
+		switch {
+		case isWhitespace(p.s[p.pos]):
+			p.pos++
+		case strings.HasPrefix(p.s[p.pos:], "/*"):
+			end := strings.Index(p.s[p.pos+2:], "*/")
+			if end < 0 {
+				p.pos = len(p.s)
+			} else {
+				p.pos += end + 4
+			}
+		default:
+			return p.pos > start
+		}
+	}
+	return p.pos > start
+}
+
+func (p *parser) selectorList(relative bool) (SelectorList, error) {
+	var ret SelectorList
+	for {
+		p.skipWhitespace()
+		c, err := p.complex(relative)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, c)
+		p.skipWhitespace()
+		if p.peek() != ',' {
+			return ret, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) combinator() Combinator {
+	switch {
+	case strings.HasPrefix(p.s[p.pos:], "||"):
+		p.pos += 2
+		return Column
+	case p.peek() == '>':
+		p.pos++
+		return Child
+	case p.peek() == '+':
+		p.pos++
+		return NextSibling
+	case p.peek() == '~':
+		p.pos++
+		return SubsequentSibling
+	}
+	return None
+}
+
+// complex parses a complex selector. If relative is set, the selector can
+// start with a combinator, as in :has(> img).
+func (p *parser) complex(relative bool) (*Complex, error) {
+	ret := &Complex{}
+	combinator := None
+	if relative {
+		combinator = p.combinator()
+		p.skipWhitespace()
+	}
+
+	for {
+		compound, err := p.compound()
+		if err != nil {
+			return nil, err
+		}
+		ret.Parts = append(ret.Parts, &Part{Combinator: combinator, Compound: compound})
+
+		hadWhitespace := p.skipWhitespace()
+		if c := p.peek(); p.eof() || c == ',' || c == ')' {
+			return ret, nil
+		}
+		combinator = p.combinator()
+		if combinator == None {
+			if !hadWhitespace {
+				return nil, p.errorf("unexpected %q", p.peek())
+			}
+			combinator = Descendant
+		}
+		p.skipWhitespace()
+	}
+}
+
+func (p *parser) compound() (*Compound, error) {
+	ret := &Compound{}
+
+	switch c := p.peek(); {
+	case c == '*' || c == '|' || isNameStart(c) || c == '\\':
+		t, err := p.typeSelector()
+		if err != nil {
+			return nil, err
+		}
+		ret.Type = t
+	case c == '&':
+		p.pos++
+		ret.Type = "&"
+	}
+
+	for !p.eof() {
+		switch p.peek() {
+		case '#':
+			p.pos++
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			ret.Simples = append(ret.Simples, &ID{Name: name})
+		case '.':
+			p.pos++
+			name, err := p.ident()
+			if err != nil {
+				return nil, err
+			}
+			ret.Simples = append(ret.Simples, &Class{Name: name})
+		case '[':
+			a, err := p.attribute()
+			if err != nil {
+				return nil, err
+			}
+			ret.Simples = append(ret.Simples, a)
+		case ':':
+			s, err := p.pseudo()
+			if err != nil {
+				return nil, err
+			}
+			ret.Simples = append(ret.Simples, s)
+		case '&':
+			p.pos++
+			if ret.Type == "" && len(ret.Simples) == 0 {
+				ret.Type = "&"
+				continue
+			}
+			return nil, p.errorf("unexpected &")
+		default:
+			if ret.Type == "" && len(ret.Simples) == 0 {
+				if p.eof() {
+					return nil, p.errorf("empty selector")
+				}
+				return nil, p.errorf("unexpected %q", p.peek())
+			}
+			return ret, nil
+		}
+	}
+
+	if ret.Type == "" && len(ret.Simples) == 0 {
+		return nil, p.errorf("empty selector")
+	}
+	return ret, nil
+}
+
+// typeSelector parses an element name or *, with an optional namespace
+// prefix.
+func (p *parser) typeSelector() (string, error) {
+	part := func() (string, error) {
+		if p.peek() == '*' {
+			p.pos++
+			return "*", nil
+		}
+		name, err := p.ident()
+		if err != nil {
+			return "", err
+		}
+		return strings.ToLower(name), nil
+	}
+
+	ret := ""
+	if p.peek() != '|' {
+		var err error
+		ret, err = part()
+		if err != nil {
+			return "", err
+		}
+	}
+	if p.peek() == '|' && p.peekAt(1) != '|' && p.peekAt(1) != '=' {
+		p.pos++
+		name, err := part()
+		if err != nil {
+			return "", err
+		}
+		ret += "|" + name
+	}
+	return ret, nil
+}
+
+func (p *parser) attribute() (*Attribute, error) {
+	p.pos++ // [
+	p.skipWhitespace()
+
+	ret := &Attribute{}
+	name, err := p.typeSelector()
+	if err != nil {
+		return nil, err
+	}
+	ret.Name = name
+	p.skipWhitespace()
+
+	if p.peek() == ']' {
+		p.pos++
+		return ret, nil
+	}
+
+	switch {
+	case p.peek() == '=':
+		ret.Operator = "="
+		p.pos++
+	case strings.ContainsRune("~|^$*", p.peek()) && p.peekAt(1) == '=':
+		ret.Operator = p.s[p.pos : p.pos+2]
+		p.pos += 2
+	default:
+		return nil, p.errorf("unexpected %q in attribute selector", p.peek())
+	}
+	p.skipWhitespace()
+
+	if c := p.peek(); c == '"' || c == '\'' {
+		ret.Value, err = p.string()
+	} else {
+		ret.Value, err = p.ident()
+	}
+	if err != nil {
+		return nil, err
+	}
+	p.skipWhitespace()
+
+	if c := p.peek(); c == 'i' || c == 'I' || c == 's' || c == 'S' {
+		ret.Modifier = strings.ToLower(string(c))
+		p.pos++
+		p.skipWhitespace()
+	}
+	if p.peek() != ']' {
+		return nil, p.errorf("expected ] to close the attribute selector")
+	}
+	p.pos++
+	return ret, nil
+}
+
+func (p *parser) pseudo() (Simple, error) {
+	p.pos++ // :
+	isElement := false
+	if p.peek() == ':' {
+		isElement = true
+		p.pos++
+	}
+	name, err := p.ident()
+	if err != nil {
+		return nil, err
+	}
+	name = strings.ToLower(name)
+	if legacyPseudoElements[name] {
+		isElement = true
+	}
+
+	var argument string
+	var selectors SelectorList
+	isFunction := p.peek() == '('
+	if isFunction {
+		p.pos++
+		argument, selectors, err = p.pseudoArgument(name)
+		if err != nil {
+			return nil, err
+		}
+		p.skipWhitespace()
+		if p.peek() != ')' {
+			return nil, p.errorf("expected ) to close :%s(", name)
+		}
+		p.pos++
+	}
+
+	if isElement {
+		return &PseudoElement{Name: name, Argument: argument, Selectors: selectors, IsFunction: isFunction}, nil
+	}
+	return &PseudoClass{Name: name, Argument: argument, Selectors: selectors, IsFunction: isFunction}, nil
+}
+
+func (p *parser) pseudoArgument(name string) (string, SelectorList, error) {
+	if selectorArguments[name] {
+		p.skipWhitespace()
+		if name == "is" || name == "where" {
+			// :is() and :where() accept an empty list
+			if p.peek() == ')' {
+				return "", nil, nil
+			}
+		}
+		l, err := p.selectorList(name == "has")
+		return "", l, err
+	}
+
+	raw := strings.TrimSpace(p.balanced())
+	if name == "nth-child" || name == "nth-last-child" {
+		// An+B of S
+		if i := indexOfKeyword(raw, "of"); i >= 0 {
+			l, err := Parse(raw[i+2:])
+			if err != nil {
+				return "", nil, err
+			}
+			return strings.TrimSpace(raw[:i]), l, nil
+		}
+	}
+	return raw, nil, nil
+}
+
+// balanced reads up to the parenthesis closing the current function.
+func (p *parser) balanced() string {
+	start := p.pos
+	depth := 0
+	for !p.eof() {
+		switch p.s[p.pos] {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return p.s[start:p.pos]
+			}
+			depth--
+		case '\\':
+			p.pos++
+		case '"', '\'':
+			_, _ = p.string()
+			continue
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// indexOfKeyword returns the index of the whitespace separated keyword in s,
+// or -1.
+func indexOfKeyword(s string, keyword string) int {
+	fields := strings.Fields(s)
+	offset := 0
+	for _, field := range fields {
+		i := strings.Index(s[offset:], field) + offset
+		if strings.EqualFold(field, keyword) {
+			return i
+		}
+		offset = i + len(field)
+	}
+	return -1
+}
+
+// name parses the name of an id selector, which can start with a digit.
+func (p *parser) name() (string, error) {
+	sb := strings.Builder{}
+	for !p.eof() {
+		c := p.peek()
+		switch {
+		case c == '\\':
+			r, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(r)
+		case isNameChar(c):
+			sb.WriteRune(c)
+			p.pos += utf8.RuneLen(c)
+		default:
+			if sb.Len() == 0 {
+				return "", p.errorf("expected a name")
+			}
+			return sb.String(), nil
+		}
+	}
+	if sb.Len() == 0 {
+		return "", p.errorf("expected a name")
+	}
+	return sb.String(), nil
+}
+
+// ident parses an identifier, unescaping it.
+func (p *parser) ident() (string, error) {
+	start := p.pos
+	c := p.peek()
+	next := rune(p.peekAt(1))
+	switch {
+	case c == '-' && (next == '-' || isNameStart(next) || next == '\\'):
+	case c == '\\' || isNameStart(c):
+	default:
+		return "", p.errorf("expected an identifier")
+	}
+	name, err := p.name()
+	if err != nil {
+		p.pos = start
+		return "", err
+	}
+	return name, nil
+}
+
+// escape parses a backslash escape: up to 6 hex digits followed by an
+// optional whitespace, or any other character.
+func (p *parser) escape() (rune, error) {
+	p.pos++ // backslash
+	if p.eof() {
+		return 0, p.errorf("unterminated escape")
+	}
+	end := p.pos
+	for end < len(p.s) && end-p.pos < 6 && isHex(p.s[end]) {
+		end++
+	}
+	if end == p.pos {
+		r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+		p.pos += size
+		return r, nil
+	}
+	n, _ := strconv.ParseUint(p.s[p.pos:end], 16, 32)
+	p.pos = end
+	if !p.eof() && isWhitespace(p.s[p.pos]) {
+		p.pos++
+	}
+	if n == 0 || n > utf8.MaxRune || (n >= 0xd800 && n <= 0xdfff) {
+		return utf8.RuneError, nil
+	}
+	return rune(n), nil
+}
+
+func (p *parser) string() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	sb := strings.Builder{}
+	for !p.eof() {
+		c := p.s[p.pos]
+		switch c {
+		case quote:
+			p.pos++
+			return sb.String(), nil
+		case '\\':
+			if p.peekAt(1) == '\n' {
+				p.pos += 2
+				continue
+			}
+			r, err := p.escape()
+			if err != nil {
+				return "", err
+			}
+			sb.WriteRune(r)
+		default:
+			r, size := utf8.DecodeRuneInString(p.s[p.pos:])
+			sb.WriteRune(r)
+			p.pos += size
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func isWhitespace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isHex(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+func isNameStart(r rune) bool {
+	return r == '_' || r >= 0x80 || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z')
+}
+
+func isNameChar(r rune) bool {
+	return isNameStart(r) || r == '-' || (r >= '0' && r <= '9')
+}
@@ -0,0 +1,317 @@
+// Package selector parses CSS selectors (Selectors Level 4) into an AST and
+// computes their specificity.
+//
+// A SelectorList is a comma separated list of Complex selectors. A Complex
+// selector is a chain of Compound selectors joined by combinators, and a
+// Compound selector is an optional type selector followed by simple
+// selectors: ids, classes, attributes, pseudo-classes and pseudo-elements.
+// Pseudo-classes like :not(), :is(), :where(), :has() and :nth-child(An+B of S)
+// have their argument parsed as a nested SelectorList.
+package selector
+
+import (
+	"fmt"
+	"strings"
+)
+
+type SelectorList []*Complex
+
+// Complex is a chain of compound selectors, such as `nav > ul li.active`.
+type Complex struct {
+	Parts []*Part
+}
+
+// Part is a compound selector, together with the combinator joining it to
+// the previous part. The combinator of the first part is empty, except in
+// the relative selectors of :has(), such as `:has(> img)`.
+type Part struct {
+	Combinator Combinator
+	Compound   *Compound
+}
+
+type Combinator string
+
+const (
+	None              Combinator = ""
+	Descendant        Combinator = " "
+	Child             Combinator = ">"
+	NextSibling       Combinator = "+"
+	SubsequentSibling Combinator = "~"
+	Column            Combinator = "||"
+)
+
+// Compound is a sequence of simple selectors without combinators, such as
+// `a.button:hover`.
+type Compound struct {
+	// Type is the type selector (div, svg|rect), * for the universal
+	// selector, & for the nesting selector, or empty.
+	Type    string
+	Simples []Simple
+}
+
+// Simple is an *ID, *Class, *Attribute, *PseudoClass or *PseudoElement.
+type Simple interface {
+	String() string
+	Specificity() Specificity
+}
+
+type ID struct {
+	Name string
+}
+
+type Class struct {
+	Name string
+}
+
+type Attribute struct {
+	Name string
+	// Operator is one of =, ~=, |=, ^=, $= and *=, or empty if the selector
+	// only tests the presence of the attribute.
+	Operator string
+	Value    string
+	// Modifier is i or s, or empty.
+	Modifier string
+}
+
+type PseudoClass struct {
+	Name string
+	// Argument is the raw argument of functional pseudo-classes, such as
+	// 2n+1 for :nth-child(2n+1). It is empty for :not(), :is(), :where() and
+	// :has(), whose argument is in Selectors.
+	Argument string
+	// Selectors is the selector argument of :not(), :is(), :where(), :has(),
+	// :host() and the `of S` part of :nth-child() and :nth-last-child().
+	Selectors SelectorList
+	// IsFunction is set for functional pseudo-classes.
+	IsFunction bool
+}
+
+type PseudoElement struct {
+	Name       string
+	Argument   string
+	Selectors  SelectorList
+	IsFunction bool
+}
+
+// Specificity is the (A, B, C) specificity of a selector: the number of ids,
+// the number of classes, attributes and pseudo-classes, and the number of
+// type selectors and pseudo-elements.
+type Specificity struct {
+	A, B, C int
+}
+
+func (s Specificity) Add(o Specificity) Specificity {
+	return Specificity{s.A + o.A, s.B + o.B, s.C + o.C}
+}
+
+// Compare returns -1, 0 or 1 if s is less than, equal to or greater than o.
+func (s Specificity) Compare(o Specificity) int {
+	for _, d := range []int{s.A - o.A, s.B - o.B, s.C - o.C} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	return 0
+}
+
+func (s Specificity) String() string {
+	return fmt.Sprintf("%d,%d,%d", s.A, s.B, s.C)
+}
+
+func (i *ID) Specificity() Specificity        { return Specificity{A: 1} }
+func (c *Class) Specificity() Specificity     { return Specificity{B: 1} }
+func (a *Attribute) Specificity() Specificity { return Specificity{B: 1} }
+
+func (p *PseudoClass) Specificity() Specificity {
+	switch p.Name {
+	case "where":
+		return Specificity{}
+	case "is", "not", "has", "matches", "-webkit-any", "-moz-any":
+		return p.Selectors.Specificity()
+	case "nth-child", "nth-last-child", "host", "host-context":
+		return Specificity{B: 1}.Add(p.Selectors.Specificity())
+	}
+	return Specificity{B: 1}
+}
+
+func (p *PseudoElement) Specificity() Specificity {
+	return Specificity{C: 1}.Add(p.Selectors.Specificity())
+}
+
+// Specificity returns the specificity of the most specific selector of the
+// list, which is what :is() and :not() use.
+func (l SelectorList) Specificity() Specificity {
+	ret := Specificity{}
+	for _, c := range l {
+		if s := c.Specificity(); s.Compare(ret) > 0 {
+			ret = s
+		}
+	}
+	return ret
+}
+
+func (c *Complex) Specificity() Specificity {
+	ret := Specificity{}
+	for _, part := range c.Parts {
+		ret = ret.Add(part.Compound.Specificity())
+	}
+	return ret
+}
+
+func (c *Compound) Specificity() Specificity {
+	ret := Specificity{}
+	if c.Type != "" && c.Type != "*" && c.Type != "&" && !strings.HasSuffix(c.Type, "|*") {
+		ret.C++
+	}
+	for _, s := range c.Simples {
+		ret = ret.Add(s.Specificity())
+	}
+	return ret
+}
+
+// Classes returns the class names used anywhere in the list, including in
+// the arguments of pseudo-classes, without duplicates.
+func (l SelectorList) Classes() []string {
+	seen := map[string]bool{}
+	var ret []string
+	l.Walk(func(s Simple) {
+		if c, ok := s.(*Class); ok && !seen[c.Name] {
+			seen[c.Name] = true
+			ret = append(ret, c.Name)
+		}
+	})
+	return ret
+}
+
+// Classes returns the class names used anywhere in c, without duplicates.
+func (c *Complex) Classes() []string {
+	return SelectorList{c}.Classes()
+}
+
+// Walk calls f for every simple selector of the list, depth first.
+func (l SelectorList) Walk(f func(Simple)) {
+	for _, c := range l {
+		for _, part := range c.Parts {
+			for _, s := range part.Compound.Simples {
+				f(s)
+				switch s := s.(type) {
+				case *PseudoClass:
+					s.Selectors.Walk(f)
+				case *PseudoElement:
+					s.Selectors.Walk(f)
+				}
+			}
+		}
+	}
+}
+
+func (l SelectorList) String() string {
+	parts := make([]string, len(l))
+	for i, c := range l {
+		parts[i] = c.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (c *Complex) String() string {
+	sb := strings.Builder{}
+	for i, part := range c.Parts {
+		switch part.Combinator {
+		case None:
+		case Descendant:
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+		default:
+			if i > 0 {
+				sb.WriteString(" ")
+			}
+			sb.WriteString(string(part.Combinator))
+			sb.WriteString(" ")
+		}
+		sb.WriteString(part.Compound.String())
+	}
+	return sb.String()
+}
+
+func (c *Compound) String() string {
+	sb := strings.Builder{}
+	sb.WriteString(c.Type)
+	for _, s := range c.Simples {
+		sb.WriteString(s.String())
+	}
+	return sb.String()
+}
+
+func (i *ID) String() string {
+	return "#" + escapeIdent(i.Name)
+}
+
+func (c *Class) String() string {
+	return "." + escapeIdent(c.Name)
+}
+
+func (a *Attribute) String() string {
+	if a.Operator == "" {
+		return "[" + a.Name + "]"
+	}
+	ret := "[" + a.Name + a.Operator + quote(a.Value)
+	if a.Modifier != "" {
+		ret += " " + a.Modifier
+	}
+	return ret + "]"
+}
+
+func (p *PseudoClass) String() string {
+	return ":" + pseudoString(p.Name, p.IsFunction, p.Argument, p.Selectors)
+}
+
+func (p *PseudoElement) String() string {
+	return "::" + pseudoString(p.Name, p.IsFunction, p.Argument, p.Selectors)
+}
+
+func pseudoString(name string, isFunction bool, argument string, selectors SelectorList) string {
+	if !isFunction {
+		return name
+	}
+	switch {
+	case argument != "" && len(selectors) > 0:
+		return name + "(" + argument + " of " + selectors.String() + ")"
+	case len(selectors) > 0:
+		return name + "(" + selectors.String() + ")"
+	}
+	return name + "(" + argument + ")"
+}
+
+// escapeIdent escapes the characters of s that can't appear as is in an
+// identifier, such as the colon of the tailwind class sm:flex.
+func escapeIdent(s string) string {
+	sb := strings.Builder{}
+	for i, r := range s {
+		switch {
+		case r == '-' || r == '_' || r >= 0x80 ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z'):
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 || (i == 1 && s[0] == '-') {
+				// identifiers can't start with a digit
+				_, _ = fmt.Fprintf(&sb, "\\%x ", r)
+			} else {
+				sb.WriteRune(r)
+			}
+		case r < 0x20 || r == 0x7f:
+			_, _ = fmt.Fprintf(&sb, "\\%x ", r)
+		default:
+			sb.WriteRune('\\')
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\a `).Replace(s) + `"`
+}
@@ -0,0 +1,62 @@
+package selector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		selector    string
+		classes     []string
+		specificity []string
+		str         string
+	}{
+		{".a", []string{"a"}, []string{"0,1,0"}, ".a"},
+		{"ul li.active > a:hover", []string{"active"}, []string{"0,2,3"}, "ul li.active > a:hover"},
+		{".a+.b~.c", []string{"a", "b", "c"}, []string{"0,3,0"}, ".a + .b ~ .c"},
+		{"#nav .item, .x", []string{"item", "x"}, []string{"1,1,0", "0,1,0"}, "#nav .item, .x"},
+		{`a[href$=".pdf" i]::after`, nil, []string{"0,1,2"}, `a[href$=".pdf" i]::after`},
+		{"p:before", nil, []string{"0,0,2"}, "p::before"},
+		{".btn:not(.disabled, #x)", []string{"btn", "disabled"}, []string{"1,1,0"}, ".btn:not(.disabled, #x)"},
+		{":is(.a, .b .c) .d", []string{"a", "b", "c", "d"}, []string{"0,3,0"}, ":is(.a, .b .c) .d"},
+		{":where(.a, #b) .c", []string{"a", "c"}, []string{"0,1,0"}, ":where(.a, #b) .c"},
+		{".card:has(> img.cover)", []string{"card", "cover"}, []string{"0,2,1"}, ".card:has(> img.cover)"},
+		{"li:nth-child(2n + 1 of .x)", []string{"x"}, []string{"0,2,1"}, "li:nth-child(2n + 1 of .x)"},
+		{"li:nth-child(odd)", nil, []string{"0,1,1"}, "li:nth-child(odd)"},
+		{`.sm\:p-4, .w-1\/2, .\31 0`, []string{"sm:p-4", "w-1/2", "10"}, []string{"0,1,0", "0,1,0", "0,1,0"}, `.sm\:p-4, .w-1\/2, .\31 0`},
+		{"*|* > svg|rect", nil, []string{"0,0,1"}, "*|* > svg|rect"},
+		{"a /* comment */ b", nil, []string{"0,0,2"}, "a b"},
+		{"& .child", []string{"child"}, []string{"0,1,0"}, "& .child"},
+	} {
+		l, err := Parse(tc.selector)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.selector, err)
+			continue
+		}
+		if classes := l.Classes(); !reflect.DeepEqual(classes, tc.classes) {
+			t.Errorf("%s: expected classes %v, got %v", tc.selector, tc.classes, classes)
+		}
+		specificity := []string{}
+		for _, c := range l {
+			specificity = append(specificity, c.Specificity().String())
+		}
+		if !reflect.DeepEqual(specificity, tc.specificity) {
+			t.Errorf("%s: expected specificity %v, got %v", tc.selector, tc.specificity, specificity)
+		}
+		if s := l.String(); s != tc.str {
+			t.Errorf("%s: expected %s, got %s", tc.selector, tc.str, s)
+		}
+		if _, err := Parse(l.String()); err != nil {
+			t.Errorf("%s: could not parse %s back: %v", tc.selector, l.String(), err)
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	for _, s := range []string{"", ".", "a >", "a[href", ".a,", ":not(.a", "a!b"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("%q: expected an error", s)
+		}
+	}
+}
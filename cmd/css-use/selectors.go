@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/go-go-labs/cmd/css-use/selector"
+)
+
+type SelectorsCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewSelectorsCommand() (*SelectorsCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, fmt.Errorf("could not create Glazed parameter layer: %w", err)
+	}
+
+	return &SelectorsCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"selectors",
+			cmds.WithShort("List the selectors of CSS files or HTML pages with their specificity."),
+			cmds.WithLong("List every selector of the stylesheets of CSS files or HTML pages, "+
+				"one row per selector of a selector list, with its specificity (ids, classes, types) "+
+				"and the classes it references."),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"document-root",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Directory that root-relative stylesheet URLs of local HTML files are resolved against (defaults to the directory of the file)."),
+				),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"files",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("List of CSS or HTML files (or URLs)."),
+					parameters.WithDefault([]string{}),
+				),
+			),
+			cmds.WithLayers(glazedParameterLayer),
+		),
+	}, nil
+}
+
+func (c *SelectorsCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	documentRoot, _ := ps["document-root"].(string)
+	urls := ps["files"].([]string)
+
+	loader := NewStylesheetLoader(documentRoot)
+
+	for _, url := range urls {
+		reader, err := ReaderUrlOrFile(url)
+		if err != nil {
+			return err
+		}
+		stylesheets, err := loader.Load(url, reader)
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+
+		for _, stylesheet := range stylesheets {
+			if err := outputSelectors(ctx, gp, url, stylesheet); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func outputSelectors(ctx context.Context, gp middlewares.Processor, path string, stylesheet Stylesheet) error {
+	for p := parseCSS(stylesheet.Content).Rulesets.Oldest(); p != nil; p = p.Next() {
+		ruleset := p.Value
+		if ruleset.Selector == "" {
+			continue
+		}
+
+		l, err := selector.Parse(ruleset.Selector)
+		if err != nil {
+			row := types.NewRow(
+				types.MRP("file", path),
+				types.MRP("stylesheet", stylesheet.URL),
+				types.MRP("at_rule", ruleset.AtRule),
+				types.MRP("selector", ruleset.Selector),
+				types.MRP("error", err.Error()),
+			)
+			if err := gp.AddRow(ctx, row); err != nil {
+				return err
+			}
+			continue
+		}
+
+		for _, complex_ := range l {
+			specificity := complex_.Specificity()
+			row := types.NewRow(
+				types.MRP("file", path),
+				types.MRP("stylesheet", stylesheet.URL),
+				types.MRP("at_rule", ruleset.AtRule),
+				types.MRP("selector", complex_.String()),
+				types.MRP("specificity", specificity.String()),
+				types.MRP("ids", specificity.A),
+				types.MRP("classes", specificity.B),
+				types.MRP("types", specificity.C),
+				types.MRP("class_names", complex_.Classes()),
+			)
+			if err := gp.AddRow(ctx, row); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
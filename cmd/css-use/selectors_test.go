@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestSelectorsCommand(t *testing.T) {
+	cmd, err := NewSelectorsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, map[string]interface{}{
+		"files": []string{"testdata/selectors.css"},
+	}, gp)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := [][]interface{}{}
+	for _, row := range gp.rows {
+		selector, _ := row.Get("selector")
+		specificity, _ := row.Get("specificity")
+		atRule, _ := row.Get("at_rule")
+		got = append(got, []interface{}{selector, specificity, atRule})
+	}
+	expected := [][]interface{}{
+		{".a > .b", "0,2,0", ""},
+		{".c .d:not(.e, .f)", "0,3,0", ""},
+		{`.sm\:p-4`, "0,1,0", "@media (max-width: 10px)"},
+		{`a[href="x y"]`, "0,1,1", "@media (max-width: 10px)"},
+		{".card:has(> img) + .x ~ .y", "0,3,1", ""},
+		{"#main .btn:hover::after", "1,2,1", ""},
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected\n%v\ngot\n%v", expected, got)
+	}
+}
+
+func TestGetDefinedClasses_SelectorLists(t *testing.T) {
+	rules, err := GetRules(`.a > .b, .c .d:not(.e, .f) { color: red } .sm\:p-4 + [class~="x"] {}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"a", "b", "c", "d", "e", "f", "sm:p-4"}
+	if got := getDefinedClasses(rules); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-go-golems/go-go-labs/pkg/csstree"
+	"github.com/rs/zerolog/log"
+	"github.com/tdewolff/parse/v2/css"
+	"golang.org/x/net/html"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// Stylesheet is the CSS of a <style> block, a <link rel="stylesheet"> or an
+// @import, together with where it was included from.
+type Stylesheet struct {
+	// URL is the URL or path of the stylesheet. Inline <style> blocks use the
+	// page URL followed by #style-<n>.
+	URL string
+	// Page is the HTML page that includes the stylesheet, directly or through
+	// @import. It is empty for stylesheets passed on the command line.
+	Page string
+	// ImportedFrom is the stylesheet containing the @import, if any.
+	ImportedFrom string
+	Content      string
+	// Line and Column are the position of the content of inline <style>
+	// blocks in the page, and 0 for stylesheets that are files of their own.
+	Line   int
+	Column int
+}
+
+// Position converts a line and column of the content of the stylesheet to
+// a position in the file containing it, the page for inline <style> blocks.
+func (s Stylesheet) Position(line int, column int) (int, int) {
+	if s.Line == 0 {
+		return line, column
+	}
+	if line == 1 {
+		return s.Line, s.Column + column - 1
+	}
+	return s.Line + line - 1, column
+}
+
+// StylesheetLoader collects all the stylesheets that apply to a page or a CSS
+// file, following <link rel="stylesheet"> and @import.
+//
+// Stylesheets are fetched with ReaderUrlOrFile, relative to the URL or path
+// of the including page or stylesheet, and cached so that a stylesheet shared
+// by several pages is only fetched once. Within a page, a stylesheet included
+// several times (e.g. imported by two stylesheets) is only returned once.
+type StylesheetLoader struct {
+	// DocumentRoot is the directory root-relative URLs (/css/site.css) are
+	// resolved against when the including page is a local file. If empty,
+	// the directory of the page is used.
+	DocumentRoot string
+
+	cache map[string]string
+}
+
+func NewStylesheetLoader(documentRoot string) *StylesheetLoader {
+	return &StylesheetLoader{
+		DocumentRoot: documentRoot,
+		cache:        map[string]string{},
+	}
+}
+
+// LoadHTML returns the inline, linked and imported stylesheets of an HTML
+// page, in document order. Imported stylesheets come before the stylesheet
+// importing them.
+func (l *StylesheetLoader) LoadHTML(page string, reader io.Reader) ([]Stylesheet, error) {
+	b, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := html.Parse(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	// the parser doesn't keep track of positions, the tokenizer is used to
+	// find where the <style> blocks are
+	pageContent := string(b)
+	styleBlocks := getStyleBlocks(pageContent)
+	lines := newLineIndex(pageContent)
+
+	var ret []Stylesheet
+	state := newLoadState()
+	styleCount := 0
+	var f func(*html.Node) error
+	f = func(n *html.Node) error {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "style":
+				styleCount++
+				content := ""
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if c.Type == html.TextNode {
+						content += c.Data
+					}
+				}
+				stylesheet := Stylesheet{
+					URL:     fmt.Sprintf("%s#style-%d", page, styleCount),
+					Page:    page,
+					Content: content,
+				}
+				if styleCount <= len(styleBlocks) && styleBlocks[styleCount-1].Text == content {
+					stylesheet.Line, stylesheet.Column = lines.position(pageContent, styleBlocks[styleCount-1].Offset)
+				}
+				stylesheets, err := l.withImports(stylesheet, page, state)
+				if err != nil {
+					return err
+				}
+				ret = append(ret, stylesheets...)
+
+			case n.Data == "link" && isStylesheetLink(n):
+				href := getAttr(n, "href")
+				if href == "" {
+					break
+				}
+				stylesheets, err := l.load(l.resolve(page, href), page, "", state)
+				if err != nil {
+					return err
+				}
+				ret = append(ret, stylesheets...)
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := f(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := f(doc); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+// Load returns the stylesheets of the CSS file or HTML page read from reader.
+// Files ending with .css are parsed as CSS.
+func (l *StylesheetLoader) Load(url string, reader io.Reader) ([]Stylesheet, error) {
+	if !strings.HasSuffix(url, ".css") {
+		return l.LoadHTML(url, reader)
+	}
+
+	cssContent, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSS from %s: %w", url, err)
+	}
+	return l.withImports(Stylesheet{
+		URL:     url,
+		Content: string(cssContent),
+	}, url, newLoadState())
+}
+
+// Forget removes url from the cache, so that it is fetched again the next
+// time it is loaded.
+func (l *StylesheetLoader) Forget(url string) {
+	delete(l.cache, url)
+}
+
+// LoadCSS returns a stylesheet and the stylesheets it imports.
+func (l *StylesheetLoader) LoadCSS(url string) ([]Stylesheet, error) {
+	return l.load(url, "", "", newLoadState())
+}
+
+// loadState is the state of a single Load, LoadHTML or LoadCSS call.
+type loadState struct {
+	// visiting holds the stylesheets on the current @import chain, to break
+	// cycles.
+	visiting map[string]bool
+	// loaded holds the stylesheets that were already returned.
+	loaded map[string]bool
+}
+
+func newLoadState() *loadState {
+	return &loadState{
+		visiting: map[string]bool{},
+		loaded:   map[string]bool{},
+	}
+}
+
+// load fetches the stylesheet at url and follows its imports. Stylesheets
+// that can't be fetched are logged and skipped, as are stylesheets that were
+// already loaded.
+func (l *StylesheetLoader) load(url string, page string, importedFrom string, state *loadState) ([]Stylesheet, error) {
+	if state.visiting[url] {
+		log.Warn().Str("stylesheet", url).Str("imported-from", importedFrom).Msg("Skipping @import cycle")
+		return nil, nil
+	}
+	if state.loaded[url] {
+		log.Debug().Str("stylesheet", url).Str("imported-from", importedFrom).Msg("Skipping stylesheet included twice")
+		return nil, nil
+	}
+
+	content, ok := l.cache[url]
+	if !ok {
+		reader, err := ReaderUrlOrFile(url)
+		if err != nil {
+			log.Warn().Err(err).Str("stylesheet", url).Str("page", page).Msg("Could not load stylesheet")
+			return nil, nil
+		}
+		b, err := io.ReadAll(reader)
+		_ = reader.Close()
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSS from %s: %w", url, err)
+		}
+		content = string(b)
+		l.cache[url] = content
+	}
+
+	return l.withImports(Stylesheet{
+		URL:          url,
+		Page:         page,
+		ImportedFrom: importedFrom,
+		Content:      content,
+	}, url, state)
+}
+
+// withImports returns the stylesheets imported by stylesheet, followed by
+// stylesheet itself. Imports are resolved relative to base.
+func (l *StylesheetLoader) withImports(stylesheet Stylesheet, base string, state *loadState) ([]Stylesheet, error) {
+	state.loaded[stylesheet.URL] = true
+	state.visiting[stylesheet.URL] = true
+	defer delete(state.visiting, stylesheet.URL)
+
+	var ret []Stylesheet
+	for _, import_ := range getImports(stylesheet.Content) {
+		imported, err := l.load(l.resolve(base, import_), stylesheet.Page, stylesheet.URL, state)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, imported...)
+	}
+
+	return append(ret, stylesheet), nil
+}
+
+// resolve resolves ref relative to base, which is either a URL or a file path.
+func (l *StylesheetLoader) resolve(base string, ref string) string {
+	refURL, err := url.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	if refURL.IsAbs() {
+		return ref
+	}
+
+	if isURL(base) {
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return ref
+		}
+		return baseURL.ResolveReference(refURL).String()
+	}
+
+	// local files have no query strings or fragments (style.css?v=3)
+	p := refURL.Path
+	if strings.HasPrefix(p, "/") {
+		root := l.DocumentRoot
+		if root == "" {
+			root = filepath.Dir(strings.SplitN(base, "#", 2)[0])
+		}
+		return filepath.Join(root, filepath.FromSlash(p))
+	}
+	return filepath.Join(filepath.Dir(strings.SplitN(base, "#", 2)[0]), filepath.FromSlash(p))
+}
+
+// getImports returns the URLs of the @import rules of a stylesheet.
+func getImports(cssContent string) []string {
+	var imports []string
+	for _, n := range csstree.Parse(cssContent).Children {
+		if n.Type != csstree.TypeAtRule || strings.ToLower(n.Name) != "import" {
+			continue
+		}
+
+		for _, val := range tokenize(n.Prelude) {
+			if val.TokenType == css.URLToken {
+				imports = append(imports, unquoteURL(string(val.Data)))
+				break
+			}
+			if val.TokenType == css.StringToken {
+				imports = append(imports, unquote(string(val.Data)))
+				break
+			}
+		}
+	}
+	return imports
+}
+
+// unquoteURL turns url(foo.css), url('foo.css') and url("foo.css") into foo.css.
+func unquoteURL(s string) string {
+	s = strings.TrimSuffix(strings.TrimPrefix(s, "url("), ")")
+	return unquote(strings.TrimSpace(s))
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
+
+func isStylesheetLink(n *html.Node) bool {
+	rels := strings.Fields(strings.ToLower(getAttr(n, "rel")))
+	isStylesheet := false
+	for _, rel := range rels {
+		if rel == "alternate" {
+			return false
+		}
+		if rel == "stylesheet" {
+			isStylesheet = true
+		}
+	}
+	return isStylesheet
+}
+
+func getAttr(n *html.Node, name string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+func isURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
@@ -0,0 +1,10 @@
+export function Button({ size, active }: Props) {
+    return (
+        <button
+            className={cx('btn', `btn-${size}`, { 'btn-active': active, disabled: !active })}
+            onClick={(e) => e.currentTarget.classList.add('clicked', 'icon-' + name)}
+        >
+            <span className="label">{label}</span>
+        </button>
+    );
+}
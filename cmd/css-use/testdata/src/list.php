@@ -0,0 +1,3 @@
+<ul class="list <?php if ($compact): ?>list-compact<?php endif; ?>">
+    <li class="item item-<?= $type ?>">x</li>
+</ul>
@@ -8,10 +8,7 @@ import (
 	"github.com/go-go-golems/glazed/pkg/middlewares"
 	"github.com/go-go-golems/glazed/pkg/settings"
 	"github.com/go-go-golems/glazed/pkg/types"
-	"sort"
-	"strings"
-
-	"github.com/PuerkitoBio/goquery"
+	"os"
 )
 
 type UsedCommand struct {
@@ -28,14 +25,41 @@ func NewUsedCommand() (*UsedCommand, error) {
 		CommandDescription: cmds.NewCommandDescription(
 			"used",
 			cmds.WithShort("Parses an HTML page and lists all CSS classes used in it."),
+			cmds.WithLong("Lists the CSS classes used in HTML pages, and in template and component source files.\n\n"+
+				"URLs and .html files are parsed as HTML, with the line and column of the first occurrence "+
+				"of each class. Other files, and all the files of a directory "+
+				"with one of the --extensions, are scanned for class=\"...\" attributes, className "+
+				"string literals, Vue :class bindings and classList calls, with the line and column of each class. "+
+				"Classes built at runtime, such as btn-{{ .Size }} or 'btn-' + size, "+
+				"are output as a glob (btn-*) and flagged as uncertain. Entirely dynamic values, such as "+
+				"class=\"{{ .Classes }}\" or :class=\"classes\", are output as a * flagged as dynamic, "+
+				"which find-unused and prune ignore."),
 			cmds.WithArguments(
 				parameters.NewParameterDefinition(
 					"files",
 					parameters.ParameterTypeStringList,
-					parameters.WithHelp("List of URLs to parse for CSS classes."),
+					parameters.WithHelp("List of URLs, files or directories to parse for CSS classes."),
 					parameters.WithDefault([]string{}),
 				),
 			),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"extensions",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Extensions of the source files scanned in directories."),
+					parameters.WithDefault([]string{
+						".html", ".htm", ".tmpl", ".gohtml", ".tpl",
+						".js", ".jsx", ".ts", ".tsx", ".vue", ".php",
+					}),
+				),
+				parameters.NewParameterDefinition(
+					"exclude-dirs",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Names of the directories skipped when scanning a directory (can be glob)."),
+					parameters.WithDefault([]string{"node_modules", ".git", "vendor"}),
+				),
+			),
+			cmds.WithFlags(renderFlags()...),
 			cmds.WithLayers(glazedLayer),
 		),
 	}, nil
@@ -48,40 +72,64 @@ func (c *UsedCommand) Run(
 	gp middlewares.Processor,
 ) error {
 	files := ps["files"].([]string)
+	extensions, _ := ps["extensions"].([]string)
+	excludeDirs, _ := ps["exclude-dirs"].([]string)
 
-	for _, url := range files {
-		reader, err2 := ReaderUrlOrFile(url)
-		if err2 != nil {
-			return err2
-		}
-		defer func() {
-			_ = reader.Close()
-		}()
+	renderer, err := newRendererFromParameters(ctx, ps, NewStylesheetLoader(""))
+	if err != nil {
+		return err
+	}
+	if renderer != nil {
+		defer renderer.Close()
+	}
 
-		doc, err := goquery.NewDocumentFromReader(reader)
-		if err != nil {
-			return err
+	for _, url := range files {
+		if !isURL(url) {
+			fi, err := os.Stat(url)
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() {
+				sourceFiles, err := findSourceFiles(url, extensions, excludeDirs)
+				if err != nil {
+					return err
+				}
+				for _, file := range sourceFiles {
+					if err := outputSourceClasses(ctx, gp, file); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if !isHTMLFile(url) {
+				if err := outputSourceClasses(ctx, gp, url); err != nil {
+					return err
+				}
+				continue
+			}
 		}
 
-		classesMap := make(map[string]bool)
-		doc.Find("*").Each(func(index int, element *goquery.Selection) {
-			class, exists := element.Attr("class")
-			if exists {
-				for _, cls := range strings.Fields(class) {
-					classesMap[cls] = true
-				}
+		if renderer != nil {
+			page, err := renderer.Render(url)
+			if err != nil {
+				return err
 			}
-		})
+			if err := outputUsedClasses(ctx, gp, url, page.Classes); err != nil {
+				return err
+			}
+			continue
+		}
 
-		alphabeticalKeys := make([]string, 0, len(classesMap))
-		for key := range classesMap {
-			alphabeticalKeys = append(alphabeticalKeys, key)
+		content, err := readAll(url)
+		if err != nil {
+			return err
 		}
-		sort.Strings(alphabeticalKeys)
-		for _, key := range alphabeticalKeys {
+		for _, usage := range getHTMLClassUsages(url, string(content)) {
 			row := types.NewRow(
-				types.MRP("class", key),
-				types.MRP("file", url),
+				types.MRP("class", usage.Class),
+				types.MRP("file", usage.File),
+				types.MRP("line", usage.Line),
+				types.MRP("column", usage.Column),
 			)
 			if err := gp.AddRow(ctx, row); err != nil {
 				return err
@@ -91,3 +139,38 @@ func (c *UsedCommand) Run(
 
 	return nil
 }
+
+func outputSourceClasses(ctx context.Context, gp middlewares.Processor, file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	for _, usage := range ExtractClassUsages(file, string(content)) {
+		row := types.NewRow(
+			types.MRP("class", usage.Class),
+			types.MRP("file", usage.File),
+			types.MRP("line", usage.Line),
+			types.MRP("column", usage.Column),
+			types.MRP("uncertain", usage.Uncertain),
+			types.MRP("dynamic", usage.Dynamic),
+			types.MRP("source", usage.Source),
+		)
+		if err := gp.AddRow(ctx, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func outputUsedClasses(ctx context.Context, gp middlewares.Processor, url string, classes []string) error {
+	for _, key := range classes {
+		row := types.NewRow(
+			types.MRP("class", key),
+			types.MRP("file", url),
+		)
+		if err := gp.AddRow(ctx, row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
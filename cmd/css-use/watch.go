@@ -0,0 +1,745 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/rs/zerolog/log"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefinedClass is a class defined in a stylesheet.
+type DefinedClass struct {
+	Class      string `json:"class"`
+	Stylesheet string `json:"stylesheet"`
+	Page       string `json:"page,omitempty"`
+	Line       int    `json:"line"`
+	Column     int    `json:"column"`
+}
+
+// WatchReport is the state of a Workspace, as served by watch --listen.
+type WatchReport struct {
+	Used    []ClassUsage   `json:"used"`
+	Defined []DefinedClass `json:"defined"`
+	// Unused are the defined classes that are not used, nor matched by an
+	// uncertain used class, like find-unused reports them.
+	Unused  []DefinedClass `json:"unused"`
+	Updated time.Time      `json:"updated"`
+}
+
+// ClassChange is a class that became unused, or used again, after an update.
+type ClassChange struct {
+	Class  string
+	Unused bool
+	// Location is where the class is defined if it became unused, and where
+	// it is used otherwise, as file:line:column.
+	Location string
+}
+
+type inputRole int
+
+const (
+	rolePage inputRole = iota
+	roleSource
+	roleStylesheet
+)
+
+type fileStat struct {
+	modTime time.Time
+	size    int64
+}
+
+// Workspace keeps the classes used and defined by a set of inputs in memory,
+// and re-parses the files that change.
+//
+// Inputs are handled like used and defined do: HTML pages are parsed for
+// classes and stylesheets, CSS files for classes, and other files are scanned
+// as template and component sources. The files of directories, including the
+// ones created while watching, are handled the same way.
+type Workspace struct {
+	Extensions  []string
+	ExcludeDirs []string
+	Loader      *StylesheetLoader
+
+	mu     sync.Mutex
+	inputs []string
+	// roles are the roles of the local files parsed so far
+	roles map[string]inputRole
+	stats map[string]fileStat
+	used  map[string][]ClassUsage
+	// roots are the stylesheets pages and CSS inputs include directly
+	roots   map[string][]string
+	imports map[string][]string
+	defined map[string][]DefinedClass
+	report  *WatchReport
+}
+
+func NewWorkspace(loader *StylesheetLoader, extensions []string, excludeDirs []string) *Workspace {
+	return &Workspace{
+		Extensions:  extensions,
+		ExcludeDirs: excludeDirs,
+		Loader:      loader,
+		roles:       map[string]inputRole{},
+		stats:       map[string]fileStat{},
+		used:        map[string][]ClassUsage{},
+		roots:       map[string][]string{},
+		imports:     map[string][]string{},
+		defined:     map[string][]DefinedClass{},
+	}
+}
+
+// Load parses the inputs.
+func (ws *Workspace) Load(inputs []string) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	ws.inputs = inputs
+	for _, input := range inputs {
+		if isURL(input) {
+			ws.update(input, rolePage)
+			continue
+		}
+		fi, err := os.Stat(input)
+		if err != nil {
+			return err
+		}
+		if !fi.IsDir() {
+			ws.update(input, inputRoleOf(input))
+			continue
+		}
+		files, err := findSourceFiles(input, ws.Extensions, ws.ExcludeDirs)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			ws.update(file, inputRoleOf(file))
+		}
+	}
+	ws.report = ws.buildReport()
+	return nil
+}
+
+func inputRoleOf(file string) inputRole {
+	switch {
+	case strings.HasSuffix(file, ".css"):
+		return roleStylesheet
+	case isHTMLFile(file):
+		return rolePage
+	}
+	return roleSource
+}
+
+// Scan returns the local files that changed, were created or were deleted
+// since they were last parsed.
+func (ws *Workspace) Scan() []string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	current := map[string]bool{}
+	for file := range ws.roles {
+		current[file] = true
+	}
+	for _, input := range ws.inputs {
+		if isURL(input) {
+			continue
+		}
+		if fi, err := os.Stat(input); err == nil && fi.IsDir() {
+			files, err := findSourceFiles(input, ws.Extensions, ws.ExcludeDirs)
+			if err != nil {
+				log.Warn().Err(err).Str("directory", input).Msg("Could not scan directory")
+				continue
+			}
+			for _, file := range files {
+				current[file] = true
+			}
+		}
+	}
+
+	var ret []string
+	for file := range current {
+		if isURL(file) {
+			continue
+		}
+		stat, known := ws.stats[file]
+		fi, err := os.Stat(file)
+		switch {
+		case err != nil:
+			if known {
+				ret = append(ret, file)
+			}
+		case !known || !fi.ModTime().Equal(stat.modTime) || fi.Size() != stat.size:
+			ret = append(ret, file)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Update re-parses files, and returns the classes that became unused or used
+// again.
+func (ws *Workspace) Update(files []string) []ClassChange {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	for _, file := range files {
+		role, ok := ws.roles[file]
+		if !ok {
+			role = inputRoleOf(file)
+		}
+		ws.update(file, role)
+	}
+
+	previous := ws.report
+	ws.report = ws.buildReport()
+	return diffReports(previous, ws.report)
+}
+
+// FileCount returns the number of local files parsed.
+func (ws *Workspace) FileCount() int {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return len(ws.stats)
+}
+
+// Report returns the current report.
+func (ws *Workspace) Report() *WatchReport {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.report
+}
+
+// WatchedDirectories returns the directories containing the local files of the
+// workspace, and the input directories and their subdirectories.
+func (ws *Workspace) WatchedDirectories() []string {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	dirs := map[string]bool{}
+	for file := range ws.roles {
+		if !isURL(file) {
+			dirs[filepath.Dir(file)] = true
+		}
+	}
+	for _, input := range ws.inputs {
+		if fi, err := os.Stat(input); err != nil || !fi.IsDir() {
+			continue
+		}
+		_ = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || !d.IsDir() {
+				return nil
+			}
+			if path != input && containsGlob(ws.ExcludeDirs, d.Name()) {
+				return filepath.SkipDir
+			}
+			dirs[path] = true
+			return nil
+		})
+	}
+
+	ret := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		ret = append(ret, dir)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// update parses file again, or forgets it if it was deleted.
+func (ws *Workspace) update(file string, role inputRole) {
+	ws.roles[file] = role
+	if !isURL(file) {
+		fi, err := os.Stat(file)
+		if err != nil {
+			ws.remove(file)
+			return
+		}
+		ws.stats[file] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+	}
+
+	switch role {
+	case rolePage:
+		content, err := readAll(file)
+		if err != nil {
+			log.Warn().Err(err).Str("page", file).Msg("Could not load page")
+			ws.remove(file)
+			return
+		}
+		ws.used[file] = getHTMLClassUsages(file, string(content))
+		stylesheets, err := ws.Loader.LoadHTML(file, strings.NewReader(string(content)))
+		if err != nil {
+			log.Warn().Err(err).Str("page", file).Msg("Could not parse page")
+			return
+		}
+		ws.roots[file] = ws.addStylesheets(stylesheets, file)
+
+	case roleStylesheet:
+		ws.Loader.Forget(file)
+		stylesheets, err := ws.Loader.LoadCSS(file)
+		if err != nil {
+			log.Warn().Err(err).Str("stylesheet", file).Msg("Could not load stylesheet")
+			return
+		}
+		roots := ws.addStylesheets(stylesheets, file)
+		if ws.isInput(file) {
+			ws.roots[file] = roots
+		}
+
+	case roleSource:
+		content, err := os.ReadFile(file)
+		if err != nil {
+			ws.remove(file)
+			return
+		}
+		ws.used[file] = ExtractClassUsages(file, string(content))
+	}
+}
+
+// addStylesheets records the classes and imports of stylesheets, which were
+// loaded because changed changed. Stylesheets parsed before are only parsed
+// again if they are changed itself or one of its inline <style> blocks. It
+// returns the stylesheets that are not imported.
+func (ws *Workspace) addStylesheets(stylesheets []Stylesheet, changed string) []string {
+	var roots []string
+	for _, stylesheet := range stylesheets {
+		if stylesheet.ImportedFrom == "" {
+			roots = append(roots, stylesheet.URL)
+		}
+	}
+	for _, stylesheet := range stylesheets {
+		isInline := strings.HasPrefix(stylesheet.URL, changed+"#")
+		if _, ok := ws.defined[stylesheet.URL]; ok && stylesheet.URL != changed && !isInline {
+			continue
+		}
+		// the imports of the stylesheet are recorded again below
+		ws.imports[stylesheet.URL] = nil
+		ws.defined[stylesheet.URL] = getDefinedClassPositions(stylesheet)
+		if !isURL(stylesheet.URL) && !isInline {
+			if _, ok := ws.roles[stylesheet.URL]; !ok {
+				ws.roles[stylesheet.URL] = roleStylesheet
+				if fi, err := os.Stat(stylesheet.URL); err == nil {
+					ws.stats[stylesheet.URL] = fileStat{modTime: fi.ModTime(), size: fi.Size()}
+				}
+			}
+		}
+	}
+	for _, stylesheet := range stylesheets {
+		if stylesheet.ImportedFrom != "" {
+			ws.imports[stylesheet.ImportedFrom] = appendUnique(ws.imports[stylesheet.ImportedFrom], stylesheet.URL)
+		}
+	}
+	return roots
+}
+
+func appendUnique(l []string, s string) []string {
+	for _, s_ := range l {
+		if s_ == s {
+			return l
+		}
+	}
+	return append(l, s)
+}
+
+func (ws *Workspace) isInput(file string) bool {
+	for _, input := range ws.inputs {
+		if input == file {
+			return true
+		}
+	}
+	return false
+}
+
+func (ws *Workspace) remove(file string) {
+	delete(ws.stats, file)
+	delete(ws.used, file)
+	delete(ws.roots, file)
+	delete(ws.defined, file)
+	delete(ws.imports, file)
+	if ws.roles[file] == rolePage {
+		for url := range ws.defined {
+			if strings.HasPrefix(url, file+"#") {
+				delete(ws.defined, url)
+			}
+		}
+	}
+	if !ws.isInput(file) {
+		delete(ws.roles, file)
+	}
+	ws.Loader.Forget(file)
+}
+
+// buildReport computes the report from the parsed files. Only the
+// stylesheets still included by a page or a CSS input are taken into
+// account.
+func (ws *Workspace) buildReport() *WatchReport {
+	ret := &WatchReport{
+		Used:    []ClassUsage{},
+		Defined: []DefinedClass{},
+		Unused:  []DefinedClass{},
+		Updated: time.Now(),
+	}
+
+	usedClasses := map[string]bool{}
+	var globs []string
+	for _, usages := range ws.used {
+		for _, usage := range usages {
+			ret.Used = append(ret.Used, usage)
+			if usage.Dynamic {
+				continue
+			}
+			if usage.Uncertain {
+				globs = append(globs, usage.Class)
+			} else {
+				usedClasses[usage.Class] = true
+			}
+		}
+	}
+	sort.Slice(ret.Used, func(i, j int) bool {
+		a, b := ret.Used[i], ret.Used[j]
+		if a.Class != b.Class {
+			return a.Class < b.Class
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	reachable := map[string]bool{}
+	var visit func(url string)
+	visit = func(url string) {
+		if reachable[url] {
+			return
+		}
+		reachable[url] = true
+		for _, imported := range ws.imports[url] {
+			visit(imported)
+		}
+	}
+	for _, roots := range ws.roots {
+		for _, root := range roots {
+			visit(root)
+		}
+	}
+
+	for url := range reachable {
+		for _, defined := range ws.defined[url] {
+			ret.Defined = append(ret.Defined, defined)
+			if !usedClasses[defined.Class] && !containsGlob(globs, defined.Class) {
+				ret.Unused = append(ret.Unused, defined)
+			}
+		}
+	}
+	for _, l := range [][]DefinedClass{ret.Defined, ret.Unused} {
+		sort.Slice(l, func(i, j int) bool {
+			if l[i].Class != l[j].Class {
+				return l[i].Class < l[j].Class
+			}
+			return l[i].Stylesheet < l[j].Stylesheet
+		})
+	}
+	return ret
+}
+
+// diffReports returns the classes unused in current but not in previous, and
+// the other way around, for classes still defined.
+func diffReports(previous *WatchReport, current *WatchReport) []ClassChange {
+	unusedBefore := map[string]DefinedClass{}
+	for _, d := range previous.Unused {
+		if _, ok := unusedBefore[d.Class]; !ok {
+			unusedBefore[d.Class] = d
+		}
+	}
+	unusedNow := map[string]DefinedClass{}
+	for _, d := range current.Unused {
+		if _, ok := unusedNow[d.Class]; !ok {
+			unusedNow[d.Class] = d
+		}
+	}
+	definedNow := map[string]bool{}
+	for _, d := range current.Defined {
+		definedNow[d.Class] = true
+	}
+	firstUsage := map[string]ClassUsage{}
+	for _, u := range current.Used {
+		if _, ok := firstUsage[u.Class]; !ok {
+			firstUsage[u.Class] = u
+		}
+	}
+
+	var ret []ClassChange
+	for class, d := range unusedNow {
+		if _, ok := unusedBefore[class]; !ok {
+			ret = append(ret, ClassChange{
+				Class:    class,
+				Unused:   true,
+				Location: fmt.Sprintf("%s:%d:%d", strings.SplitN(d.Stylesheet, "#", 2)[0], d.Line, d.Column),
+			})
+		}
+	}
+	for class := range unusedBefore {
+		if _, ok := unusedNow[class]; ok || !definedNow[class] {
+			continue
+		}
+		change := ClassChange{Class: class}
+		if u, ok := firstUsage[class]; ok {
+			change.Location = fmt.Sprintf("%s:%d:%d", u.File, u.Line, u.Column)
+		}
+		ret = append(ret, change)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Class < ret[j].Class
+	})
+	return ret
+}
+
+// getDefinedClassPositions returns the classes defined in stylesheet, with
+// the position of their first occurrence.
+func getDefinedClassPositions(stylesheet Stylesheet) []DefinedClass {
+	parsed := parseCSS(stylesheet.Content)
+	classOffsets := getClassOffsets(parsed)
+	var ret []DefinedClass
+	for _, class := range getDefinedClasses(getRules(parsed)) {
+		line, column := stylesheet.Position(parsed.Position(classOffsets[class]))
+		ret = append(ret, DefinedClass{
+			Class:      class,
+			Stylesheet: stylesheet.URL,
+			Page:       stylesheet.Page,
+			Line:       line,
+			Column:     column,
+		})
+	}
+	return ret
+}
+
+// ServeHTTP serves the current report as JSON.
+func (ws *Workspace) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/report" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(ws.Report()); err != nil {
+		log.Warn().Err(err).Msg("Could not write report")
+	}
+}
+
+type WatchCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewWatchCommand() (*WatchCommand, error) {
+	return &WatchCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"watch",
+			cmds.WithShort("Watch files and report the classes that become unused or used."),
+			cmds.WithLong("Parse HTML pages, CSS files and template and component sources like used and defined do, "+
+				"then watch them and print the classes that become unused, or used again, after every change. "+
+				"Only the files that changed are parsed again.\n\n"+
+				"Changes are detected with inotify (or the equivalent of the platform), or by polling "+
+				"with --poll. With --listen, the current report (used, defined and unused classes) "+
+				"is served as JSON on http://<address>/report."),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"poll",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Poll the files every this many milliseconds instead of using file system notifications."),
+					parameters.WithDefault(0),
+				),
+				parameters.NewParameterDefinition(
+					"listen",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Address to serve the report on, such as 127.0.0.1:8181."),
+				),
+				parameters.NewParameterDefinition(
+					"extensions",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Extensions of the source files scanned in directories."),
+					parameters.WithDefault([]string{
+						".html", ".htm", ".tmpl", ".gohtml", ".tpl",
+						".js", ".jsx", ".ts", ".tsx", ".vue", ".php",
+					}),
+				),
+				parameters.NewParameterDefinition(
+					"exclude-dirs",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Names of the directories skipped when scanning a directory (can be glob)."),
+					parameters.WithDefault([]string{"node_modules", ".git", "vendor"}),
+				),
+				parameters.NewParameterDefinition(
+					"document-root",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Directory that root-relative stylesheet URLs of local HTML files are resolved against (defaults to the directory of the file)."),
+				),
+			),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"files",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("HTML pages, CSS files, source files or directories to watch."),
+					parameters.WithRequired(true),
+				),
+			),
+		),
+	}, nil
+}
+
+func (c *WatchCommand) RunIntoWriter(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	w io.Writer,
+) error {
+	files := ps["files"].([]string)
+	poll, _ := ps["poll"].(int)
+	listen, _ := ps["listen"].(string)
+	extensions, _ := ps["extensions"].([]string)
+	excludeDirs, _ := ps["exclude-dirs"].([]string)
+	documentRoot, _ := ps["document-root"].(string)
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt)
+	defer stop()
+
+	ws := NewWorkspace(NewStylesheetLoader(documentRoot), extensions, excludeDirs)
+	if err := ws.Load(files); err != nil {
+		return err
+	}
+	report := ws.Report()
+	_, _ = fmt.Fprintf(w, "Watching %d files: %d classes used, %d defined, %d unused\n",
+		ws.FileCount(), len(report.Used), len(report.Defined), len(report.Unused))
+
+	if listen != "" {
+		listener, err := net.Listen("tcp", listen)
+		if err != nil {
+			return err
+		}
+		server := &http.Server{Handler: ws}
+		go func() {
+			<-ctx.Done()
+			_ = server.Close()
+		}()
+		go func() {
+			if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Msg("Could not serve report")
+			}
+		}()
+		_, _ = fmt.Fprintf(w, "Serving the report on http://%s/report\n", listener.Addr())
+	}
+
+	for range watchChanges(ctx, ws, time.Duration(poll)*time.Millisecond) {
+		changed := ws.Scan()
+		if len(changed) == 0 {
+			continue
+		}
+		for _, change := range ws.Update(changed) {
+			if change.Unused {
+				_, _ = fmt.Fprintf(w, "newly unused: .%s %s\n", change.Class, change.Location)
+			} else {
+				_, _ = fmt.Fprintf(w, "newly used:   .%s %s\n", change.Class, change.Location)
+			}
+		}
+	}
+
+	return nil
+}
+
+// watchChanges returns a channel receiving a value whenever a file of ws may
+// have changed, and closed when ctx is done. It polls every interval if it is
+// positive, and uses file system notifications otherwise, falling back to
+// polling every second if they are not available.
+func watchChanges(ctx context.Context, ws *Workspace, interval time.Duration) <-chan struct{} {
+	ret := make(chan struct{})
+
+	var watcher *fsnotify.Watcher
+	if interval <= 0 {
+		var err error
+		watcher, err = fsnotify.NewWatcher()
+		if err != nil {
+			log.Warn().Err(err).Msg("Could not use file system notifications, polling instead")
+			interval = time.Second
+		} else {
+			for _, dir := range ws.WatchedDirectories() {
+				if err := watcher.Add(dir); err != nil {
+					log.Warn().Err(err).Str("directory", dir).Msg("Could not watch directory")
+				}
+			}
+		}
+	}
+
+	go func() {
+		defer close(ret)
+		if watcher == nil {
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					select {
+					case ret <- struct{}{}:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}
+
+		defer func() {
+			_ = watcher.Close()
+		}()
+		// editors write files in several steps, events are batched until
+		// none arrived for debounce
+		const debounce = 100 * time.Millisecond
+		var timer <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Create) {
+					if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+						_ = watcher.Add(event.Name)
+					}
+				}
+				timer = time.After(debounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Warn().Err(err).Msg("File system notification error")
+			case <-timer:
+				timer = nil
+				select {
+				case ret <- struct{}{}:
+				case <-ctx.Done():
+					return
+				}
+				// pages can include new stylesheets
+				for _, dir := range ws.WatchedDirectories() {
+					_ = watcher.Add(dir)
+				}
+			}
+		}
+	}()
+
+	return ret
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWorkspace_Update(t *testing.T) {
+	dir := t.TempDir()
+	page := filepath.Join(dir, "index.html")
+	stylesheet := filepath.Join(dir, "style.css")
+	write := func(path string, content string) {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(stylesheet, ".a { color: red }\n.b { color: blue }\n.c { color: green }\n")
+	write(page, `<link rel="stylesheet" href="style.css"><div class="a"></div>`)
+
+	ws := NewWorkspace(NewStylesheetLoader(""), []string{".html"}, nil)
+	if err := ws.Load([]string{page}); err != nil {
+		t.Fatal(err)
+	}
+	unused := func() []string {
+		ret := []string{}
+		for _, d := range ws.Report().Unused {
+			ret = append(ret, d.Class)
+		}
+		return ret
+	}
+	if expected := []string{"b", "c"}; !reflect.DeepEqual(unused(), expected) {
+		t.Fatalf("expected unused %v, got %v", expected, unused())
+	}
+
+	write(page, `<link rel="stylesheet" href="style.css"><div class="a b"></div>`)
+	if changed := ws.Scan(); !reflect.DeepEqual(changed, []string{page}) {
+		t.Fatalf("expected %s to change, got %v", page, changed)
+	}
+	changes := ws.Update([]string{page})
+	expected := []ClassChange{{Class: "b", Location: page + ":1:55"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+
+	write(stylesheet, ".a { color: red }\n.b { color: blue }\n.c { color: green }\n.d { color: black }\n")
+	if changed := ws.Scan(); !reflect.DeepEqual(changed, []string{stylesheet}) {
+		t.Fatalf("expected %s to change, got %v", stylesheet, changed)
+	}
+	changes = ws.Update([]string{stylesheet})
+	expected = []ClassChange{{Class: "d", Unused: true, Location: stylesheet + ":4:1"}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("expected %v, got %v", expected, changes)
+	}
+	if changed := ws.Scan(); len(changed) != 0 {
+		t.Fatalf("expected no change, got %v", changed)
+	}
+
+	recorder := httptest.NewRecorder()
+	ws.ServeHTTP(recorder, httptest.NewRequest("GET", "/report", nil))
+	var report WatchReport
+	if err := json.Unmarshal(recorder.Body.Bytes(), &report); err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Unused) != 2 || report.Unused[0].Class != "c" || report.Unused[1].Class != "d" {
+		t.Fatalf("expected c and d to be unused, got %v", report.Unused)
+	}
+}
+
+func TestWorkspace_Directory(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	write("style.css", ".a { color: red }\n.b { color: blue }\n.c { color: green }\n")
+	write("index.html", `<link rel="stylesheet" href="style.css"><div class="a"></div>`)
+	write("Button.jsx", `<button className="b"></button>`)
+
+	ws := NewWorkspace(NewStylesheetLoader(""), []string{".html", ".jsx"}, nil)
+	if err := ws.Load([]string{dir}); err != nil {
+		t.Fatal(err)
+	}
+	report := ws.Report()
+	if len(report.Defined) != 3 || len(report.Unused) != 1 || report.Unused[0].Class != "c" {
+		t.Fatalf("expected 3 defined classes and c unused, got %v and %v", report.Defined, report.Unused)
+	}
+
+	// a page created while watching loads its stylesheets too
+	write("other.css", ".d { color: black }\n")
+	page := write("other.html", `<link rel="stylesheet" href="other.css">`)
+	if changed := ws.Scan(); !reflect.DeepEqual(changed, []string{page}) {
+		t.Fatalf("expected %s to be new, got %v", page, changed)
+	}
+	changes := ws.Update([]string{page})
+	if len(changes) != 1 || changes[0].Class != "d" || !changes[0].Unused {
+		t.Fatalf("expected d to become unused, got %v", changes)
+	}
+}
@@ -2,13 +2,15 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
+	"github.com/go-go-golems/go-go-labs/pkg/workerpool"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
-	"sync"
 )
 
 func isValidJSON(filepath string) bool {
@@ -21,13 +23,11 @@ func isValidJSON(filepath string) bool {
 	return json.Unmarshal(data, &js) == nil
 }
 
-func processLine(line, outputDir string, wg *sync.WaitGroup) {
-	defer wg.Done()
-
+func processLine(ctx context.Context, line, outputDir string) error {
 	fields := strings.SplitN(line, ",", 2)
 	if len(fields) < 2 {
 		fmt.Printf("Skipping malformed line: %s\n", line)
-		return
+		return nil
 	}
 
 	name := fields[0]
@@ -38,7 +38,7 @@ func processLine(line, outputDir string, wg *sync.WaitGroup) {
 		// If file exists
 		if isValidJSON(outputFilePath) {
 			fmt.Printf("Valid JSON already exists for %s. Skipping...\n", name)
-			return
+			return nil
 		} else {
 			fmt.Printf("Invalid JSON already exists for %s. Removing...\n", name)
 			// Remove the invalid JSON file
@@ -47,17 +47,15 @@ func processLine(line, outputDir string, wg *sync.WaitGroup) {
 	}
 
 	fmt.Printf("Processing %s...\n", name)
-	cmd := exec.Command("pinocchio", "ttc", "plants", "--name", name, "--botanical-name", botanicalName)
+	cmd := exec.CommandContext(ctx, "pinocchio", "ttc", "plants", "--name", name, "--botanical-name", botanicalName)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("Error executing command for %s: %v\n", name, err)
-		return
+		return fmt.Errorf("error executing command for %s: %w", name, err)
 	}
 
 	outFile, err := os.Create(outputFilePath)
 	if err != nil {
-		fmt.Printf("Error creating file for %s: %v\n", name, err)
-		return
+		return fmt.Errorf("error creating file for %s: %w", name, err)
 	}
 	defer func(outFile *os.File) {
 		_ = outFile.Close()
@@ -65,8 +63,9 @@ func processLine(line, outputDir string, wg *sync.WaitGroup) {
 
 	_, err = outFile.Write(output)
 	if err != nil {
-		fmt.Printf("Error writing to file for %s: %v\n", name, err)
+		return fmt.Errorf("error writing to file for %s: %w", name, err)
 	}
+	return nil
 }
 
 func main() {
@@ -101,22 +100,31 @@ func main() {
 		_ = file.Close()
 	}(file)
 
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	scanner := bufio.NewScanner(file)
-	var wg sync.WaitGroup
+	pool := workerpool.New(*NTHREADs)
+	pool.Start(ctx)
 
-	sem := make(chan struct{}, *NTHREADs)
 	for scanner.Scan() {
-		wg.Add(1)
-		sem <- struct{}{} // Acquire one semaphore slot
-		go func(line string) {
-			defer func() { <-sem }() // Release one semaphore slot
-			processLine(line, *outputDir, &wg)
-		}(scanner.Text())
+		line := scanner.Text()
+		pool.AddJob(func(ctx context.Context) error {
+			return processLine(ctx, line, *outputDir)
+		})
 	}
 
 	if err := scanner.Err(); err != nil {
 		fmt.Printf("Error reading file: %v\n", err)
 	}
 
-	wg.Wait()
+	if err := pool.Close(); err != nil {
+		if errs, ok := err.(workerpool.Errors); ok {
+			for _, err := range errs {
+				fmt.Println(err)
+			}
+		} else {
+			fmt.Println(err)
+		}
+	}
 }
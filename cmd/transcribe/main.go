@@ -7,18 +7,17 @@ import (
 	"io/ioutil"
 	"log"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
-	"sync"
 
+	"github.com/go-go-golems/go-go-labs/pkg/workerpool"
 	openai "github.com/sashabaranov/go-openai"
 )
 
 var authToken = "XXX"
 
-func transcribeFile(client *openai.Client, mp3FilePath string, out chan<- string, wg *sync.WaitGroup) {
-	defer wg.Done()
-
+func transcribeFile(ctx context.Context, client *openai.Client, mp3FilePath string) (string, error) {
 	// Set up the audio request
 	req := openai.AudioRequest{
 		Model:    openai.Whisper1,
@@ -27,14 +26,12 @@ func transcribeFile(client *openai.Client, mp3FilePath string, out chan<- string
 	}
 
 	// Call the CreateTranscription method
-	resp, err := client.CreateTranscription(context.Background(), req)
+	resp, err := client.CreateTranscription(ctx, req)
 	if err != nil {
-		log.Printf("Failed to transcribe %s: %v\n", mp3FilePath, err)
-		out <- ""
-		return
+		return "", fmt.Errorf("failed to transcribe %s: %w", mp3FilePath, err)
 	}
 
-	out <- resp.Text
+	return resp.Text, nil
 }
 
 func main() {
@@ -63,28 +60,35 @@ func main() {
 
 	client := openai.NewClient(authToken)
 
-	var wg sync.WaitGroup
-	out := make(chan string, len(files))
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
+	var mp3Files []string
 	for _, file := range files {
 		if strings.HasSuffix(file.Name(), ".mp3") {
-			wg.Add(1)
-			go transcribeFile(client, filepath.Join(*dirPath, file.Name()), out, &wg)
-
-			// Limit concurrent workers
-			for len(out) >= *workers {
-				<-out
-			}
+			mp3Files = append(mp3Files, filepath.Join(*dirPath, file.Name()))
 		}
 	}
 
-	wg.Wait()
-	close(out)
-
-	// Collect and reassemble transcriptions
-	var transcriptions []string
-	for transcription := range out {
-		transcriptions = append(transcriptions, transcription)
+	// Each job writes to its own slot, so the transcriptions stay in file order
+	transcriptions := make([]string, len(mp3Files))
+	pool := workerpool.New(*workers, workerpool.WithProgress(func(progress workerpool.Progress) {
+		log.Printf("Transcribed %d/%d files\n", progress.Done(), len(mp3Files))
+	}))
+	pool.Start(ctx)
+	for i, mp3FilePath := range mp3Files {
+		i, mp3FilePath := i, mp3FilePath
+		pool.AddJob(func(ctx context.Context) error {
+			text, err := transcribeFile(ctx, client, mp3FilePath)
+			if err != nil {
+				return err
+			}
+			transcriptions[i] = text
+			return nil
+		})
+	}
+	if err := pool.Close(); err != nil {
+		log.Printf("Some files could not be transcribed: %v\n", err)
 	}
 
 	fmt.Println("Combined Transcription:", strings.Join(transcriptions, "\n"))
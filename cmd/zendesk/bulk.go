@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/go-go-labs/pkg/workerpool"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"sync"
+)
+
+// bulkBatchSize is the maximum number of tickets accepted by the *_many endpoints.
+const bulkBatchSize = 100
+
+// bulkJobFunc starts a background job for one batch of tickets.
+type bulkJobFunc func(ctx context.Context, ticketIds []int) (*JobStatus, error)
+
+// bulkBatchResult is the outcome of one batch. Err is set if the job could
+// not be started, could not be polled, or failed as a whole.
+type bulkBatchResult struct {
+	TicketIds []int
+	JobStatus *JobStatus
+	Err       error
+}
+
+// runBulkJobs splits ticketIds into batches of bulkBatchSize, starts a job
+// for each batch with submit on a pool of workers, and waits for every job to
+// finish. If rateLimit is positive, at most rateLimit batches are started per
+// second across all workers.
+//
+// onResult is called once per batch, never concurrently. A failed batch does
+// not stop the other batches; it is passed to onResult with Err set. The
+// returned error collects the errors of all failed batches, and the context
+// error if batches were skipped because ctx was canceled.
+func runBulkJobs(
+	ctx context.Context,
+	zd *ZendeskConfig,
+	ticketIds []int,
+	workers int,
+	rateLimit float64,
+	submit bulkJobFunc,
+	onResult func(result bulkBatchResult),
+) error {
+	batches := chunkInts(ticketIds, bulkBatchSize)
+	pool := workerpool.New(workers,
+		workerpool.WithRateLimit(rateLimit, 1),
+		workerpool.WithProgress(func(progress workerpool.Progress) {
+			log.Debug().
+				Int("done", progress.Done()).
+				Int("failed", progress.Failed).
+				Int("batches", len(batches)).
+				Msg("Bulk job progress")
+		}),
+	)
+	pool.Start(ctx)
+
+	var mu sync.Mutex
+	report := func(result bulkBatchResult) error {
+		mu.Lock()
+		defer mu.Unlock()
+		onResult(result)
+		return result.Err
+	}
+
+	for _, ticketIdGroup := range batches {
+		ticketIdGroupCopy := ticketIdGroup // Create a copy to avoid closure over the loop variable
+		pool.AddJob(func(ctx context.Context) error {
+			jobStatus, err := submit(ctx, ticketIdGroupCopy)
+			if err != nil {
+				return report(bulkBatchResult{TicketIds: ticketIdGroupCopy, Err: err})
+			}
+			if jobStatus == nil {
+				return report(bulkBatchResult{TicketIds: ticketIdGroupCopy, Err: errors.New("no job status returned")})
+			}
+
+			// getJobStatus polls until the job is completed or failed, and
+			// already retries rate limits and transient errors
+			jobID := jobStatus.ID
+			jobStatus, err = zd.getJobStatus(ctx, jobID)
+			if err != nil {
+				return report(bulkBatchResult{
+					TicketIds: ticketIdGroupCopy,
+					Err:       errors.Wrapf(err, "failed to get job status for job ID %s", jobID),
+				})
+			}
+
+			result := bulkBatchResult{TicketIds: ticketIdGroupCopy, JobStatus: jobStatus}
+			if jobStatus.Status == "failed" {
+				result.Err = fmt.Errorf("job %s failed: %s", jobStatus.ID, jobStatus.Message)
+			}
+			return report(result)
+		})
+	}
+
+	return pool.Close()
+}
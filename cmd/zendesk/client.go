@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/levigross/grequests"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// These are variables so that tests can shorten them.
+var (
+	defaultMaxRetries      = 5
+	defaultMinBackoff      = 1 * time.Second
+	defaultMaxBackoff      = 60 * time.Second
+	defaultJobPollInterval = 5 * time.Second
+)
+
+var (
+	ErrNotFound     = errors.New("zendesk: not found")
+	ErrUnauthorized = errors.New("zendesk: authentication failed")
+	ErrRateLimited  = errors.New("zendesk: rate limited")
+)
+
+// APIError is returned for every non-2xx response that is not retried (or
+// that is still failing once all retries are used up).
+//
+// It unwraps to ErrNotFound, ErrUnauthorized or ErrRateLimited depending on
+// the status code, so callers can use errors.Is.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       string
+	// RetryAfter is the delay requested by the server on a 429.
+	RetryAfter time.Duration
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("zendesk: %s %s failed with status %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	case http.StatusTooManyRequests:
+		return ErrRateLimited
+	default:
+		return nil
+	}
+}
+
+func (zd *ZendeskConfig) maxRetries() int {
+	if zd.MaxRetries == 0 {
+		return defaultMaxRetries
+	}
+	if zd.MaxRetries < 0 {
+		return 0
+	}
+	return zd.MaxRetries
+}
+
+// backoff returns the delay before retry number attempt (starting at 0):
+// exponential from MinBackoff, capped at MaxBackoff, with up to 50% jitter.
+func (zd *ZendeskConfig) backoff(attempt int) time.Duration {
+	minBackoff, maxBackoff := zd.MinBackoff, zd.MaxBackoff
+	if minBackoff == 0 {
+		minBackoff = defaultMinBackoff
+	}
+	if maxBackoff == 0 {
+		maxBackoff = defaultMaxBackoff
+	}
+
+	d := minBackoff
+	for i := 0; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// request sends an authenticated request to the Zendesk API. Every call to
+// Zendesk goes through here. Credentials are only sent to the configured
+// Zendesk domain, other hosts (e.g. attachment storage) get a plain request.
+//
+// 429 responses are retried after the delay given in Retry-After, 5xx
+// responses and network errors are retried with exponential backoff. Other
+// non-2xx responses are returned as *APIError right away.
+// If body is not nil, it is sent as JSON.
+func (zd *ZendeskConfig) request(ctx context.Context, method string, endpoint string, body interface{}) (*grequests.Response, error) {
+	ro := &grequests.RequestOptions{
+		Headers: map[string]string{},
+		Context: ctx,
+	}
+	if zd.isZendeskURL(endpoint) {
+		ro.Headers["Authorization"] = "Basic " + basicAuth(zd.Email, zd.ApiToken)
+	}
+	if body != nil {
+		ro.JSON = body
+	}
+
+	maxRetries := zd.maxRetries()
+	for attempt := 0; ; attempt++ {
+		response, err := grequests.Req(method, endpoint, ro)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			if attempt >= maxRetries {
+				return nil, errors.Wrapf(err, "zendesk: %s %s failed", method, endpoint)
+			}
+			delay := zd.backoff(attempt)
+			log.Warn().Err(err).Str("url", endpoint).Dur("delay", delay).Msg("Request failed, retrying")
+			if err := sleepContext(ctx, delay); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if response.StatusCode >= 200 && response.StatusCode < 300 {
+			return response, nil
+		}
+
+		apiErr := &APIError{
+			Method:     method,
+			URL:        endpoint,
+			StatusCode: response.StatusCode,
+			Body:       response.String(),
+		}
+
+		var delay time.Duration
+		switch {
+		case response.StatusCode == http.StatusTooManyRequests:
+			apiErr.RetryAfter = parseRetryAfter(response.Header.Get("Retry-After"))
+			delay = apiErr.RetryAfter
+			if delay <= 0 {
+				delay = zd.backoff(attempt)
+			}
+		case response.StatusCode >= 500:
+			delay = zd.backoff(attempt)
+		default:
+			return nil, apiErr
+		}
+
+		if attempt >= maxRetries {
+			return nil, apiErr
+		}
+
+		log.Warn().
+			Int("status", response.StatusCode).
+			Str("url", endpoint).
+			Dur("delay", delay).
+			Int("attempt", attempt+1).
+			Msg("Zendesk request failed, retrying")
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// isZendeskURL returns true if endpoint is on the host of the configured
+// Zendesk domain.
+func (zd *ZendeskConfig) isZendeskURL(endpoint string) bool {
+	domain, err := url.Parse(zd.Domain)
+	if err != nil || domain.Host == "" {
+		return false
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, domain.Host)
+}
+
+// requestJSON is request followed by decoding the JSON response into out.
+func (zd *ZendeskConfig) requestJSON(ctx context.Context, method string, endpoint string, body interface{}, out interface{}) error {
+	response, err := zd.request(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	if err := response.JSON(out); err != nil {
+		return errors.Wrapf(err, "zendesk: could not decode response from %s", endpoint)
+	}
+	return nil
+}
@@ -0,0 +1,825 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/go-go-labs/cmd/zendesk/fakezendesk"
+	"github.com/go-go-golems/go-go-labs/cmd/zendesk/mirror"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	defaultMinBackoff = time.Millisecond
+	defaultMaxBackoff = 10 * time.Millisecond
+	defaultJobPollInterval = time.Millisecond
+	os.Exit(m.Run())
+}
+
+func newFakeZendesk(t *testing.T) *fakezendesk.Server {
+	tickets, err := fakezendesk.LoadTickets("testdata/tickets.json")
+	if err != nil {
+		t.Fatalf("could not load fixtures: %v", err)
+	}
+	fields, err := fakezendesk.LoadTicketFields("testdata/ticket_fields.json")
+	if err != nil {
+		t.Fatalf("could not load fixtures: %v", err)
+	}
+	users, err := fakezendesk.LoadUsers("testdata/users.json")
+	if err != nil {
+		t.Fatalf("could not load fixtures: %v", err)
+	}
+	organizations, err := fakezendesk.LoadOrganizations("testdata/organizations.json")
+	if err != nil {
+		t.Fatalf("could not load fixtures: %v", err)
+	}
+	s := fakezendesk.New(tickets)
+	s.SetTicketFields(fields)
+	s.SetUsers(users)
+	s.SetOrganizations(organizations)
+	t.Cleanup(s.Close)
+	return s
+}
+
+func connectionParameters(s *fakezendesk.Server) map[string]interface{} {
+	return map[string]interface{}{
+		"domain":    s.URL,
+		"email":     "agent@example.com",
+		"api-token": "token",
+	}
+}
+
+// rowCollector is a middlewares.Processor that keeps every row it is given.
+type rowCollector struct {
+	rows []types.Row
+}
+
+func (c *rowCollector) AddRow(ctx context.Context, row types.Row) error {
+	c.rows = append(c.rows, row)
+	return nil
+}
+
+func (c *rowCollector) Close(ctx context.Context) error {
+	return nil
+}
+
+func runGetTicketRows(t *testing.T, ps map[string]interface{}) ([]types.Row, error) {
+	cmd, err := NewGetTicketsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ps["limit"]; !ok {
+		ps["limit"] = 0
+	}
+
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, ps, gp)
+	if err != nil {
+		return nil, err
+	}
+	return gp.rows, nil
+}
+
+func runGetTickets(t *testing.T, ps map[string]interface{}) ([]int, error) {
+	rows, err := runGetTicketRows(t, ps)
+	if err != nil {
+		return nil, err
+	}
+	return rowIDs(rows), nil
+}
+
+func runDeleteTickets(t *testing.T, ps map[string]interface{}) (string, error) {
+	cmd, err := NewDeleteTicketsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ps["workers"]; !ok {
+		ps["workers"] = 2
+	}
+	if _, ok := ps["dry-run"]; !ok {
+		ps["dry-run"] = false
+	}
+
+	buf := &bytes.Buffer{}
+	err = cmd.RunIntoWriter(context.Background(), nil, ps, buf)
+	return buf.String(), err
+}
+
+func allFixtureIDs() []int {
+	var ids []int
+	for id := 1001; id <= 1012; id++ {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestGetTicketsCommand_ExportsAllPages(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetPageSize(5)
+
+	ids, err := runGetTickets(t, connectionParameters(s))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, allFixtureIDs()) {
+		t.Fatalf("expected %v, got %v", allFixtureIDs(), ids)
+	}
+	if n := s.Requests("/api/v2/incremental/tickets/cursor.json"); n != 3 {
+		t.Fatalf("expected 3 export pages, got %d", n)
+	}
+}
+
+func TestGetTicketsCommand_Filters(t *testing.T) {
+	s := newFakeZendesk(t)
+	ps := connectionParameters(s)
+	ps["status"] = []string{"new", "closed"}
+	ps["tags"] = []string{"spam"}
+
+	ids, err := runGetTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// tickets 1001, 1005 and 1009 are tagged spam, with status new, closed and solved
+	expected := []int{1001, 1005}
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("expected %v, got %v", expected, ids)
+	}
+}
+
+func TestGetTicketsCommand_RetriesRateLimits(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetPageSize(5)
+	s.InjectRateLimits(3, "0")
+
+	ids, err := runGetTickets(t, connectionParameters(s))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, allFixtureIDs()) {
+		t.Fatalf("expected %v, got %v", allFixtureIDs(), ids)
+	}
+	if n := s.Requests("/api/v2/incremental/tickets/cursor.json"); n != 6 {
+		t.Fatalf("expected 3 pages and 3 rate-limited requests, got %d requests", n)
+	}
+}
+
+func TestGetTicketsCommand_GivesUpOnPersistentRateLimit(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.InjectRateLimits(100, "")
+
+	_, err := runGetTickets(t, connectionParameters(s))
+	if !errors.Is(err, ErrRateLimited) {
+		t.Fatalf("expected ErrRateLimited, got %v", err)
+	}
+}
+
+func TestGetTicketsCommand_ResumesFromStateFile(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetPageSize(5)
+	stateFile := filepath.Join(t.TempDir(), "state.json")
+
+	ps := connectionParameters(s)
+	ps["state-file"] = stateFile
+	ids, err := runGetTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(ids) != 12 {
+		t.Fatalf("expected 12 tickets on the first run, got %v", ids)
+	}
+
+	s.AddTicket(fakezendesk.Ticket{
+		"id":         2001,
+		"status":     "new",
+		"created_at": time.Now().UTC().Format(time.RFC3339),
+		"updated_at": time.Now().UTC().Format(time.RFC3339),
+	})
+
+	// the cursor of the state file wins over a later --start-date
+	ps = connectionParameters(s)
+	ps["state-file"] = stateFile
+	ps["start-date"] = time.Now().Add(24 * time.Hour)
+	ids, err = runGetTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int{2001}) {
+		t.Fatalf("expected only the new ticket on the second run, got %v", ids)
+	}
+}
+
+func TestGetTicketsCommand_ById(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	ps := connectionParameters(s)
+	ps["id"] = "1003"
+	ids, err := runGetTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []int{1003}) {
+		t.Fatalf("expected [1003], got %v", ids)
+	}
+
+	ps = connectionParameters(s)
+	ps["id"] = "9999"
+	_, err = runGetTickets(t, ps)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetTicketsCommand_Unauthorized(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	_, err := runGetTickets(t, map[string]interface{}{"domain": s.URL})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("expected ErrUnauthorized, got %v", err)
+	}
+}
+
+func TestGetTicketsCommand_CustomFieldColumns(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	ps := connectionParameters(s)
+	ps["id"] = "1002"
+	ps["custom-field-columns"] = true
+	rows, err := runGetTicketRows(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 row, got %d", len(rows))
+	}
+
+	expected := map[string]interface{}{
+		"plan":         "Pro",
+		"vip":          false,
+		"renewal_date": time.Date(2022, 2, 15, 0, 0, 0, 0, time.UTC),
+		"seats":        int64(10),
+		"mrr":          99.0,
+		"products":     []string{"Chat", "Voice"},
+		// the Status custom field clashes with the ticket status
+		"status":        "open",
+		"status_360007": "internal-2",
+	}
+	for column, value := range expected {
+		v, ok := rows[0].Get(column)
+		if !ok {
+			t.Errorf("missing column %s", column)
+			continue
+		}
+		if !reflect.DeepEqual(v, value) {
+			t.Errorf("column %s: expected %#v, got %#v", column, value, v)
+		}
+	}
+
+	if n := s.Requests("/api/v2/ticket_fields.json"); n != 1 {
+		t.Fatalf("expected the ticket fields to be fetched once, got %d requests", n)
+	}
+}
+
+func runSearch(t *testing.T, ps map[string]interface{}) ([]types.Row, error) {
+	cmd, err := NewSearchCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, ps, gp)
+	return gp.rows, err
+}
+
+func rowIDs(rows []types.Row) []int {
+	var ids []int
+	for _, row := range rows {
+		id, _ := row.Get("id")
+		ids = append(ids, id.(int))
+	}
+	return ids
+}
+
+func TestSearchCommand_Tickets(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	ps := connectionParameters(s)
+	ps["query"] = []string{"tags:billing", "status:solved"}
+	rows, err := runSearch(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := rowIDs(rows); !reflect.DeepEqual(ids, []int{1004}) {
+		t.Fatalf("expected [1004], got %v", ids)
+	}
+
+	ps = connectionParameters(s)
+	ps["query"] = []string{"created<2021-03-01"}
+	rows, err = runSearch(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := rowIDs(rows); !reflect.DeepEqual(ids, []int{1001, 1002, 1003}) {
+		t.Fatalf("expected [1001 1002 1003], got %v", ids)
+	}
+}
+
+func TestSearchCommand_PagesAndCustomFields(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	ps := connectionParameters(s)
+	ps["query"] = []string{"tags:billing"}
+	ps["page-size"] = 2
+	ps["custom-field-columns"] = true
+	rows, err := runSearch(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := rowIDs(rows); !reflect.DeepEqual(ids, []int{1001, 1004, 1007, 1010}) {
+		t.Fatalf("expected [1001 1004 1007 1010], got %v", ids)
+	}
+	if n := s.Requests("/api/v2/search/export.json"); n != 2 {
+		t.Fatalf("expected 2 pages, got %d requests", n)
+	}
+	if plan, _ := rows[1].Get("plan"); plan != "Pro" {
+		t.Fatalf("expected the plan column to be resolved to Pro, got %v", plan)
+	}
+
+	ps = connectionParameters(s)
+	ps["query"] = []string{"tags:billing"}
+	ps["page-size"] = 2
+	ps["limit"] = 3
+	rows, err = runSearch(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("expected the limit to stop after 3 rows, got %d", len(rows))
+	}
+}
+
+func TestSearchCommand_UsersAndOrganizations(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	ps := connectionParameters(s)
+	ps["query"] = []string{"role:end-user"}
+	ps["type"] = "user"
+	rows, err := runSearch(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := rowIDs(rows); !reflect.DeepEqual(ids, []int{501, 502}) {
+		t.Fatalf("expected [501 502], got %v", ids)
+	}
+
+	ps = connectionParameters(s)
+	ps["query"] = []string{"tags:enterprise"}
+	ps["type"] = "organization"
+	rows, err = runSearch(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := rowIDs(rows); !reflect.DeepEqual(ids, []int{701}) {
+		t.Fatalf("expected [701], got %v", ids)
+	}
+	if name, _ := rows[0].Get("name"); name != "Acme" {
+		t.Fatalf("expected Acme, got %v", name)
+	}
+}
+
+func TestDeleteTicketsCommand_DeletesInBatches(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetJobPolls(2)
+	s.InjectRateLimits(2, "0")
+
+	ps := connectionParameters(s)
+	ps["ids"] = []int{1001, 1002, 1003}
+	out, err := runDeleteTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+
+	if deleted := s.DeletedTicketIDs(); !reflect.DeepEqual(deleted, []int{1001, 1002, 1003}) {
+		t.Fatalf("expected tickets 1001-1003 to be deleted, got %v", deleted)
+	}
+	if !strings.Contains(out, "Deleted 3 of 3 tickets") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
+
+func TestDeleteTicketsCommand_ReportsFailedJobs(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetFailJobs(true)
+
+	ps := connectionParameters(s)
+	ps["ids"] = []int{1001, 1002}
+	out, err := runDeleteTickets(t, ps)
+	if err == nil {
+		t.Fatalf("expected an error for the failed job")
+	}
+	if !strings.Contains(out, "Failed to delete 2 tickets") || !strings.Contains(out, "1001,1002") {
+		t.Fatalf("expected the failed batch to be reported, got:\n%s", out)
+	}
+	if deleted := s.DeletedTicketIDs(); len(deleted) != 0 {
+		t.Fatalf("expected no deleted tickets, got %v", deleted)
+	}
+}
+
+func TestDeleteTicketsCommand_DryRun(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	ps := connectionParameters(s)
+	ps["tags"] = []string{"spam"}
+	ps["dry-run"] = true
+	out, err := runDeleteTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(out, "Would delete 3 tickets") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	if deleted := s.DeletedTicketIDs(); len(deleted) != 0 {
+		t.Fatalf("expected no deleted tickets, got %v", deleted)
+	}
+	if n := s.Requests("/api/v2/tickets/destroy_many.json"); n != 0 {
+		t.Fatalf("expected no destroy_many request, got %d", n)
+	}
+}
+
+func TestDeleteTicketsCommand_ManifestAndUndelete(t *testing.T) {
+	s := newFakeZendesk(t)
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+
+	ps := connectionParameters(s)
+	ps["tags"] = []string{"spam"}
+	ps["manifest"] = manifest
+	out, err := runDeleteTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	if deleted := s.DeletedTicketIDs(); !reflect.DeepEqual(deleted, []int{1001, 1005, 1009}) {
+		t.Fatalf("expected the spam tickets to be deleted, got %v", deleted)
+	}
+
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tickets []interface{}
+	if err := json.Unmarshal(b, &tickets); err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 3 {
+		t.Fatalf("expected 3 tickets in the manifest, got %d", len(tickets))
+	}
+
+	cmd, err := NewUndeleteCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps = connectionParameters(s)
+	ps["manifest"] = tickets
+	ps["dry-run"] = false
+	buf := &bytes.Buffer{}
+	if err := cmd.RunIntoWriter(context.Background(), nil, ps, buf); err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, buf.String())
+	}
+	if deleted := s.DeletedTicketIDs(); len(deleted) != 0 {
+		t.Fatalf("expected all tickets to be restored, got %v deleted", deleted)
+	}
+}
+
+func TestDeleteTicketsCommand_ExistingManifest(t *testing.T) {
+	s := newFakeZendesk(t)
+	manifest := filepath.Join(t.TempDir(), "manifest.json")
+	if err := os.WriteFile(manifest, []byte("[]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ps := connectionParameters(s)
+	ps["tags"] = []string{"spam"}
+	ps["manifest"] = manifest
+	out, err := runDeleteTickets(t, ps)
+	if err == nil {
+		t.Fatalf("expected an error for the existing manifest\n%s", out)
+	}
+	if deleted := s.DeletedTicketIDs(); len(deleted) != 0 {
+		t.Fatalf("expected no deleted tickets, got %v", deleted)
+	}
+	if b, _ := os.ReadFile(manifest); string(b) != "[]" {
+		t.Fatalf("expected the manifest to be left alone, got %s", b)
+	}
+
+	ps["force"] = true
+	out, err = runDeleteTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v\n%s", err, out)
+	}
+	b, err := os.ReadFile(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tickets []interface{}
+	if err := json.Unmarshal(b, &tickets); err != nil {
+		t.Fatal(err)
+	}
+	if len(tickets) != 3 {
+		t.Fatalf("expected 3 tickets in the manifest, got %d", len(tickets))
+	}
+	entries, err := os.ReadDir(filepath.Dir(manifest))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected no temporary files to be left, got %d entries", len(entries))
+	}
+}
+
+func TestGetCommentsCommand_PagesAndDownloadsAttachments(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetPageSize(2)
+
+	// attachments stored on another host must not get the Zendesk credentials
+	var foreignAuth []string
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		foreignAuth = append(foreignAuth, r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte("logo"))
+	}))
+	defer foreign.Close()
+
+	report := s.AddAttachment("abc/report.txt", []byte("report"))
+	s.SetComments(1001, []fakezendesk.Comment{
+		{"id": 1, "body": "Hello", "public": true},
+		{"id": 2, "body": "See attached", "attachments": []interface{}{
+			map[string]interface{}{"id": 11, "file_name": "report.txt", "content_url": report},
+			map[string]interface{}{"id": 12, "file_name": "logo.png", "content_url": foreign.URL + "/logo.png"},
+		}},
+		{"id": 3, "body": "Again", "attachments": []interface{}{
+			map[string]interface{}{"id": 13, "file_name": "report-copy.txt", "content_url": report},
+		}},
+	})
+
+	cmd, err := NewGetCommentsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	ps := connectionParameters(s)
+	ps["ids"] = []int{1001, 1002}
+	ps["attachments-dir"] = dir
+	gp := &rowCollector{}
+	if err := cmd.Run(context.Background(), nil, ps, gp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ids := rowIDs(gp.rows); !reflect.DeepEqual(ids, []int{1, 2, 3}) {
+		t.Fatalf("expected comments [1 2 3], got %v", ids)
+	}
+	if n := s.Requests("/api/v2/tickets/1001/comments.json"); n != 2 {
+		t.Fatalf("expected 2 comment pages, got %d", n)
+	}
+
+	attachments_, _ := gp.rows[1].Get("attachments")
+	attachments := attachments_.([]Attachment)
+	for _, a := range attachments {
+		if a.Path != filepath.Join(dir, a.SHA256[:2], a.SHA256) {
+			t.Errorf("unexpected path %s for attachment %d", a.Path, a.ID)
+		}
+	}
+	b, err := os.ReadFile(attachments[0].Path)
+	if err != nil || string(b) != "report" {
+		t.Fatalf("expected the report content, got %q (%v)", b, err)
+	}
+	copies, _ := gp.rows[2].Get("attachments")
+	if path := copies.([]Attachment)[0].Path; path != attachments[0].Path {
+		t.Fatalf("expected identical attachments to be stored once, got %s and %s", path, attachments[0].Path)
+	}
+	if !reflect.DeepEqual(foreignAuth, []string{""}) {
+		t.Fatalf("expected a single request without credentials to the foreign host, got %q", foreignAuth)
+	}
+}
+
+func TestGetAuditsCommand(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetPageSize(1)
+	s.SetAudits(1001, []fakezendesk.Audit{
+		{"id": 51, "ticket_id": 1001, "events": []interface{}{map[string]interface{}{"type": "Create"}}},
+		{"id": 52, "ticket_id": 1001},
+	})
+	s.SetAudits(1003, []fakezendesk.Audit{{"id": 53, "ticket_id": 1003}})
+
+	cmd, err := NewGetAuditsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := connectionParameters(s)
+	ps["ids"] = []int{1001, 1003}
+	gp := &rowCollector{}
+	if err := cmd.Run(context.Background(), nil, ps, gp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := rowIDs(gp.rows); !reflect.DeepEqual(ids, []int{51, 52, 53}) {
+		t.Fatalf("expected audits [51 52 53], got %v", ids)
+	}
+	if ticketId, _ := gp.rows[2].Get("ticket_id"); ticketId != 1003 {
+		t.Fatalf("expected ticket 1003, got %v", ticketId)
+	}
+
+	ps["ids"] = []int{9999}
+	if err := cmd.Run(context.Background(), nil, ps, &rowCollector{}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func runSync(t *testing.T, s *fakezendesk.Server, dbPath string) map[string]types.Row {
+	cmd, err := NewSyncCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps := connectionParameters(s)
+	ps["db"] = dbPath
+	ps["resources"] = []string{"ticket-fields", "organizations", "users", "tickets"}
+	gp := &rowCollector{}
+	if err := cmd.Run(context.Background(), nil, ps, gp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rows := map[string]types.Row{}
+	for _, row := range gp.rows {
+		resource, _ := row.Get("resource")
+		rows[resource.(string)] = row
+	}
+	return rows
+}
+
+func TestSyncCommand_UpsertsAndResumes(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetPageSize(5)
+	dbPath := filepath.Join(t.TempDir(), "zendesk.db")
+
+	upserted := func(rows map[string]types.Row) map[string]int {
+		ret := map[string]int{}
+		for resource, row := range rows {
+			n, _ := row.Get("upserted")
+			ret[resource] = n.(int)
+		}
+		return ret
+	}
+	count := func(table string) int {
+		db, err := sql.Open("sqlite3", dbPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+		var n int
+		if err := db.QueryRow("SELECT count(*) FROM " + table).Scan(&n); err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+
+	rows := runSync(t, s, dbPath)
+	expected := map[string]int{"ticket-fields": 8, "organizations": 2, "users": 4, "tickets": 12}
+	if got := upserted(rows); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected first sync to upsert %v, got %v", expected, got)
+	}
+	for table, n := range map[string]int{"tickets": 12, "users": 4, "organizations": 2, "sync_state": 3} {
+		if got := count(table); got != n {
+			t.Errorf("expected %d rows in %s, got %d", n, table, got)
+		}
+	}
+	if n := s.Requests("/api/v2/incremental/tickets/cursor.json"); n != 3 {
+		t.Fatalf("expected 3 ticket export pages, got %d", n)
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	tickets, err := fakezendesk.LoadTickets("testdata/tickets.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ticket := range tickets {
+		if ticket["id"] == 1003.0 {
+			ticket["subject"] = "Updated subject"
+			ticket["updated_at"] = now
+			s.AddTicket(ticket)
+		}
+	}
+	s.AddTicket(fakezendesk.Ticket{"id": 2001, "status": "new", "subject": "New", "created_at": now, "updated_at": now})
+	users, _ := fakezendesk.LoadUsers("testdata/users.json")
+	s.SetUsers(append(users, fakezendesk.User{"id": 505, "name": "Eve", "created_at": now, "updated_at": now}))
+	organizations, _ := fakezendesk.LoadOrganizations("testdata/organizations.json")
+	s.SetOrganizations(append(organizations, fakezendesk.Organization{"id": 703, "name": "Initech", "created_at": now, "updated_at": now}))
+
+	rows = runSync(t, s, dbPath)
+	// the time-based organizations export starts again at the end_time of
+	// the last page, so the organizations updated then are upserted again
+	expected = map[string]int{"ticket-fields": 8, "organizations": 3, "users": 1, "tickets": 2}
+	if got := upserted(rows); !reflect.DeepEqual(got, expected) {
+		t.Fatalf("expected second sync to upsert %v, got %v", expected, got)
+	}
+	for table, n := range map[string]int{"tickets": 13, "users": 5, "organizations": 3, "sync_state": 3} {
+		if got := count(table); got != n {
+			t.Errorf("expected %d rows in %s, got %d", n, table, got)
+		}
+	}
+	// one more page, resumed from the stored cursor
+	if n := s.Requests("/api/v2/incremental/tickets/cursor.json"); n != 4 {
+		t.Fatalf("expected the second sync to fetch a single page, got %d pages in total", n)
+	}
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	var subject string
+	if err := db.QueryRow("SELECT subject FROM tickets WHERE id = 1003").Scan(&subject); err != nil {
+		t.Fatal(err)
+	}
+	if subject != "Updated subject" {
+		t.Fatalf("expected ticket 1003 to be updated, got %q", subject)
+	}
+	state, err := newMirrorExportStateStore(context.Background(), mirror.New(db), "tickets").Load()
+	if err != nil || state == nil || state.Cursor == "" || !state.EndOfStream {
+		t.Fatalf("unexpected tickets sync state %+v (%v)", state, err)
+	}
+}
+
+func runUpdateTickets(t *testing.T, ps map[string]interface{}) ([]types.Row, error) {
+	cmd, err := NewUpdateTicketsCommand()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ps["workers"]; !ok {
+		ps["workers"] = 2
+	}
+	gp := &rowCollector{}
+	err = cmd.Run(context.Background(), nil, ps, gp)
+	return gp.rows, err
+}
+
+func TestUpdateTicketsCommand_UpdatesTickets(t *testing.T) {
+	s := newFakeZendesk(t)
+
+	ps := connectionParameters(s)
+	ps["ids"] = []int{1001, 1003}
+	ps["status"] = "pending"
+	ps["add-tags"] = []string{"reviewed"}
+	// multiselect labels as given on the command line
+	ps["custom-fields"] = map[string]interface{}{"Products": "Chat, Voice"}
+	rows, err := runUpdateTickets(t, ps)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ids := rowIDs(rows); !reflect.DeepEqual(ids, []int{1001, 1003}) {
+		t.Fatalf("expected rows for 1001 and 1003, got %v", ids)
+	}
+
+	for _, id := range []int{1001, 1003} {
+		ticket, _ := s.Ticket(id)
+		if ticket["status"] != "pending" {
+			t.Errorf("ticket %d: expected status pending, got %v", id, ticket["status"])
+		}
+		if tags := fmt.Sprint(ticket["tags"]); !strings.Contains(tags, "reviewed") {
+			t.Errorf("ticket %d: expected the reviewed tag, got %s", id, tags)
+		}
+		if fields := fmt.Sprint(ticket["custom_fields"]); !strings.Contains(fields, "id:360006 value:[product_chat product_voice]") {
+			t.Errorf("ticket %d: expected the products to be set as tags, got %s", id, fields)
+		}
+	}
+}
+
+func TestUpdateTicketsCommand_ReportsFailedTickets(t *testing.T) {
+	s := newFakeZendesk(t)
+	s.SetFailJobs(true)
+
+	ps := connectionParameters(s)
+	ps["ids"] = []int{1002, 1001}
+	ps["priority"] = "high"
+	rows, err := runUpdateTickets(t, ps)
+	if err == nil || !strings.Contains(err.Error(), "1001,1002") {
+		t.Fatalf("expected an error listing the failed tickets, got %v", err)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected a row per failed ticket, got %d", len(rows))
+	}
+	if success, _ := rows[0].Get("success"); success != false {
+		t.Fatalf("expected the tickets to be reported as failed, got %v", rows[0])
+	}
+
+	s.SetFailJobs(false)
+	ps["ids"] = []int{1001, 9999}
+	_, err = runUpdateTickets(t, ps)
+	if err == nil || !strings.Contains(err.Error(), "not updated: 9999") {
+		t.Fatalf("expected an error for the missing ticket, got %v", err)
+	}
+}
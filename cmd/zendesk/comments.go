@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/pkg/errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+type Attachment struct {
+	ID          int    `json:"id"`
+	FileName    string `json:"file_name"`
+	ContentURL  string `json:"content_url"`
+	ContentType string `json:"content_type"`
+	Size        int    `json:"size"`
+	Inline      bool   `json:"inline"`
+	// SHA256 and Path are only set once the attachment has been downloaded.
+	SHA256 string `json:"sha256,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+type Comment struct {
+	ID          int                    `json:"id"`
+	Type        string                 `json:"type"`
+	AuthorID    int                    `json:"author_id"`
+	Body        string                 `json:"body"`
+	HTMLBody    string                 `json:"html_body"`
+	PlainBody   string                 `json:"plain_body"`
+	Public      bool                   `json:"public"`
+	CreatedAt   string                 `json:"created_at"`
+	AuditID     int                    `json:"audit_id"`
+	Attachments []Attachment           `json:"attachments"`
+	Via         Via                    `json:"via"`
+	Metadata    map[string]interface{} `json:"metadata"`
+}
+
+type Audit struct {
+	ID        int                      `json:"id"`
+	TicketID  int                      `json:"ticket_id"`
+	AuthorID  int                      `json:"author_id"`
+	CreatedAt string                   `json:"created_at"`
+	Via       Via                      `json:"via"`
+	Metadata  map[string]interface{}   `json:"metadata"`
+	Events    []map[string]interface{} `json:"events"`
+}
+
+// cursorPagination is the part of a cursor-paginated list response that
+// points to the next page.
+type cursorPagination struct {
+	Meta struct {
+		HasMore     bool   `json:"has_more"`
+		AfterCursor string `json:"after_cursor"`
+	} `json:"meta"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+func (c cursorPagination) nextPage() (string, bool) {
+	if !c.Meta.HasMore || c.Links.Next == "" {
+		return "", false
+	}
+	return c.Links.Next, true
+}
+
+// getTicketComments pages through all comments of a ticket, oldest first.
+func (zd *ZendeskConfig) getTicketComments(ctx context.Context, ticketId int, callback func(Comment) error) error {
+	endpoint := fmt.Sprintf("%s/api/v2/tickets/%d/comments.json?page[size]=100", zd.Domain, ticketId)
+
+	for {
+		var result struct {
+			cursorPagination
+			Comments []Comment `json:"comments"`
+		}
+		if err := zd.requestJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return errors.Wrapf(err, "could not fetch comments for ticket %d", ticketId)
+		}
+
+		for _, comment := range result.Comments {
+			if err := callback(comment); err != nil {
+				return err
+			}
+		}
+
+		next, ok := result.nextPage()
+		if !ok {
+			return nil
+		}
+		endpoint = next
+	}
+}
+
+// getTicketAudits pages through all audits of a ticket, oldest first.
+func (zd *ZendeskConfig) getTicketAudits(ctx context.Context, ticketId int, callback func(Audit) error) error {
+	endpoint := fmt.Sprintf("%s/api/v2/tickets/%d/audits.json?page[size]=100", zd.Domain, ticketId)
+
+	for {
+		var result struct {
+			cursorPagination
+			Audits []Audit `json:"audits"`
+		}
+		if err := zd.requestJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return errors.Wrapf(err, "could not fetch audits for ticket %d", ticketId)
+		}
+
+		for _, audit := range result.Audits {
+			if err := callback(audit); err != nil {
+				return err
+			}
+		}
+
+		next, ok := result.nextPage()
+		if !ok {
+			return nil
+		}
+		endpoint = next
+	}
+}
+
+// downloadAttachment stores the attachment content in dir, addressed by its
+// sha256: dir/ab/abcdef.... Identical files attached to several comments are
+// only stored once. The SHA256 and Path fields of attachment are filled in.
+func (zd *ZendeskConfig) downloadAttachment(ctx context.Context, attachment *Attachment, dir string) error {
+	response, err := zd.request(ctx, http.MethodGet, attachment.ContentURL, nil)
+	if err != nil {
+		return errors.Wrapf(err, "could not download attachment %d", attachment.ID)
+	}
+	defer func() {
+		_ = response.Close()
+	}()
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "attachment-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, h), response); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "could not download attachment %d", attachment.ID)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	path := filepath.Join(dir, sum[:2], sum)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return err
+		}
+	}
+
+	attachment.SHA256 = sum
+	attachment.Path = path
+	return nil
+}
+
+// downloadCommentAttachments downloads all attachments of comment into dir.
+func (zd *ZendeskConfig) downloadCommentAttachments(ctx context.Context, comment *Comment, dir string) error {
+	for i := range comment.Attachments {
+		if err := zd.downloadAttachment(ctx, &comment.Attachments[i], dir); err != nil {
+			return err
+		}
+	}
+	return nil
+}
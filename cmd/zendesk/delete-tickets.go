@@ -2,16 +2,17 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/go-go-golems/glazed/pkg/cmds"
 	"github.com/go-go-golems/glazed/pkg/cmds/layers"
 	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
 	"github.com/go-go-golems/glazed/pkg/settings"
-	"github.com/go-go-golems/go-go-labs/cmd/zendesk/workerpool"
 	"github.com/pkg/errors"
 	"io"
 	"os"
-	"time"
+	"path/filepath"
+	"strings"
 )
 
 type DeleteTicketsCommand struct {
@@ -40,27 +41,37 @@ func NewDeleteTicketsCommand() (*DeleteTicketsCommand, error) {
 					parameters.WithHelp("File containing a list of tickets to delete."),
 				),
 				parameters.NewParameterDefinition(
-					"domain",
-					parameters.ParameterTypeString,
-					parameters.WithHelp("Zendesk domain."),
+					"workers",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Number of workers to use."),
+					parameters.WithDefault(8),
 				),
 				parameters.NewParameterDefinition(
-					"email",
-					parameters.ParameterTypeString,
-					parameters.WithHelp("Zendesk email."),
+					"rate-limit",
+					parameters.ParameterTypeFloat,
+					parameters.WithHelp("Maximum number of batches started per second, across all workers (0 for no limit)."),
+					parameters.WithDefault(0.0),
 				),
 				parameters.NewParameterDefinition(
-					"api-token",
+					"dry-run",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Only print the tickets that would be deleted."),
+					parameters.WithDefault(false),
+				),
+				parameters.NewParameterDefinition(
+					"manifest",
 					parameters.ParameterTypeString,
-					parameters.WithHelp("Zendesk API token."),
+					parameters.WithHelp("Write the full payload of the tickets to this JSON file before deleting them."),
 				),
 				parameters.NewParameterDefinition(
-					"workers",
-					parameters.ParameterTypeInteger,
-					parameters.WithHelp("Number of workers to use."),
-					parameters.WithDefault(8),
+					"force",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Overwrite an existing manifest."),
+					parameters.WithDefault(false),
 				),
 			),
+			cmds.WithFlags(ticketSelectionFlags()...),
+			cmds.WithFlags(zendeskConnectionFlags()...),
 			cmds.WithLayers(
 				glazedParameterLayer,
 			),
@@ -74,126 +85,143 @@ func (c *DeleteTicketsCommand) RunIntoWriter(
 	ps map[string]interface{},
 	w io.Writer,
 ) error {
-	// Extract flags from ps map
-	ticketIds_, ok := ps["ids"]
-	var ticketIds []int
-	if ok {
-		ticketIds = ticketIds_.([]int)
+	ticketIds, err := getTicketIdsFromParameters(ps)
+	if err != nil {
+		return err
 	}
 
-	ticketsFromFile_, ok := ps["tickets-file"]
-	var ticketsFromFile []interface{}
-	if ok {
-		ticketsFromFile = ticketsFromFile_.([]interface{})
-		for _, ticket_ := range ticketsFromFile {
-			ticket, ok := ticket_.(map[string]interface{})
-			if !ok {
-				return fmt.Errorf("could not convert ticket to map[string]interface{}")
-			}
-			ticketId, ok := ticket["id"].(float64)
-			if !ok {
-				return fmt.Errorf("could not convert ticket ID to int")
-			}
-			ticketIds = append(ticketIds, int(ticketId))
-		}
-	}
+	zd := newZendeskConfigFromParameters(ps)
+	dryRun := ps["dry-run"].(bool)
+	manifest, _ := ps["manifest"].(string)
+	force, _ := ps["force"].(bool)
 
-	if len(ticketIds) == 0 {
-		return fmt.Errorf("no ticket IDs specified")
+	// check before fetching anything, the manifest of an earlier deletion
+	// is the only way to restore its tickets
+	if manifest != "" && !force {
+		if _, err := os.Stat(manifest); err == nil {
+			return errors.Errorf("manifest %s already exists, use --force to overwrite it", manifest)
+		}
 	}
 
-	domain_, ok := ps["domain"]
-	var domain string
-	if ok {
-		domain = domain_.(string)
-	}
-	email_, ok := ps["email"]
-	var email string
-	if ok {
-		email = email_.(string)
-	}
-	apiToken_, ok := ps["api-token"]
-	var apiToken string
-	if ok {
-		apiToken = apiToken_.(string)
+	var tickets []Ticket
+	switch {
+	case len(ticketIds) > 0:
+		if dryRun || manifest != "" {
+			tickets, err = zd.getTicketsByIds(ctx, ticketIds)
+			if err != nil {
+				return errors.Wrap(err, "could not fetch tickets to delete")
+			}
+		}
+	case hasTicketSelection(ps):
+		query := getTicketQueryFromParameters(ps)
+		tickets, err = zd.getIncrementalTickets(ctx, query)
+		if err != nil {
+			return errors.Wrap(err, "could not select tickets to delete")
+		}
+		for _, ticket := range tickets {
+			ticketIds = append(ticketIds, ticket.ID)
+		}
+	default:
+		return fmt.Errorf("no ticket IDs or selection specified")
 	}
 
-	// If flags are not set, use environment variables
-	if domain == "" {
-		domain = os.Getenv("ZENDESK_DOMAIN")
-	}
-	if email == "" {
-		email = os.Getenv("ZENDESK_EMAIL")
-	}
-	if apiToken == "" {
-		apiToken = os.Getenv("ZENDESK_API_TOKEN")
+	if len(ticketIds) == 0 {
+		_, _ = fmt.Fprintln(w, "No tickets to delete")
+		return nil
 	}
 
-	zd := &ZendeskConfig{
-		Domain:   domain,
-		Email:    email,
-		ApiToken: apiToken,
+	if manifest != "" {
+		if err := writeTicketManifest(manifest, tickets); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "Wrote %d tickets to manifest %s\n", len(tickets), manifest)
 	}
 
-	// split ticketIds in group of 100 tickets
-	var ticketIdGroups [][]int
-	for i := 0; i < len(ticketIds); i += 100 {
-		end := i + 100
-		if end > len(ticketIds) {
-			end = len(ticketIds)
+	if dryRun {
+		for _, ticket := range tickets {
+			_, _ = fmt.Fprintf(w, "Would delete ticket %d (%s, created %s): %s\n",
+				ticket.ID, ticket.Status, ticket.CreatedAt, ticket.Subject)
 		}
-		ticketIdGroups = append(ticketIdGroups, ticketIds[i:end])
+		_, _ = fmt.Fprintf(w, "Would delete %d tickets\n", len(ticketIds))
+		return nil
 	}
 
 	workers := ps["workers"].(int)
+	rateLimit, _ := ps["rate-limit"].(float64)
 	fmt.Printf("Using %d workers\n", workers)
-	pool := workerpool.New(workers) // For example, 5 workers
-	pool.Start()
 
+	// failed batches are collected instead of aborting the whole run, so that
+	// they can be retried with the list printed at the end.
+	var failedBatches []bulkBatchResult
+	batchCount := 0
 	total := 0
-	for _, ticketIdGroup := range ticketIdGroups {
-		ticketIdGroupCopy := ticketIdGroup // Create a copy to avoid closure over the loop variable
-		job := func() error {
-			firstId := ticketIdGroupCopy[0]
-			lastId := ticketIdGroupCopy[len(ticketIdGroupCopy)-1]
-			fmt.Printf("About to delete %d (%d/%d) tickets, first: %d, last: %d\n",
-				len(ticketIdGroupCopy),
-				total, len(ticketIds),
-				firstId, lastId)
-
-			jobStatus, err := zd.bulkDeleteTickets(ticketIdGroupCopy)
-			if err != nil {
-				return err
-			}
 
-			retryCnt := 0
-			// poll jobStatus and print to the writer (assuming w is your writer)
-			for {
-				jobStatus, err := zd.getJobStatus(jobStatus.ID)
-				if err != nil {
-					if retryCnt < 3 {
-						retryCnt++
-						continue
-					}
-					return errors.Wrapf(err, "failed to get job status for job ID %s", jobStatus.ID)
-				}
-
-				_, _ = fmt.Fprintf(w, "Job ID: %s, Status: %s, Progress: %d%%, Message: %s\n",
-					jobStatus.ID, jobStatus.Status, jobStatus.Progress, jobStatus.Message)
-
-				if jobStatus.Status == "completed" || jobStatus.Status == "failed" {
-					total += len(ticketIdGroupCopy)
-					break
-				}
-				time.Sleep(time.Second) // Optional: Add a sleep for regular intervals to avoid aggressive polling
-			}
+	submit := func(ctx context.Context, ticketIds []int) (*JobStatus, error) {
+		fmt.Printf("About to delete %d tickets, first: %d, last: %d\n",
+			len(ticketIds), ticketIds[0], ticketIds[len(ticketIds)-1])
+		return zd.bulkDeleteTickets(ctx, ticketIds)
+	}
 
-			return nil
+	err = runBulkJobs(ctx, zd, ticketIds, workers, rateLimit, submit, func(result bulkBatchResult) {
+		batchCount++
+		if result.Err != nil {
+			failedBatches = append(failedBatches, result)
+			return
 		}
 
-		pool.AddJob(job)
+		total += len(result.TicketIds)
+		jobStatus := result.JobStatus
+		_, _ = fmt.Fprintf(w, "Job ID: %s, Status: %s, Progress: %d%%, Message: %s (%d/%d)\n",
+			jobStatus.ID, jobStatus.Status, jobStatus.Progress, jobStatus.Message,
+			total, len(ticketIds))
+	})
+
+	_, _ = fmt.Fprintf(w, "Deleted %d of %d tickets\n", total, len(ticketIds))
+
+	if len(failedBatches) > 0 {
+		failedCount := 0
+		for _, batch := range failedBatches {
+			failedCount += len(batch.TicketIds)
+			_, _ = fmt.Fprintf(w, "Failed to delete %d tickets: %v\n  ids: %s\n",
+				len(batch.TicketIds), batch.Err, strings.Join(convertIntsToStrings(batch.TicketIds), ","))
+		}
+		return errors.Wrapf(err, "%d of %d batches (%d tickets) failed to delete", len(failedBatches), batchCount, failedCount)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "deleted only %d of %d tickets", total, len(ticketIds))
 	}
-	pool.Close()
 
 	return nil
 }
+
+// writeTicketManifest saves the full payload of tickets as a JSON list, which
+// can be passed to undelete --manifest or delete-tickets --tickets-file.
+//
+// The manifest is written to a temporary file first, so that an interrupted
+// run never leaves a truncated manifest behind.
+func writeTicketManifest(path string, tickets []Ticket) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return errors.Wrapf(err, "could not create manifest %s", path)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	encoder := json.NewEncoder(tmp)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(tickets); err != nil {
+		_ = tmp.Close()
+		return errors.Wrapf(err, "could not write manifest %s", path)
+	}
+
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,185 @@
+package fakezendesk
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// searchTerm is one term of a search query: a field, an operator (":", "<"
+// or ">") and a value. Terms without an operator have an empty field and
+// match the subject, description or name.
+type searchTerm struct {
+	Field    string
+	Operator string
+	Value    string
+}
+
+// parseQuery splits a query like `tags:spam status:solved "created<2021-01-01"`
+// into terms. All terms have to match.
+func parseQuery(query string) []searchTerm {
+	var terms []searchTerm
+	for _, word := range splitQuery(query) {
+		term := searchTerm{Value: word}
+		if i := strings.IndexAny(word, ":<>"); i > 0 {
+			term = searchTerm{Field: word[:i], Operator: word[i : i+1], Value: word[i+1:]}
+		}
+		terms = append(terms, term)
+	}
+	return terms
+}
+
+// splitQuery splits on spaces, keeping double-quoted parts together.
+func splitQuery(query string) []string {
+	var words []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range query {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == ' ' && !quoted:
+			if current.Len() > 0 {
+				words = append(words, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		words = append(words, current.String())
+	}
+	return words
+}
+
+// matches checks a term against an object. "created" and "updated" compare
+// the date part of created_at and updated_at, "tags" and "tag" look for the
+// value in the tags list, every other field is compared as a string.
+func (t searchTerm) matches(object map[string]interface{}) bool {
+	if t.Field == "" {
+		for _, key := range []string{"subject", "description", "name"} {
+			if s, ok := object[key].(string); ok && strings.Contains(strings.ToLower(s), strings.ToLower(t.Value)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	field := t.Field
+	switch field {
+	case "tag", "tags":
+		tags, _ := object["tags"].([]interface{})
+		for _, tag := range tags {
+			if tag == t.Value {
+				return true
+			}
+		}
+		return false
+	case "created", "updated":
+		field += "_at"
+	}
+
+	value := fmt.Sprint(object[field])
+	if field == "created_at" || field == "updated_at" {
+		if len(value) >= 10 {
+			value = value[:10]
+		}
+	}
+
+	switch t.Operator {
+	case "<":
+		return value < t.Value
+	case ">":
+		return value > t.Value
+	default:
+		return strings.EqualFold(value, t.Value)
+	}
+}
+
+// handleSearchExport answers search/export queries, sorted by ID. The cursor
+// is the ID of the last result of the previous page.
+func (s *Server) handleSearchExport(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	s.mu.Lock()
+	var objects []map[string]interface{}
+	switch q.Get("filter[type]") {
+	case "ticket":
+		for _, ticket := range s.tickets {
+			objects = append(objects, ticket)
+		}
+	case "user":
+		objects = append(objects, s.users...)
+	case "organization":
+		objects = append(objects, s.organizations...)
+	default:
+		s.mu.Unlock()
+		writeError(w, http.StatusBadRequest, "filter[type] is required")
+		return
+	}
+	s.mu.Unlock()
+
+	pageSize := 100
+	if size, err := strconv.Atoi(q.Get("page[size]")); err == nil && size > 0 {
+		pageSize = size
+	}
+	after := -1
+	if cursor := q.Get("page[after]"); cursor != "" {
+		id, err := strconv.Atoi(cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		after = id
+	}
+
+	terms := parseQuery(q.Get("query"))
+	results := []map[string]interface{}{}
+	for _, object := range objects {
+		if objectID(object) <= after {
+			continue
+		}
+		matched := true
+		for _, term := range terms {
+			if !term.matches(object) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			results = append(results, object)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return objectID(results[i]) < objectID(results[j]) })
+
+	hasMore := len(results) > pageSize
+	if hasMore {
+		results = results[:pageSize]
+	}
+
+	meta := map[string]interface{}{"has_more": hasMore}
+	links := map[string]interface{}{"next": nil}
+	if hasMore {
+		afterCursor := strconv.Itoa(objectID(results[len(results)-1]))
+		next := *r.URL
+		next.Scheme, next.Host = "http", r.Host
+		nq := url.Values{}
+		for k, v := range q {
+			nq[k] = v
+		}
+		nq.Set("page[after]", afterCursor)
+		next.RawQuery = nq.Encode()
+		meta["after_cursor"] = afterCursor
+		links["next"] = next.String()
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"results": results,
+		"meta":    meta,
+		"links":   links,
+	})
+}
@@ -0,0 +1,845 @@
+// Package fakezendesk is an in-process fake of the parts of the Zendesk API
+// used by the zendesk command, for tests.
+//
+// It serves tickets and users from a fixture set through the cursor-based
+// incremental exports, organizations through the time-based one, the single
+// ticket and show_many endpoints, serves the ticket field
+// definitions set with SetTicketFields, the comments and audits of tickets
+// and attachment contents, answers a subset of the search query
+// syntax through search/export, and runs destroy_many,
+// update_many and restore_many as background jobs that can be polled through
+// job_statuses. Rate limits, slow jobs and failing jobs can be injected.
+package fakezendesk
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Ticket is a ticket fixture, as returned by the Zendesk API.
+type Ticket = map[string]interface{}
+
+// TicketField is a ticket field fixture, as returned by the Zendesk API.
+type TicketField = map[string]interface{}
+
+// Comment and Audit are comment and audit fixtures, as returned by the
+// Zendesk API.
+type Comment = map[string]interface{}
+type Audit = map[string]interface{}
+
+// User and Organization are user and organization fixtures, as returned by
+// the Zendesk API.
+type User = map[string]interface{}
+type Organization = map[string]interface{}
+
+type job struct {
+	ID        string
+	Action    string
+	TicketIds []int
+	// Update is the ticket object sent to update_many
+	Update map[string]interface{}
+	// missing are the tickets that did not exist when the job ran
+	missing map[int]bool
+	// polls is the number of remaining polls before the job completes
+	polls  int
+	fail   bool
+	status string
+}
+
+type Server struct {
+	*httptest.Server
+
+	mu sync.Mutex
+	// tickets are the current tickets, deleted are the soft-deleted ones
+	tickets map[int]Ticket
+	deleted map[int]Ticket
+	fields  []TicketField
+
+	comments    map[int][]Comment
+	audits      map[int][]Audit
+	attachments map[string][]byte
+
+	users         []User
+	organizations []Organization
+	jobs          map[string]*job
+	nextJob       int
+
+	pageSize   int
+	jobPolls   int
+	failJobs   bool
+	rateLimits int
+	retryAfter string
+
+	requests map[string]int
+}
+
+// New starts a fake Zendesk server serving tickets. Close it when done.
+func New(tickets []Ticket) *Server {
+	s := &Server{
+		tickets:     map[int]Ticket{},
+		deleted:     map[int]Ticket{},
+		comments:    map[int][]Comment{},
+		audits:      map[int][]Audit{},
+		attachments: map[string][]byte{},
+		jobs:        map[string]*job{},
+		pageSize:    100,
+		requests:    map[string]int{},
+	}
+	for _, ticket := range tickets {
+		s.tickets[objectID(ticket)] = ticket
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/incremental/tickets/cursor.json", s.handleIncrementalTickets)
+	mux.HandleFunc("/api/v2/incremental/users/cursor.json", s.handleIncrementalUsers)
+	mux.HandleFunc("/api/v2/incremental/organizations.json", s.handleIncrementalOrganizations)
+	mux.HandleFunc("/api/v2/tickets/show_many.json", s.handleShowMany)
+	mux.HandleFunc("/api/v2/tickets/destroy_many.json", s.handleDestroyMany)
+	mux.HandleFunc("/api/v2/tickets/update_many.json", s.handleUpdateMany)
+	mux.HandleFunc("/api/v2/deleted_tickets/restore_many.json", s.handleRestoreMany)
+	mux.HandleFunc("/api/v2/tickets/", s.handleTicket)
+	mux.HandleFunc("/api/v2/job_statuses/", s.handleJobStatus)
+	mux.HandleFunc("/api/v2/ticket_fields.json", s.handleTicketFields)
+	mux.HandleFunc("/api/v2/search/export.json", s.handleSearchExport)
+	mux.HandleFunc("/attachments/", s.handleAttachment)
+
+	s.Server = httptest.NewServer(s.middleware(mux))
+	return s
+}
+
+// LoadTickets reads a JSON list of ticket fixtures.
+func LoadTickets(path string) ([]Ticket, error) {
+	return loadFixtures(path)
+}
+
+// LoadTicketFields reads a JSON list of ticket field fixtures.
+func LoadTicketFields(path string) ([]TicketField, error) {
+	return loadFixtures(path)
+}
+
+// LoadUsers reads a JSON list of user fixtures.
+func LoadUsers(path string) ([]User, error) {
+	return loadFixtures(path)
+}
+
+// LoadOrganizations reads a JSON list of organization fixtures.
+func LoadOrganizations(path string) ([]Organization, error) {
+	return loadFixtures(path)
+}
+
+func loadFixtures(path string) ([]map[string]interface{}, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []map[string]interface{}
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		return nil, fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return fixtures, nil
+}
+
+// SetUsers sets the users returned by user searches and the users export.
+func (s *Server) SetUsers(users []User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users = users
+}
+
+// SetOrganizations sets the organizations returned by organization searches
+// and the organizations export.
+func (s *Server) SetOrganizations(organizations []Organization) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.organizations = organizations
+}
+
+// SetTicketFields sets the ticket field definitions served by ticket_fields.
+func (s *Server) SetTicketFields(fields []TicketField) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fields = fields
+}
+
+// SetComments sets the comments of a ticket, oldest first.
+func (s *Server) SetComments(ticketID int, comments []Comment) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.comments[ticketID] = comments
+}
+
+// SetAudits sets the audits of a ticket, oldest first.
+func (s *Server) SetAudits(ticketID int, audits []Audit) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.audits[ticketID] = audits
+}
+
+// AddAttachment stores the content of an attachment and returns its
+// content_url.
+func (s *Server) AddAttachment(name string, content []byte) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := "/attachments/" + name
+	s.attachments[path] = content
+	return s.URL + path
+}
+
+// AddTicket adds or replaces a ticket.
+func (s *Server) AddTicket(ticket Ticket) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tickets[objectID(ticket)] = ticket
+}
+
+// SetPageSize sets the number of objects per incremental export page, and the
+// maximum number of comments or audits per page.
+func (s *Server) SetPageSize(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pageSize = n
+}
+
+// InjectRateLimits makes the next n requests fail with a 429 and the given
+// Retry-After header (in seconds, may be empty).
+func (s *Server) InjectRateLimits(n int, retryAfter string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rateLimits = n
+	s.retryAfter = retryAfter
+}
+
+// SetJobPolls makes every new job report "working" for n polls before it
+// completes.
+func (s *Server) SetJobPolls(n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobPolls = n
+}
+
+// SetFailJobs makes every new job fail instead of completing.
+func (s *Server) SetFailJobs(fail bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failJobs = fail
+}
+
+// Requests returns the number of requests received for a path, including
+// rate-limited ones.
+func (s *Server) Requests(path string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[path]
+}
+
+// Ticket returns the current version of a ticket that is not deleted.
+func (s *Server) Ticket(id int) (Ticket, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[id]
+	return ticket, ok
+}
+
+// TicketIDs returns the sorted IDs of the tickets that are not deleted.
+func (s *Server) TicketIDs() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedIDs(s.tickets)
+}
+
+// DeletedTicketIDs returns the sorted IDs of the soft-deleted tickets.
+func (s *Server) DeletedTicketIDs() []int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sortedIDs(s.deleted)
+}
+
+func (s *Server) middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests[r.URL.Path]++
+		rateLimited := s.rateLimits > 0
+		if rateLimited {
+			s.rateLimits--
+		}
+		retryAfter := s.retryAfter
+		s.mu.Unlock()
+
+		if email, token, ok := r.BasicAuth(); !ok || email == "" || token == "" {
+			writeError(w, http.StatusUnauthorized, "Couldn't authenticate you")
+			return
+		}
+
+		if rateLimited {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			writeError(w, http.StatusTooManyRequests, "Too many requests")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// exportKey orders tickets in the incremental export by update time, then ID.
+type exportKey struct {
+	UpdatedAt int64
+	ID        int
+}
+
+func (k exportKey) less(o exportKey) bool {
+	if k.UpdatedAt != o.UpdatedAt {
+		return k.UpdatedAt < o.UpdatedAt
+	}
+	return k.ID < o.ID
+}
+
+func encodeCursor(k exportKey) string {
+	return base64.URLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", k.UpdatedAt, k.ID)))
+}
+
+func decodeCursor(cursor string) (exportKey, error) {
+	b, err := base64.URLEncoding.DecodeString(cursor)
+	if err != nil {
+		return exportKey{}, err
+	}
+	var k exportKey
+	if _, err := fmt.Sscanf(string(b), "%d:%d", &k.UpdatedAt, &k.ID); err != nil {
+		return exportKey{}, err
+	}
+	return k, nil
+}
+
+func (s *Server) handleIncrementalTickets(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tickets := make([]map[string]interface{}, 0, len(s.tickets))
+	for _, ticket := range s.tickets {
+		tickets = append(tickets, ticket)
+	}
+	s.writeIncrementalCursorPage(w, r, "tickets", tickets)
+}
+
+func (s *Server) handleIncrementalUsers(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.writeIncrementalCursorPage(w, r, "users", s.users)
+}
+
+// writeIncrementalCursorPage writes the page of a cursor-based incremental
+// export that follows the cursor or start_time parameter. Must be called with
+// mu held.
+func (s *Server) writeIncrementalCursorPage(w http.ResponseWriter, r *http.Request, key string, objects []map[string]interface{}) {
+	// after is the key of the last object that was already exported
+	var after exportKey
+	if cursor := r.URL.Query().Get("cursor"); cursor != "" {
+		k, err := decodeCursor(cursor)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		after = k
+	} else {
+		startTime, err := strconv.ParseInt(r.URL.Query().Get("start_time"), 10, 64)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid start_time")
+			return
+		}
+		after = exportKey{UpdatedAt: startTime, ID: -1}
+	}
+
+	byID := map[int]map[string]interface{}{}
+	var keys []exportKey
+	for _, object := range objects {
+		k := exportKey{UpdatedAt: updatedAt(object), ID: objectID(object)}
+		if after.less(k) {
+			keys = append(keys, k)
+			byID[k.ID] = object
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].less(keys[j]) })
+
+	endOfStream := len(keys) <= s.pageSize
+	if !endOfStream {
+		keys = keys[:s.pageSize]
+	}
+
+	page := []map[string]interface{}{}
+	last := after
+	for _, k := range keys {
+		page = append(page, byID[k.ID])
+		last = k
+	}
+
+	writeJSON(w, map[string]interface{}{
+		key:             page,
+		"after_cursor":  encodeCursor(last),
+		"before_cursor": nil,
+		"end_of_stream": endOfStream,
+	})
+}
+
+// handleIncrementalOrganizations serves the time-based export. Like Zendesk,
+// it returns the organizations updated at or after start_time, and the next
+// page starts at the end_time of the current one, so the last organization of
+// a page is returned again.
+func (s *Server) handleIncrementalOrganizations(w http.ResponseWriter, r *http.Request) {
+	startTime, err := strconv.ParseInt(r.URL.Query().Get("start_time"), 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid start_time")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var organizations []Organization
+	for _, organization := range s.organizations {
+		if updatedAt(organization) >= startTime {
+			organizations = append(organizations, organization)
+		}
+	}
+	sort.Slice(organizations, func(i, j int) bool {
+		return exportKey{updatedAt(organizations[i]), objectID(organizations[i])}.
+			less(exportKey{updatedAt(organizations[j]), objectID(organizations[j])})
+	})
+
+	endOfStream := len(organizations) <= s.pageSize
+	if !endOfStream {
+		organizations = organizations[:s.pageSize]
+	}
+	endTime := startTime
+	if len(organizations) > 0 {
+		endTime = updatedAt(organizations[len(organizations)-1])
+	}
+	var nextPage interface{}
+	if !endOfStream {
+		nextPage = fmt.Sprintf("%s/api/v2/incremental/organizations.json?start_time=%d", s.URL, endTime)
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"organizations": append([]Organization{}, organizations...),
+		"end_time":      endTime,
+		"next_page":     nextPage,
+		"end_of_stream": endOfStream,
+	})
+}
+
+// handleTicket serves /api/v2/tickets/{id}.json, and the comments.json and
+// audits.json of a ticket.
+func (s *Server) handleTicket(w http.ResponseWriter, r *http.Request) {
+	id_, sub, _ := strings.Cut(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/tickets/"), ".json"), "/")
+	id, err := strconv.Atoi(id_)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "RecordNotFound")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ticket, ok := s.tickets[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "RecordNotFound")
+		return
+	}
+
+	switch sub {
+	case "":
+		writeJSON(w, map[string]interface{}{"ticket": ticket})
+	case "comments":
+		s.writeCursorPage(w, r, "comments", s.comments[id])
+	case "audits":
+		s.writeCursorPage(w, r, "audits", s.audits[id])
+	default:
+		writeError(w, http.StatusNotFound, "RecordNotFound")
+	}
+}
+
+// writeCursorPage writes the page of objects selected by the page[size] and
+// page[after] parameters of a cursor-paginated list. The cursor is the index
+// of the first object of the next page. Must be called with mu held.
+func (s *Server) writeCursorPage(w http.ResponseWriter, r *http.Request, key string, objects []map[string]interface{}) {
+	size := s.pageSize
+	if n, err := strconv.Atoi(r.URL.Query().Get("page[size]")); err == nil && n > 0 && n < size {
+		size = n
+	}
+	start := 0
+	if after := r.URL.Query().Get("page[after]"); after != "" {
+		n, err := strconv.Atoi(after)
+		if err != nil || n < 0 || n > len(objects) {
+			writeError(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		start = n
+	}
+	end := start + size
+	if end > len(objects) {
+		end = len(objects)
+	}
+
+	page := append([]map[string]interface{}{}, objects[start:end]...)
+	hasMore := end < len(objects)
+	var next interface{}
+	afterCursor := ""
+	if hasMore {
+		afterCursor = strconv.Itoa(end)
+		next = fmt.Sprintf("%s%s?page[size]=%d&page[after]=%s", s.URL, r.URL.Path, size, afterCursor)
+	}
+	writeJSON(w, map[string]interface{}{
+		key:     page,
+		"meta":  map[string]interface{}{"has_more": hasMore, "after_cursor": afterCursor},
+		"links": map[string]interface{}{"next": next},
+	})
+}
+
+func (s *Server) handleAttachment(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	content, ok := s.attachments[r.URL.Path]
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "RecordNotFound")
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	_, _ = w.Write(content)
+}
+
+// handleTicketFields serves all fields on a single cursor-paginated page.
+func (s *Server) handleTicketFields(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fields := append([]TicketField{}, s.fields...)
+	writeJSON(w, map[string]interface{}{
+		"ticket_fields": fields,
+		"meta":          map[string]interface{}{"has_more": false},
+		"links":         map[string]interface{}{"next": nil},
+	})
+}
+
+func (s *Server) handleShowMany(w http.ResponseWriter, r *http.Request) {
+	ids, err := parseIDs(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tickets := []Ticket{}
+	for _, id := range ids {
+		if ticket, ok := s.tickets[id]; ok {
+			tickets = append(tickets, ticket)
+		}
+	}
+	writeJSON(w, map[string]interface{}{"tickets": tickets})
+}
+
+func (s *Server) handleDestroyMany(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	s.startJob(w, r, "delete", nil)
+}
+
+func (s *Server) handleUpdateMany(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	var body struct {
+		Ticket map[string]interface{} `json:"ticket"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Ticket == nil {
+		writeError(w, http.StatusBadRequest, "Invalid ticket update")
+		return
+	}
+	s.startJob(w, r, "update", body.Ticket)
+}
+
+func (s *Server) handleRestoreMany(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+	ids, err := parseIDs(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		if ticket, ok := s.deleted[id]; ok {
+			s.tickets[id] = ticket
+			delete(s.deleted, id)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) startJob(w http.ResponseWriter, r *http.Request, action string, update map[string]interface{}) {
+	ids, err := parseIDs(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(ids) > 100 {
+		writeError(w, http.StatusBadRequest, "Too many ids")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextJob++
+	j := &job{
+		ID:        fmt.Sprintf("job-%d", s.nextJob),
+		Action:    action,
+		TicketIds: ids,
+		Update:    update,
+		missing:   map[int]bool{},
+		polls:     s.jobPolls,
+		fail:      s.failJobs,
+		status:    "queued",
+	}
+	s.jobs[j.ID] = j
+
+	writeJSON(w, map[string]interface{}{"job_status": s.jobStatus(j)})
+}
+
+func (s *Server) handleJobStatus(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/v2/job_statuses/"), ".json")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "RecordNotFound")
+		return
+	}
+
+	if j.status == "queued" || j.status == "working" {
+		if j.polls > 0 {
+			j.polls--
+			j.status = "working"
+		} else if j.fail {
+			j.status = "failed"
+		} else {
+			s.runJob(j)
+			j.status = "completed"
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{"job_status": s.jobStatus(j)})
+}
+
+// runJob applies the job to the tickets. Must be called with mu held.
+func (s *Server) runJob(j *job) {
+	for _, id := range j.TicketIds {
+		ticket, ok := s.tickets[id]
+		if !ok {
+			j.missing[id] = true
+			continue
+		}
+		switch j.Action {
+		case "delete":
+			s.deleted[id] = ticket
+			delete(s.tickets, id)
+		case "update":
+			applyUpdate(ticket, j.Update)
+		}
+	}
+}
+
+// applyUpdate applies the status, priority, tags and custom field changes of
+// an update_many ticket object.
+func applyUpdate(ticket Ticket, update map[string]interface{}) {
+	for _, key := range []string{"status", "priority"} {
+		if v, ok := update[key]; ok {
+			ticket[key] = v
+		}
+	}
+
+	tags := stringList(ticket["tags"])
+	if v, ok := update["tags"]; ok {
+		tags = stringList(v)
+	}
+	for _, tag := range stringList(update["additional_tags"]) {
+		if !contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	remove := stringList(update["remove_tags"])
+	kept := []interface{}{}
+	for _, tag := range tags {
+		if !contains(remove, tag) {
+			kept = append(kept, tag)
+		}
+	}
+	ticket["tags"] = kept
+
+	customFields, _ := ticket["custom_fields"].([]interface{})
+	updates, _ := update["custom_fields"].([]interface{})
+	for _, u_ := range updates {
+		u, ok := u_.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		replaced := false
+		for _, f_ := range customFields {
+			if f, ok := f_.(map[string]interface{}); ok && objectID(f) == objectID(u) {
+				f["value"] = u["value"]
+				replaced = true
+			}
+		}
+		if !replaced {
+			customFields = append(customFields, map[string]interface{}{"id": u["id"], "value": u["value"]})
+		}
+	}
+	if customFields != nil {
+		ticket["custom_fields"] = customFields
+	}
+}
+
+func stringList(v interface{}) []string {
+	var ret []string
+	list, _ := v.([]interface{})
+	for _, s := range list {
+		if s, ok := s.(string); ok {
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) jobStatus(j *job) map[string]interface{} {
+	progress := 0
+	var results []map[string]interface{}
+	switch j.status {
+	case "completed":
+		progress = len(j.TicketIds)
+		status := "Deleted"
+		if j.Action == "update" {
+			status = "Updated"
+		}
+		for i, id := range j.TicketIds {
+			result := map[string]interface{}{
+				"id":      id,
+				"index":   i,
+				"action":  j.Action,
+				"success": true,
+				"status":  status,
+			}
+			if j.missing[id] {
+				result["success"] = false
+				result["status"] = ""
+				result["error"] = "TicketNotFound"
+			}
+			results = append(results, result)
+		}
+	case "failed":
+		reason := "TicketDeleteFailed"
+		if j.Action == "update" {
+			reason = "TicketUpdateFailed"
+		}
+		for i, id := range j.TicketIds {
+			results = append(results, map[string]interface{}{
+				"id":      id,
+				"index":   i,
+				"action":  j.Action,
+				"success": false,
+				"error":   reason,
+			})
+		}
+	}
+
+	message := ""
+	if j.status == "failed" {
+		message = "Job failed"
+	}
+
+	return map[string]interface{}{
+		"id":       j.ID,
+		"status":   j.status,
+		"total":    len(j.TicketIds),
+		"progress": progress,
+		"message":  message,
+		"results":  results,
+		"url":      fmt.Sprintf("%s/api/v2/job_statuses/%s.json", s.URL, j.ID),
+	}
+}
+
+func parseIDs(r *http.Request) ([]int, error) {
+	var ids []int
+	for _, id_ := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		id, err := strconv.Atoi(id_)
+		if err != nil {
+			return nil, fmt.Errorf("invalid id %q", id_)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// objectID returns the id of a ticket, user or organization fixture.
+func objectID(object map[string]interface{}) int {
+	switch id := object["id"].(type) {
+	case float64:
+		return int(id)
+	case int:
+		return id
+	default:
+		return 0
+	}
+}
+
+// updatedAt returns the updated_at of a ticket, user or organization fixture,
+// in seconds.
+func updatedAt(object map[string]interface{}) int64 {
+	s, _ := object["updated_at"].(string)
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return 0
+	}
+	return t.Unix()
+}
+
+func sortedIDs(tickets map[int]Ticket) []int {
+	ids := make([]int, 0, len(tickets))
+	for id := range tickets {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": message})
+}
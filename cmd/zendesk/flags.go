@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"os"
+	"time"
+)
+
+// zendeskConnectionFlags are the flags shared by every command that talks to Zendesk.
+func zendeskConnectionFlags() []*parameters.ParameterDefinition {
+	return []*parameters.ParameterDefinition{
+		parameters.NewParameterDefinition(
+			"domain",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Zendesk domain."),
+		),
+		parameters.NewParameterDefinition(
+			"email",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Zendesk email."),
+		),
+		parameters.NewParameterDefinition(
+			"api-token",
+			parameters.ParameterTypeString,
+			parameters.WithHelp("Zendesk API token."),
+		),
+	}
+}
+
+// newZendeskConfigFromParameters builds a ZendeskConfig from the connection flags,
+// falling back to the ZENDESK_DOMAIN, ZENDESK_EMAIL and ZENDESK_API_TOKEN
+// environment variables.
+func newZendeskConfigFromParameters(ps map[string]interface{}) *ZendeskConfig {
+	domain, _ := ps["domain"].(string)
+	email, _ := ps["email"].(string)
+	apiToken, _ := ps["api-token"].(string)
+
+	// If flags are not set, use environment variables
+	if domain == "" {
+		domain = os.Getenv("ZENDESK_DOMAIN")
+	}
+	if email == "" {
+		email = os.Getenv("ZENDESK_EMAIL")
+	}
+	if apiToken == "" {
+		apiToken = os.Getenv("ZENDESK_API_TOKEN")
+	}
+
+	return &ZendeskConfig{
+		Domain:   domain,
+		Email:    email,
+		ApiToken: apiToken,
+	}
+}
+
+// getTicketIdsFromParameters collects ticket IDs from the ids and tickets-file flags.
+func getTicketIdsFromParameters(ps map[string]interface{}) ([]int, error) {
+	var ticketIds []int
+	if ticketIds_, ok := ps["ids"].([]int); ok {
+		ticketIds = append(ticketIds, ticketIds_...)
+	}
+
+	if ticketsFromFile_, ok := ps["tickets-file"].([]interface{}); ok {
+		for _, ticket_ := range ticketsFromFile_ {
+			ticket, ok := ticket_.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("could not convert ticket to map[string]interface{}")
+			}
+			ticketId, ok := ticket["id"].(float64)
+			if !ok {
+				return nil, fmt.Errorf("could not convert ticket ID to int")
+			}
+			ticketIds = append(ticketIds, int(ticketId))
+		}
+	}
+
+	return ticketIds, nil
+}
+
+// ticketSelectionFlags are the flags used to select tickets from the incremental export.
+func ticketSelectionFlags() []*parameters.ParameterDefinition {
+	return []*parameters.ParameterDefinition{
+		parameters.NewParameterDefinition(
+			"start-date",
+			parameters.ParameterTypeDate,
+			parameters.WithHelp("Specify the start time from when you want to start fetching tickets."),
+		),
+		parameters.NewParameterDefinition(
+			"end-date",
+			parameters.ParameterTypeDate,
+			parameters.WithHelp("Specify the end time until when you want to fetch tickets."),
+		),
+		parameters.NewParameterDefinition(
+			"status",
+			parameters.ParameterTypeStringList,
+			parameters.WithHelp("Only select tickets with one of these statuses."),
+		),
+		parameters.NewParameterDefinition(
+			"tags",
+			parameters.ParameterTypeStringList,
+			parameters.WithHelp("Only select tickets that have all of these tags."),
+		),
+	}
+}
+
+// hasTicketSelection returns true if any of the ticketSelectionFlags was given.
+func hasTicketSelection(ps map[string]interface{}) bool {
+	for _, name := range []string{"start-date", "end-date", "status", "tags"} {
+		if _, ok := ps[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// getTicketQueryFromParameters builds a Query from the ticketSelectionFlags.
+func getTicketQueryFromParameters(ps map[string]interface{}) Query {
+	startDate_, ok := ps["start-date"]
+
+	var startDate time.Time
+	if ok {
+		startDate = startDate_.(time.Time)
+	} else {
+		// set to 2010-01-01 per default
+		startDate = time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	endDate_, ok := ps["end-date"]
+	var endDate time.Time
+	if ok {
+		endDate = endDate_.(time.Time)
+	} else {
+		// set to now per default
+		endDate = time.Now()
+	}
+
+	statuses, _ := ps["status"].([]string)
+	tags, _ := ps["tags"].([]string)
+
+	return Query{
+		StartDate: startDate,
+		EndDate:   endDate,
+		Statuses:  statuses,
+		Tags:      tags,
+	}
+}
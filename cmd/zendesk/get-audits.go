@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+)
+
+type GetAuditsCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewGetAuditsCommand() (*GetAuditsCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &GetAuditsCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"get-audits",
+			cmds.WithShort("Fetch the audit trail of tickets from Zendesk"),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"ids",
+					parameters.ParameterTypeIntegerList,
+					parameters.WithHelp("List of ticket IDs to fetch audits for."),
+				),
+				parameters.NewParameterDefinition(
+					"tickets-file",
+					parameters.ParameterTypeObjectListFromFile,
+					parameters.WithHelp("File containing a list of tickets to fetch audits for."),
+				),
+			),
+			cmds.WithFlags(zendeskConnectionFlags()...),
+			cmds.WithLayers(
+				glazedParameterLayer,
+			),
+		),
+	}, nil
+}
+
+func (c *GetAuditsCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	ticketIds, err := getTicketIdsFromParameters(ps)
+	if err != nil {
+		return err
+	}
+	if len(ticketIds) == 0 {
+		return fmt.Errorf("no ticket IDs specified")
+	}
+
+	zd := newZendeskConfigFromParameters(ps)
+
+	for _, ticketId := range ticketIds {
+		err := zd.getTicketAudits(ctx, ticketId, func(audit Audit) error {
+			row := types.NewRow(
+				types.MRP("ticket_id", ticketId),
+				types.MRP("id", audit.ID),
+				types.MRP("author_id", audit.AuthorID),
+				types.MRP("created_at", audit.CreatedAt),
+				types.MRP("via", audit.Via),
+				types.MRP("metadata", audit.Metadata),
+				types.MRP("events", audit.Events),
+			)
+			return gp.AddRow(ctx, row)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+)
+
+type GetCommentsCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewGetCommentsCommand() (*GetCommentsCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &GetCommentsCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"get-comments",
+			cmds.WithShort("Fetch the comments of tickets from Zendesk"),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"ids",
+					parameters.ParameterTypeIntegerList,
+					parameters.WithHelp("List of ticket IDs to fetch comments for."),
+				),
+				parameters.NewParameterDefinition(
+					"tickets-file",
+					parameters.ParameterTypeObjectListFromFile,
+					parameters.WithHelp("File containing a list of tickets to fetch comments for."),
+				),
+				parameters.NewParameterDefinition(
+					"attachments-dir",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Download attachments into this content-addressed directory."),
+				),
+			),
+			cmds.WithFlags(zendeskConnectionFlags()...),
+			cmds.WithLayers(
+				glazedParameterLayer,
+			),
+		),
+	}, nil
+}
+
+func commentToRow(ticketId int, comment Comment) types.Row {
+	return types.NewRow(
+		types.MRP("ticket_id", ticketId),
+		types.MRP("id", comment.ID),
+		types.MRP("type", comment.Type),
+		types.MRP("author_id", comment.AuthorID),
+		types.MRP("public", comment.Public),
+		types.MRP("created_at", comment.CreatedAt),
+		types.MRP("body", comment.Body),
+		types.MRP("html_body", comment.HTMLBody),
+		types.MRP("plain_body", comment.PlainBody),
+		types.MRP("audit_id", comment.AuditID),
+		types.MRP("attachments", comment.Attachments),
+		types.MRP("via", comment.Via),
+		types.MRP("metadata", comment.Metadata),
+	)
+}
+
+func (c *GetCommentsCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	ticketIds, err := getTicketIdsFromParameters(ps)
+	if err != nil {
+		return err
+	}
+	if len(ticketIds) == 0 {
+		return fmt.Errorf("no ticket IDs specified")
+	}
+
+	attachmentsDir, _ := ps["attachments-dir"].(string)
+
+	zd := newZendeskConfigFromParameters(ps)
+
+	for _, ticketId := range ticketIds {
+		err := zd.getTicketComments(ctx, ticketId, func(comment Comment) error {
+			if attachmentsDir != "" {
+				if err := zd.downloadCommentAttachments(ctx, &comment, attachmentsDir); err != nil {
+					return err
+				}
+			}
+			return gp.AddRow(ctx, commentToRow(ticketId, comment))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -9,7 +9,7 @@ import (
 	"github.com/go-go-golems/glazed/pkg/settings"
 	"github.com/go-go-golems/glazed/pkg/types"
 	"github.com/pkg/errors"
-	"os"
+	"github.com/rs/zerolog/log"
 	"time"
 )
 
@@ -27,44 +27,43 @@ func NewGetTicketsCommand() (*GetTicketsCommand, error) {
 		CommandDescription: cmds.NewCommandDescription(
 			"get-tickets",
 			cmds.WithShort("Fetch tickets from Zendesk"),
+			cmds.WithFlags(ticketSelectionFlags()...),
 			cmds.WithFlags(
 				parameters.NewParameterDefinition(
-					"start-date",
-					parameters.ParameterTypeDate,
-					parameters.WithHelp("Specify the start time from when you want to start fetching tickets."),
+					"id",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("Specify a ticket ID to fetch."),
 				),
 				parameters.NewParameterDefinition(
-					"end-date",
-					parameters.ParameterTypeDate,
-					parameters.WithHelp("Specify the end time until when you want to fetch tickets."),
+					"limit",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Limit the number of tickets to fetch."),
+					parameters.WithDefault(0),
 				),
 				parameters.NewParameterDefinition(
-					"domain",
+					"state-file",
 					parameters.ParameterTypeString,
-					parameters.WithHelp("Zendesk domain."),
+					parameters.WithHelp("File storing the export cursor. If it exists, the export resumes where the last run stopped."),
 				),
 				parameters.NewParameterDefinition(
-					"email",
-					parameters.ParameterTypeString,
-					parameters.WithHelp("Zendesk email."),
+					"custom-field-columns",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Add a named, typed column for every custom field, using the ticket field definitions."),
+					parameters.WithDefault(false),
 				),
 				parameters.NewParameterDefinition(
-					"api-token",
-					parameters.ParameterTypeString,
-					parameters.WithHelp("Zendesk API token."),
+					"with-comments",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Fetch the comments of each ticket and add them as a comments column."),
+					parameters.WithDefault(false),
 				),
 				parameters.NewParameterDefinition(
-					"id",
+					"attachments-dir",
 					parameters.ParameterTypeString,
-					parameters.WithHelp("Specify a ticket ID to fetch."),
-				),
-				parameters.NewParameterDefinition(
-					"limit",
-					parameters.ParameterTypeInteger,
-					parameters.WithHelp("Limit the number of tickets to fetch."),
-					parameters.WithDefault(0),
+					parameters.WithHelp("With --with-comments, download attachments into this content-addressed directory."),
 				),
 			),
+			cmds.WithFlags(zendeskConnectionFlags()...),
 			cmds.WithLayers(
 				glazedParameterLayer,
 			),
@@ -78,6 +77,51 @@ func (e ErrFinish) Error() string {
 	return "finish"
 }
 
+// ticketToRow returns the columns of a ticket, without custom field columns
+// or comments.
+func ticketToRow(ticket Ticket) types.Row {
+	return types.NewRow(
+		types.MRP("id", ticket.ID),
+		types.MRP("status", ticket.Status),
+		types.MRP("created_at", ticket.CreatedAt),
+		types.MRP("subject", ticket.Subject),
+		types.MRP("allow_attachments", ticket.AllowAttachments),
+		types.MRP("allow_channelback", ticket.AllowChannelback),
+		types.MRP("assignee_id", ticket.AssigneeID),
+		types.MRP("brand_id", ticket.BrandID),
+		types.MRP("collaborator_ids", ticket.CollaboratorIDs),
+		types.MRP("custom_fields", ticket.CustomFields),
+		types.MRP("custom_status_id", ticket.CustomStatusID),
+		types.MRP("description", ticket.Description),
+		types.MRP("due_at", ticket.DueAt),
+		types.MRP("email_cc_ids", ticket.EmailCCIDs),
+		types.MRP("external_id", ticket.ExternalID),
+		types.MRP("fields", ticket.Fields),
+		types.MRP("follower_ids", ticket.FollowerIDs),
+		types.MRP("followup_ids", ticket.FollowupIDs),
+		types.MRP("forum_topic_id", ticket.ForumTopicID),
+		types.MRP("from_messaging_channel", ticket.FromMessagingChannel),
+		types.MRP("generated_timestamp", ticket.GeneratedTimestamp),
+		types.MRP("group_id", ticket.GroupID),
+		types.MRP("has_incidents", ticket.HasIncidents),
+		types.MRP("is_public", ticket.IsPublic),
+		types.MRP("organization_id", ticket.OrganizationID),
+		types.MRP("priority", ticket.Priority),
+		types.MRP("problem_id", ticket.ProblemID),
+		types.MRP("raw_subject", ticket.RawSubject),
+		types.MRP("recipient", ticket.Recipient),
+		types.MRP("requester_id", ticket.RequesterID),
+		types.MRP("satisfaction_rating", ticket.SatisfactionRating),
+		types.MRP("sharing_agreement_ids", ticket.SharingAgreementIDs),
+		types.MRP("submitter_id", ticket.SubmitterID),
+		types.MRP("tags", ticket.Tags),
+		types.MRP("type", ticket.Type),
+		types.MRP("updated_at", ticket.UpdatedAt),
+		types.MRP("url", ticket.URL),
+		types.MRP("via", ticket.Via),
+	)
+}
+
 func (c *GetTicketsCommand) Run(
 	ctx context.Context,
 	parsedLayers map[string]*layers.ParsedParameterLayer,
@@ -85,40 +129,7 @@ func (c *GetTicketsCommand) Run(
 	gp middlewares.Processor,
 ) error {
 	// Extract flags from ps map
-	startDate_, ok := ps["start-date"]
-
-	var startDate time.Time
-	if ok {
-		startDate = startDate_.(time.Time)
-	} else {
-		// set to 2010-01-01 per default
-		startDate = time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
-	}
-
-	endDate_, ok := ps["end-date"]
-	var endDate time.Time
-	if ok {
-		endDate = endDate_.(time.Time)
-	} else {
-		// set to now per default
-		endDate = time.Now()
-	}
-
-	domain_, ok := ps["domain"]
-	var domain string
-	if ok {
-		domain = domain_.(string)
-	}
-	email_, ok := ps["email"]
-	var email string
-	if ok {
-		email = email_.(string)
-	}
-	apiToken_, ok := ps["api-token"]
-	var apiToken string
-	if ok {
-		apiToken = apiToken_.(string)
-	}
+	query := getTicketQueryFromParameters(ps)
 
 	ticketId_, ok := ps["id"]
 	var ticketId string
@@ -127,67 +138,54 @@ func (c *GetTicketsCommand) Run(
 	}
 	limit := ps["limit"].(int)
 
-	// If flags are not set, use environment variables
-	if domain == "" {
-		domain = os.Getenv("ZENDESK_DOMAIN")
-	}
-	if email == "" {
-		email = os.Getenv("ZENDESK_EMAIL")
-	}
-	if apiToken == "" {
-		apiToken = os.Getenv("ZENDESK_API_TOKEN")
+	stateFile_, ok := ps["state-file"]
+	var stateFile string
+	if ok {
+		stateFile = stateFile_.(string)
 	}
 
+	withComments, _ := ps["with-comments"].(bool)
+	attachmentsDir, _ := ps["attachments-dir"].(string)
+	customFieldColumns, _ := ps["custom-field-columns"].(bool)
+
 	// Set up the ZendeskConfig with the parsed flags
-	zd := &ZendeskConfig{
-		Domain:   domain,
-		Email:    email,
-		ApiToken: apiToken,
+	zd := newZendeskConfigFromParameters(ps)
+
+	var fieldMap *TicketFieldMap
+	if customFieldColumns {
+		var err error
+		fieldMap, err = zd.getTicketFieldMap(ctx)
+		if err != nil {
+			return err
+		}
 	}
 
 	count := 0
 
 	addTicketRow := func(ticket Ticket) error {
-		row := types.NewRow(
-			types.MRP("id", ticket.ID),
-			types.MRP("status", ticket.Status),
-			types.MRP("created_at", ticket.CreatedAt),
-			types.MRP("subject", ticket.Subject),
-			types.MRP("allow_attachments", ticket.AllowAttachments),
-			types.MRP("allow_channelback", ticket.AllowChannelback),
-			types.MRP("assignee_id", ticket.AssigneeID),
-			types.MRP("brand_id", ticket.BrandID),
-			types.MRP("collaborator_ids", ticket.CollaboratorIDs),
-			types.MRP("custom_fields", ticket.CustomFields),
-			types.MRP("custom_status_id", ticket.CustomStatusID),
-			types.MRP("description", ticket.Description),
-			types.MRP("due_at", ticket.DueAt),
-			types.MRP("email_cc_ids", ticket.EmailCCIDs),
-			types.MRP("external_id", ticket.ExternalID),
-			types.MRP("fields", ticket.Fields),
-			types.MRP("follower_ids", ticket.FollowerIDs),
-			types.MRP("followup_ids", ticket.FollowupIDs),
-			types.MRP("forum_topic_id", ticket.ForumTopicID),
-			types.MRP("from_messaging_channel", ticket.FromMessagingChannel),
-			types.MRP("generated_timestamp", ticket.GeneratedTimestamp),
-			types.MRP("group_id", ticket.GroupID),
-			types.MRP("has_incidents", ticket.HasIncidents),
-			types.MRP("is_public", ticket.IsPublic),
-			types.MRP("organization_id", ticket.OrganizationID),
-			types.MRP("priority", ticket.Priority),
-			types.MRP("problem_id", ticket.ProblemID),
-			types.MRP("raw_subject", ticket.RawSubject),
-			types.MRP("recipient", ticket.Recipient),
-			types.MRP("requester_id", ticket.RequesterID),
-			types.MRP("satisfaction_rating", ticket.SatisfactionRating),
-			types.MRP("sharing_agreement_ids", ticket.SharingAgreementIDs),
-			types.MRP("submitter_id", ticket.SubmitterID),
-			types.MRP("tags", ticket.Tags),
-			types.MRP("type", ticket.Type),
-			types.MRP("updated_at", ticket.UpdatedAt),
-			types.MRP("url", ticket.URL),
-			types.MRP("via", ticket.Via),
-		)
+		row := ticketToRow(ticket)
+
+		if fieldMap != nil {
+			fieldMap.addColumns(row, ticket.CustomFields)
+		}
+
+		if withComments {
+			var comments []Comment
+			err := zd.getTicketComments(ctx, ticket.ID, func(comment Comment) error {
+				if attachmentsDir != "" {
+					if err := zd.downloadCommentAttachments(ctx, &comment, attachmentsDir); err != nil {
+						return err
+					}
+				}
+				comments = append(comments, comment)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			row.Set("comments", comments)
+		}
+
 		if err := gp.AddRow(ctx, row); err != nil {
 			return err
 		}
@@ -201,18 +199,39 @@ func (c *GetTicketsCommand) Run(
 	}
 
 	if ticketId != "" {
-		ticket := zd.getTicketById(ticketId)
-		err := addTicketRow(ticket)
+		ticket, err := zd.getTicketById(ctx, ticketId)
+		if err != nil {
+			return err
+		}
+		err = addTicketRow(ticket)
 		if err != nil {
 			return err
 		}
 	} else {
-		_, err := zd.getIncrementalTickets(Query{
-			StartDate: startDate,
-			EndDate:   endDate,
-			Limit:     limit,
-			Callback:  addTicketRow,
-		})
+		query.Limit = limit
+		query.Callback = addTicketRow
+
+		if stateFile != "" {
+			store := NewFileExportStateStore(stateFile)
+			state, err := store.Load()
+			if err != nil {
+				return err
+			}
+			if state != nil {
+				if _, ok := ps["start-date"]; ok {
+					log.Warn().Str("state-file", stateFile).
+						Msg("Ignoring --start-date, the export resumes from the cursor in the state file")
+				}
+				log.Info().Str("state-file", stateFile).Str("cursor", state.Cursor).Msg("Resuming ticket export")
+				query.Cursor = state.Cursor
+				// the cursor replaces the start date, which would otherwise
+				// still drop the tickets updated before it
+				query.StartDate = time.Time{}
+			}
+			query.OnPage = store.Save
+		}
+
+		_, err := zd.getIncrementalTickets(ctx, query)
 
 		if err != nil && err.Error() != "finish" {
 			return err
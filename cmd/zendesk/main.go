@@ -4,17 +4,36 @@ import (
 	"github.com/go-go-golems/glazed/pkg/cli"
 	"github.com/go-go-golems/glazed/pkg/help"
 	"github.com/spf13/cobra"
+	"sync"
+	"time"
 )
 
 // TODO(manuel, 2023-10-04) Write proper documentation, add a select ticket command or something
 // ways of using
 // ❯ jq '[.[] | select(.created_at < "2020-01-01T00:00:00Z")]' /ttmp/backup/tickets.json > /ttmp/tickets-2019.json
+// or, with a local mirror created by `zendesk sync --db zendesk.db`:
+// ❯ sqlite3 zendesk.db "select id, subject from tickets where created_at < '2020-01-01T00:00:00Z'"
 
 type ZendeskConfig struct {
 	Domain   string
 	Email    string
 	Password string
 	ApiToken string
+
+	// MaxRetries is the number of times a rate-limited, 5xx or failed request
+	// is retried. 0 uses the default, a negative value disables retries.
+	MaxRetries int
+	// MinBackoff and MaxBackoff bound the exponential backoff between
+	// retries. 0 uses the defaults.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// JobPollInterval is the delay between two polls of a queued or running
+	// background job. 0 uses the default.
+	JobPollInterval time.Duration
+
+	// ticketFields caches the ticket field definitions, see getTicketFieldMap
+	ticketFieldsMu sync.Mutex
+	ticketFields   *TicketFieldMap
 }
 
 func main() {
@@ -33,12 +52,48 @@ func main() {
 	cobra.CheckErr(err)
 	rootCmd.AddCommand(cobraCommand)
 
+	getCommentsCommand, err := NewGetCommentsCommand()
+	cobra.CheckErr(err)
+	cobraCommand, err = cli.BuildCobraCommandFromGlazeCommand(getCommentsCommand)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(cobraCommand)
+
+	getAuditsCommand, err := NewGetAuditsCommand()
+	cobra.CheckErr(err)
+	cobraCommand, err = cli.BuildCobraCommandFromGlazeCommand(getAuditsCommand)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(cobraCommand)
+
+	searchCommand, err := NewSearchCommand()
+	cobra.CheckErr(err)
+	cobraCommand, err = cli.BuildCobraCommandFromGlazeCommand(searchCommand)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(cobraCommand)
+
+	syncCommand, err := NewSyncCommand()
+	cobra.CheckErr(err)
+	cobraCommand, err = cli.BuildCobraCommandFromGlazeCommand(syncCommand)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(cobraCommand)
+
 	deleteTicketsCommand, err := NewDeleteTicketsCommand()
 	cobra.CheckErr(err)
 	cobraCommand, err = cli.BuildCobraCommandFromWriterCommand(deleteTicketsCommand)
 	cobra.CheckErr(err)
 	rootCmd.AddCommand(cobraCommand)
 
+	updateTicketsCommand, err := NewUpdateTicketsCommand()
+	cobra.CheckErr(err)
+	cobraCommand, err = cli.BuildCobraCommandFromGlazeCommand(updateTicketsCommand)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(cobraCommand)
+
+	undeleteCommand, err := NewUndeleteCommand()
+	cobra.CheckErr(err)
+	cobraCommand, err = cli.BuildCobraCommandFromWriterCommand(undeleteCommand)
+	cobra.CheckErr(err)
+	rootCmd.AddCommand(cobraCommand)
+
 	err = rootCmd.Execute()
 	cobra.CheckErr(err)
 }
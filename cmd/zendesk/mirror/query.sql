@@ -0,0 +1,106 @@
+-- name: UpsertTicket :exec
+INSERT INTO tickets (id, url, subject, raw_subject, description, status, priority, type,
+                     requester_id, submitter_id, assignee_id, organization_id, group_id, brand_id,
+                     problem_id, external_id, is_public, due_at, via_channel,
+                     created_at, updated_at, generated_timestamp, raw)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET url                 = excluded.url,
+                               subject             = excluded.subject,
+                               raw_subject         = excluded.raw_subject,
+                               description         = excluded.description,
+                               status              = excluded.status,
+                               priority            = excluded.priority,
+                               type                = excluded.type,
+                               requester_id        = excluded.requester_id,
+                               submitter_id        = excluded.submitter_id,
+                               assignee_id         = excluded.assignee_id,
+                               organization_id     = excluded.organization_id,
+                               group_id            = excluded.group_id,
+                               brand_id            = excluded.brand_id,
+                               problem_id          = excluded.problem_id,
+                               external_id         = excluded.external_id,
+                               is_public           = excluded.is_public,
+                               due_at              = excluded.due_at,
+                               via_channel         = excluded.via_channel,
+                               created_at          = excluded.created_at,
+                               updated_at          = excluded.updated_at,
+                               generated_timestamp = excluded.generated_timestamp,
+                               raw                 = excluded.raw;
+
+-- name: DeleteTicketTags :exec
+DELETE FROM ticket_tags
+WHERE ticket_id = ?;
+
+-- name: InsertTicketTag :exec
+INSERT OR IGNORE INTO ticket_tags (ticket_id, tag)
+VALUES (?, ?);
+
+-- name: DeleteTicketCustomFieldValues :exec
+DELETE FROM ticket_custom_field_values
+WHERE ticket_id = ?;
+
+-- name: InsertTicketCustomFieldValue :exec
+INSERT OR REPLACE INTO ticket_custom_field_values (ticket_id, field_id, value)
+VALUES (?, ?, ?);
+
+-- name: UpsertUser :exec
+INSERT INTO users (id, url, name, email, role, organization_id, active, suspended,
+                   time_zone, locale, created_at, updated_at, raw)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET url             = excluded.url,
+                               name            = excluded.name,
+                               email           = excluded.email,
+                               role            = excluded.role,
+                               organization_id = excluded.organization_id,
+                               active          = excluded.active,
+                               suspended       = excluded.suspended,
+                               time_zone       = excluded.time_zone,
+                               locale          = excluded.locale,
+                               created_at      = excluded.created_at,
+                               updated_at      = excluded.updated_at,
+                               raw             = excluded.raw;
+
+-- name: UpsertOrganization :exec
+INSERT INTO organizations (id, url, name, details, notes, group_id, shared_tickets,
+                           created_at, updated_at, raw)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET url            = excluded.url,
+                               name           = excluded.name,
+                               details        = excluded.details,
+                               notes          = excluded.notes,
+                               group_id       = excluded.group_id,
+                               shared_tickets = excluded.shared_tickets,
+                               created_at     = excluded.created_at,
+                               updated_at     = excluded.updated_at,
+                               raw            = excluded.raw;
+
+-- name: UpsertTicketField :exec
+INSERT INTO ticket_fields (id, type, title, description, active, position, raw)
+VALUES (?, ?, ?, ?, ?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET type        = excluded.type,
+                               title       = excluded.title,
+                               description = excluded.description,
+                               active      = excluded.active,
+                               position    = excluded.position,
+                               raw         = excluded.raw;
+
+-- name: DeleteTicketFieldOptions :exec
+DELETE FROM ticket_field_options
+WHERE field_id = ?;
+
+-- name: InsertTicketFieldOption :exec
+INSERT OR REPLACE INTO ticket_field_options (field_id, value, name)
+VALUES (?, ?, ?);
+
+-- name: GetSyncState :one
+SELECT * FROM sync_state
+WHERE resource = ? LIMIT 1;
+
+-- name: UpsertSyncState :exec
+INSERT INTO sync_state (resource, cursor, end_time, end_of_stream, end_of_stream_at, updated_at)
+VALUES (?, ?, ?, ?, ?, ?)
+ON CONFLICT (resource) DO UPDATE SET cursor           = excluded.cursor,
+                                     end_time         = excluded.end_time,
+                                     end_of_stream    = excluded.end_of_stream,
+                                     end_of_stream_at = excluded.end_of_stream_at,
+                                     updated_at       = excluded.updated_at;
@@ -0,0 +1,8 @@
+package mirror
+
+import _ "embed"
+
+// Schema creates the mirror tables if they don't exist yet.
+//
+//go:embed schema.sql
+var Schema string
@@ -0,0 +1,110 @@
+CREATE TABLE IF NOT EXISTS tickets
+(
+    id                  integer PRIMARY KEY,
+    url                 text    NOT NULL,
+    subject             text    NOT NULL,
+    raw_subject         text    NOT NULL,
+    description         text    NOT NULL,
+    status              text    NOT NULL,
+    priority            text,
+    type                text,
+    requester_id        integer NOT NULL,
+    submitter_id        integer NOT NULL,
+    assignee_id         integer NOT NULL,
+    organization_id     integer,
+    group_id            integer NOT NULL,
+    brand_id            integer NOT NULL,
+    problem_id          integer,
+    external_id         text,
+    is_public           boolean NOT NULL,
+    due_at              text,
+    via_channel         text    NOT NULL,
+    created_at          text    NOT NULL,
+    updated_at          text    NOT NULL,
+    generated_timestamp integer NOT NULL,
+    raw                 text    NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS tickets_status ON tickets (status);
+CREATE INDEX IF NOT EXISTS tickets_created_at ON tickets (created_at);
+CREATE INDEX IF NOT EXISTS tickets_updated_at ON tickets (updated_at);
+CREATE INDEX IF NOT EXISTS tickets_organization_id ON tickets (organization_id);
+CREATE INDEX IF NOT EXISTS tickets_requester_id ON tickets (requester_id);
+
+CREATE TABLE IF NOT EXISTS ticket_tags
+(
+    ticket_id integer NOT NULL REFERENCES tickets (id) ON DELETE CASCADE,
+    tag       text    NOT NULL,
+    PRIMARY KEY (ticket_id, tag)
+);
+
+CREATE INDEX IF NOT EXISTS ticket_tags_tag ON ticket_tags (tag);
+
+CREATE TABLE IF NOT EXISTS ticket_custom_field_values
+(
+    ticket_id integer NOT NULL REFERENCES tickets (id) ON DELETE CASCADE,
+    field_id  integer NOT NULL,
+    value     text,
+    PRIMARY KEY (ticket_id, field_id)
+);
+
+CREATE TABLE IF NOT EXISTS users
+(
+    id              integer PRIMARY KEY,
+    url             text    NOT NULL,
+    name            text    NOT NULL,
+    email           text,
+    role            text    NOT NULL,
+    organization_id integer,
+    active          boolean NOT NULL,
+    suspended       boolean NOT NULL,
+    time_zone       text    NOT NULL,
+    locale          text    NOT NULL,
+    created_at      text    NOT NULL,
+    updated_at      text    NOT NULL,
+    raw             text    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS organizations
+(
+    id             integer PRIMARY KEY,
+    url            text    NOT NULL,
+    name           text    NOT NULL,
+    details        text,
+    notes          text,
+    group_id       integer,
+    shared_tickets boolean NOT NULL,
+    created_at     text    NOT NULL,
+    updated_at     text    NOT NULL,
+    raw            text    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ticket_fields
+(
+    id          integer PRIMARY KEY,
+    type        text    NOT NULL,
+    title       text    NOT NULL,
+    description text    NOT NULL,
+    active      boolean NOT NULL,
+    position    integer NOT NULL,
+    raw         text    NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ticket_field_options
+(
+    field_id integer NOT NULL REFERENCES ticket_fields (id) ON DELETE CASCADE,
+    value    text    NOT NULL,
+    name     text    NOT NULL,
+    PRIMARY KEY (field_id, value)
+);
+
+-- sync_state stores the position of the incremental export of each resource.
+CREATE TABLE IF NOT EXISTS sync_state
+(
+    resource         text PRIMARY KEY,
+    cursor           text    NOT NULL,
+    end_time         integer NOT NULL,
+    end_of_stream    boolean NOT NULL,
+    end_of_stream_at text,
+    updated_at       text    NOT NULL
+);
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// searchExport pages through the results of a search query with
+// /api/v2/search/export, which uses cursor pagination and, unlike
+// /api/v2/search, is not limited to 1000 results.
+//
+// resultType is the filter[type] of the export: ticket, user, organization
+// or group. Results are decoded into T and passed to callback one by one.
+func searchExport[T any](
+	ctx context.Context,
+	zd *ZendeskConfig,
+	query string,
+	resultType string,
+	pageSize int,
+	callback func(T) error,
+) error {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("filter[type]", resultType)
+	params.Set("page[size]", fmt.Sprintf("%d", pageSize))
+	endpoint := fmt.Sprintf("%s/api/v2/search/export.json?%s", zd.Domain, params.Encode())
+
+	for {
+		var result struct {
+			cursorPagination
+			Results []T `json:"results"`
+		}
+		if err := zd.requestJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return errors.Wrapf(err, "could not search for %s", query)
+		}
+		for _, item := range result.Results {
+			if err := callback(item); err != nil {
+				return err
+			}
+		}
+
+		next, ok := result.nextPage()
+		if !ok {
+			return nil
+		}
+		endpoint = next
+	}
+}
+
+func userToRow(user User) types.Row {
+	return types.NewRow(
+		types.MRP("id", user.ID),
+		types.MRP("name", user.Name),
+		types.MRP("email", user.Email),
+		types.MRP("role", user.Role),
+		types.MRP("organization_id", user.OrganizationID),
+		types.MRP("active", user.Active),
+		types.MRP("suspended", user.Suspended),
+		types.MRP("time_zone", user.TimeZone),
+		types.MRP("locale", user.Locale),
+		types.MRP("tags", user.Tags),
+		types.MRP("user_fields", user.UserFields),
+		types.MRP("created_at", user.CreatedAt),
+		types.MRP("updated_at", user.UpdatedAt),
+		types.MRP("url", user.URL),
+	)
+}
+
+func organizationToRow(organization Organization) types.Row {
+	return types.NewRow(
+		types.MRP("id", organization.ID),
+		types.MRP("name", organization.Name),
+		types.MRP("details", organization.Details),
+		types.MRP("notes", organization.Notes),
+		types.MRP("group_id", organization.GroupID),
+		types.MRP("shared_tickets", organization.SharedTickets),
+		types.MRP("domain_names", organization.DomainNames),
+		types.MRP("tags", organization.Tags),
+		types.MRP("organization_fields", organization.OrganizationFields),
+		types.MRP("created_at", organization.CreatedAt),
+		types.MRP("updated_at", organization.UpdatedAt),
+		types.MRP("url", organization.URL),
+	)
+}
+
+type SearchCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewSearchCommand() (*SearchCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &SearchCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"search",
+			cmds.WithShort("Search tickets, users or organizations with the Zendesk query syntax"),
+			cmds.WithLong("Search with the Zendesk search syntax, for example:\n\n"+
+				"```\n"+
+				"zendesk search tags:spam status:solved \"created<2021-01-01\"\n"+
+				"zendesk search --type user role:agent\n"+
+				"```\n\n"+
+				"The results are exported page by page, so there is no limit on the number of results."),
+			cmds.WithArguments(
+				parameters.NewParameterDefinition(
+					"query",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Search query. Multiple arguments are joined with spaces."),
+					parameters.WithRequired(true),
+				),
+			),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"type",
+					parameters.ParameterTypeChoice,
+					parameters.WithHelp("Type of the results."),
+					parameters.WithChoices([]string{"ticket", "user", "organization"}),
+					parameters.WithDefault("ticket"),
+				),
+				parameters.NewParameterDefinition(
+					"limit",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Limit the number of results."),
+					parameters.WithDefault(0),
+				),
+				parameters.NewParameterDefinition(
+					"page-size",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Number of results per request (at most 1000)."),
+					parameters.WithDefault(100),
+				),
+				parameters.NewParameterDefinition(
+					"custom-field-columns",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Add a named, typed column for every custom field of the ticket results."),
+					parameters.WithDefault(false),
+				),
+			),
+			cmds.WithFlags(zendeskConnectionFlags()...),
+			cmds.WithLayers(
+				glazedParameterLayer,
+			),
+		),
+	}, nil
+}
+
+func (c *SearchCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	queryTerms, _ := ps["query"].([]string)
+	query := strings.TrimSpace(strings.Join(queryTerms, " "))
+	if query == "" {
+		return fmt.Errorf("no search query specified")
+	}
+
+	resultType, _ := ps["type"].(string)
+	if resultType == "" {
+		resultType = "ticket"
+	}
+	limit, _ := ps["limit"].(int)
+	pageSize, _ := ps["page-size"].(int)
+	if pageSize <= 0 {
+		pageSize = 100
+	}
+	customFieldColumns, _ := ps["custom-field-columns"].(bool)
+
+	zd := newZendeskConfigFromParameters(ps)
+
+	count := 0
+	addRow := func(row types.Row) error {
+		if err := gp.AddRow(ctx, row); err != nil {
+			return err
+		}
+		count++
+		if limit > 0 && count >= limit {
+			return ErrFinish{}
+		}
+		return nil
+	}
+
+	var err error
+	switch resultType {
+	case "ticket":
+		var fieldMap *TicketFieldMap
+		if customFieldColumns {
+			fieldMap, err = zd.getTicketFieldMap(ctx)
+			if err != nil {
+				return err
+			}
+		}
+		err = searchExport(ctx, zd, query, resultType, pageSize, func(ticket Ticket) error {
+			row := ticketToRow(ticket)
+			if fieldMap != nil {
+				fieldMap.addColumns(row, ticket.CustomFields)
+			}
+			return addRow(row)
+		})
+	case "user":
+		err = searchExport(ctx, zd, query, resultType, pageSize, func(user User) error {
+			return addRow(userToRow(user))
+		})
+	case "organization":
+		err = searchExport(ctx, zd, query, resultType, pageSize, func(organization Organization) error {
+			return addRow(organizationToRow(organization))
+		})
+	default:
+		return fmt.Errorf("unknown result type %s", resultType)
+	}
+
+	if _, ok := errors.Cause(err).(ErrFinish); ok {
+		return nil
+	}
+	return err
+}
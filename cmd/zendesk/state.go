@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/pkg/errors"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ExportState is the persisted position of an incremental export.
+//
+// Cursor is the last after_cursor returned by Zendesk for a page that was
+// fully processed, so resuming from it never skips a ticket. Time-based
+// exports, which have no cursor, store the end_time of that page in EndTime
+// instead. EndOfStreamAt records when the export last caught up with the
+// present.
+type ExportState struct {
+	Cursor        string     `json:"cursor"`
+	EndTime       int64      `json:"end_time,omitempty"`
+	EndOfStream   bool       `json:"end_of_stream"`
+	EndOfStreamAt *time.Time `json:"end_of_stream_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// ExportStateStore loads and saves an ExportState.
+// Load returns nil, nil if no state has been saved yet.
+type ExportStateStore interface {
+	Load() (*ExportState, error)
+	Save(state *ExportState) error
+}
+
+// FileExportStateStore keeps the export state in a JSON file.
+type FileExportStateStore struct {
+	Path string
+}
+
+func NewFileExportStateStore(path string) *FileExportStateStore {
+	return &FileExportStateStore{Path: path}
+}
+
+func (f *FileExportStateStore) Load() (*ExportState, error) {
+	b, err := os.ReadFile(f.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "could not read state file %s", f.Path)
+	}
+
+	state := &ExportState{}
+	if err := json.Unmarshal(b, state); err != nil {
+		return nil, errors.Wrapf(err, "could not parse state file %s", f.Path)
+	}
+	return state, nil
+}
+
+// Save writes the state to a temporary file next to Path and renames it into
+// place, so that a crash never leaves a truncated state file behind.
+func (f *FileExportStateStore) Save(state *ExportState) error {
+	b, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.Path), filepath.Base(f.Path)+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "could not create temporary state file")
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), f.Path)
+}
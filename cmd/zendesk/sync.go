@@ -0,0 +1,514 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/go-go-golems/go-go-labs/cmd/zendesk/mirror"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+type SyncCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewSyncCommand() (*SyncCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &SyncCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"sync",
+			cmds.WithShort("Mirror tickets, users and organizations into a local SQLite database"),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"db",
+					parameters.ParameterTypeString,
+					parameters.WithHelp("SQLite database to sync into."),
+					parameters.WithDefault("zendesk.db"),
+				),
+				parameters.NewParameterDefinition(
+					"start-date",
+					parameters.ParameterTypeDate,
+					parameters.WithHelp("Start date of the first sync. Later syncs continue from the stored cursor."),
+				),
+				parameters.NewParameterDefinition(
+					"resources",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Resources to sync (ticket-fields, organizations, users, tickets)."),
+					parameters.WithDefault([]string{"ticket-fields", "organizations", "users", "tickets"}),
+				),
+			),
+			cmds.WithFlags(zendeskConnectionFlags()...),
+			cmds.WithLayers(
+				glazedParameterLayer,
+			),
+		),
+	}, nil
+}
+
+func (c *SyncCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	dbPath := ps["db"].(string)
+	resources := ps["resources"].([]string)
+
+	startDate := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	if startDate_, ok := ps["start-date"]; ok {
+		startDate = startDate_.(time.Time)
+	}
+
+	zd := newZendeskConfigFromParameters(ps)
+
+	db, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		return errors.Wrapf(err, "could not open %s", dbPath)
+	}
+	defer func(db *sql.DB) {
+		_ = db.Close()
+	}(db)
+
+	if _, err := db.ExecContext(ctx, mirror.Schema); err != nil {
+		return errors.Wrap(err, "could not create mirror schema")
+	}
+
+	s := &mirrorSync{
+		zd:        zd,
+		db:        db,
+		startTime: startDate.Unix(),
+	}
+
+	for _, resource := range resources {
+		var count int
+		var state *ExportState
+		var err error
+
+		log.Info().Str("resource", resource).Msg("Syncing")
+		switch resource {
+		case "ticket-fields":
+			count, err = s.syncTicketFields(ctx)
+		case "organizations":
+			count, state, err = s.syncOrganizations(ctx)
+		case "users":
+			count, state, err = s.syncUsers(ctx)
+		case "tickets":
+			count, state, err = s.syncTickets(ctx)
+		default:
+			return fmt.Errorf("unknown resource %s", resource)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "could not sync %s", resource)
+		}
+
+		row := types.NewRow(
+			types.MRP("resource", resource),
+			types.MRP("upserted", count),
+		)
+		if state != nil {
+			row.Set("cursor", state.Cursor)
+			row.Set("end_time", state.EndTime)
+			row.Set("end_of_stream", state.EndOfStream)
+		}
+		if err := gp.AddRow(ctx, row); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mirrorSync upserts Zendesk resources into the SQLite mirror. Every export
+// page is written in a single transaction together with the export state, so
+// an interrupted sync resumes at the last page that was fully stored.
+type mirrorSync struct {
+	zd        *ZendeskConfig
+	db        *sql.DB
+	startTime int64
+}
+
+func (s *mirrorSync) withTx(ctx context.Context, f func(q *mirror.Queries) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := f(mirror.New(s.db).WithTx(tx)); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *mirrorSync) loadState(ctx context.Context, resource string) (*ExportState, error) {
+	return newMirrorExportStateStore(ctx, mirror.New(s.db), resource).Load()
+}
+
+func (s *mirrorSync) syncTicketFields(ctx context.Context) (int, error) {
+	fields, err := s.zd.getTicketFields(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	err = s.withTx(ctx, func(q *mirror.Queries) error {
+		for _, field := range fields {
+			raw, err := json.Marshal(field)
+			if err != nil {
+				return err
+			}
+			err = q.UpsertTicketField(ctx, mirror.UpsertTicketFieldParams{
+				ID:          int64(field.ID),
+				Type:        field.Type,
+				Title:       field.Title,
+				Description: field.Description,
+				Active:      field.Active,
+				Position:    int64(field.Position),
+				Raw:         string(raw),
+			})
+			if err != nil {
+				return err
+			}
+
+			if err := q.DeleteTicketFieldOptions(ctx, int64(field.ID)); err != nil {
+				return err
+			}
+			for _, option := range field.CustomFieldOptions {
+				err = q.InsertTicketFieldOption(ctx, mirror.InsertTicketFieldOptionParams{
+					FieldID: int64(field.ID),
+					Value:   option.Value,
+					Name:    option.Name,
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return len(fields), nil
+}
+
+func (s *mirrorSync) syncOrganizations(ctx context.Context) (int, *ExportState, error) {
+	const resource = "organizations"
+	state, err := s.loadState(ctx, resource)
+	if err != nil {
+		return 0, nil, err
+	}
+	startTime := s.startTime
+	if state != nil && state.EndTime != 0 {
+		startTime = state.EndTime
+	}
+
+	count := 0
+	err = incrementalTimeExport[Organization](ctx, s.zd, resource, startTime,
+		func(organizations []Organization, pageState *ExportState) (bool, error) {
+			err := s.withTx(ctx, func(q *mirror.Queries) error {
+				for _, organization := range organizations {
+					if err := upsertOrganization(ctx, q, organization); err != nil {
+						return err
+					}
+				}
+				return newMirrorExportStateStore(ctx, q, resource).Save(pageState)
+			})
+			if err != nil {
+				return false, err
+			}
+			count += len(organizations)
+			state = pageState
+			return true, nil
+		})
+
+	return count, state, err
+}
+
+func (s *mirrorSync) syncUsers(ctx context.Context) (int, *ExportState, error) {
+	const resource = "users"
+	state, err := s.loadState(ctx, resource)
+	if err != nil {
+		return 0, nil, err
+	}
+	cursor := ""
+	if state != nil {
+		cursor = state.Cursor
+	}
+
+	count := 0
+	err = incrementalCursorExport[User](ctx, s.zd, resource, s.startTime, cursor,
+		func(users []User, pageState *ExportState) (bool, error) {
+			err := s.withTx(ctx, func(q *mirror.Queries) error {
+				for _, user := range users {
+					if err := upsertUser(ctx, q, user); err != nil {
+						return err
+					}
+				}
+				if pageState.Cursor == "" {
+					return nil
+				}
+				return newMirrorExportStateStore(ctx, q, resource).Save(pageState)
+			})
+			if err != nil {
+				return false, err
+			}
+			count += len(users)
+			state = pageState
+			return true, nil
+		})
+
+	return count, state, err
+}
+
+func (s *mirrorSync) syncTickets(ctx context.Context) (int, *ExportState, error) {
+	const resource = "tickets"
+	state, err := s.loadState(ctx, resource)
+	if err != nil {
+		return 0, nil, err
+	}
+	cursor := ""
+	if state != nil {
+		cursor = state.Cursor
+	}
+
+	count := 0
+	err = incrementalCursorExport[Ticket](ctx, s.zd, resource, s.startTime, cursor,
+		func(tickets []Ticket, pageState *ExportState) (bool, error) {
+			err := s.withTx(ctx, func(q *mirror.Queries) error {
+				for _, ticket := range tickets {
+					if err := upsertTicket(ctx, q, ticket); err != nil {
+						return err
+					}
+				}
+				if pageState.Cursor == "" {
+					return nil
+				}
+				return newMirrorExportStateStore(ctx, q, resource).Save(pageState)
+			})
+			if err != nil {
+				return false, err
+			}
+			count += len(tickets)
+			state = pageState
+			return true, nil
+		})
+
+	return count, state, err
+}
+
+func upsertTicket(ctx context.Context, q *mirror.Queries, ticket Ticket) error {
+	raw, err := json.Marshal(ticket)
+	if err != nil {
+		return err
+	}
+
+	err = q.UpsertTicket(ctx, mirror.UpsertTicketParams{
+		ID:                 int64(ticket.ID),
+		Url:                ticket.URL,
+		Subject:            ticket.Subject,
+		RawSubject:         ticket.RawSubject,
+		Description:        ticket.Description,
+		Status:             ticket.Status,
+		Priority:           nullString(ticket.Priority),
+		Type:               nullString(ticket.Type),
+		RequesterID:        int64(ticket.RequesterID),
+		SubmitterID:        int64(ticket.SubmitterID),
+		AssigneeID:         int64(ticket.AssigneeID),
+		OrganizationID:     nullInt64(ticket.OrganizationID),
+		GroupID:            int64(ticket.GroupID),
+		BrandID:            int64(ticket.BrandID),
+		ProblemID:          nullInt64(ticket.ProblemID),
+		ExternalID:         nullString(ticket.ExternalID),
+		IsPublic:           ticket.IsPublic,
+		DueAt:              nullString(ticket.DueAt),
+		ViaChannel:         ticket.Via.Channel,
+		CreatedAt:          ticket.CreatedAt,
+		UpdatedAt:          ticket.UpdatedAt,
+		GeneratedTimestamp: int64(ticket.GeneratedTimestamp),
+		Raw:                string(raw),
+	})
+	if err != nil {
+		return errors.Wrapf(err, "could not upsert ticket %d", ticket.ID)
+	}
+
+	if err := q.DeleteTicketTags(ctx, int64(ticket.ID)); err != nil {
+		return err
+	}
+	for _, tag := range ticket.Tags {
+		err := q.InsertTicketTag(ctx, mirror.InsertTicketTagParams{
+			TicketID: int64(ticket.ID),
+			Tag:      tag,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := q.DeleteTicketCustomFieldValues(ctx, int64(ticket.ID)); err != nil {
+		return err
+	}
+	for _, field := range ticket.CustomFields {
+		value, err := customFieldText(field.Value)
+		if err != nil {
+			return errors.Wrapf(err, "could not encode custom field %d of ticket %d", field.ID, ticket.ID)
+		}
+		err = q.InsertTicketCustomFieldValue(ctx, mirror.InsertTicketCustomFieldValueParams{
+			TicketID: int64(ticket.ID),
+			FieldID:  int64(field.ID),
+			Value:    value,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func upsertUser(ctx context.Context, q *mirror.Queries, user User) error {
+	raw, err := json.Marshal(user)
+	if err != nil {
+		return err
+	}
+
+	err = q.UpsertUser(ctx, mirror.UpsertUserParams{
+		ID:             int64(user.ID),
+		Url:            user.URL,
+		Name:           user.Name,
+		Email:          nullString(user.Email),
+		Role:           user.Role,
+		OrganizationID: nullInt64(user.OrganizationID),
+		Active:         user.Active,
+		Suspended:      user.Suspended,
+		TimeZone:       user.TimeZone,
+		Locale:         user.Locale,
+		CreatedAt:      user.CreatedAt,
+		UpdatedAt:      user.UpdatedAt,
+		Raw:            string(raw),
+	})
+	return errors.Wrapf(err, "could not upsert user %d", user.ID)
+}
+
+func upsertOrganization(ctx context.Context, q *mirror.Queries, organization Organization) error {
+	raw, err := json.Marshal(organization)
+	if err != nil {
+		return err
+	}
+
+	err = q.UpsertOrganization(ctx, mirror.UpsertOrganizationParams{
+		ID:            int64(organization.ID),
+		Url:           organization.URL,
+		Name:          organization.Name,
+		Details:       nullString(organization.Details),
+		Notes:         nullString(organization.Notes),
+		GroupID:       nullInt64(organization.GroupID),
+		SharedTickets: organization.SharedTickets,
+		CreatedAt:     organization.CreatedAt,
+		UpdatedAt:     organization.UpdatedAt,
+		Raw:           string(raw),
+	})
+	return errors.Wrapf(err, "could not upsert organization %d", organization.ID)
+}
+
+// mirrorExportStateStore stores the export state of one resource in the
+// sync_state table of the mirror.
+type mirrorExportStateStore struct {
+	ctx      context.Context
+	q        *mirror.Queries
+	resource string
+}
+
+var _ ExportStateStore = (*mirrorExportStateStore)(nil)
+
+func newMirrorExportStateStore(ctx context.Context, q *mirror.Queries, resource string) *mirrorExportStateStore {
+	return &mirrorExportStateStore{ctx: ctx, q: q, resource: resource}
+}
+
+func (m *mirrorExportStateStore) Load() (*ExportState, error) {
+	s, err := m.q.GetSyncState(m.ctx, m.resource)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	updatedAt, err := time.Parse(time.RFC3339, s.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	state := &ExportState{
+		Cursor:      s.Cursor,
+		EndTime:     s.EndTime,
+		EndOfStream: s.EndOfStream,
+		UpdatedAt:   updatedAt,
+	}
+	if s.EndOfStreamAt.Valid {
+		endOfStreamAt, err := time.Parse(time.RFC3339, s.EndOfStreamAt.String)
+		if err != nil {
+			return nil, err
+		}
+		state.EndOfStreamAt = &endOfStreamAt
+	}
+	return state, nil
+}
+
+func (m *mirrorExportStateStore) Save(state *ExportState) error {
+	var endOfStreamAt sql.NullString
+	if state.EndOfStreamAt != nil {
+		endOfStreamAt = sql.NullString{String: state.EndOfStreamAt.Format(time.RFC3339), Valid: true}
+	}
+	return m.q.UpsertSyncState(m.ctx, mirror.UpsertSyncStateParams{
+		Resource:      m.resource,
+		Cursor:        state.Cursor,
+		EndTime:       state.EndTime,
+		EndOfStream:   state.EndOfStream,
+		EndOfStreamAt: endOfStreamAt,
+		UpdatedAt:     state.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
+
+// customFieldText stores strings as is and every other custom field value
+// (checkboxes, numbers, multiselect lists) as JSON.
+func customFieldText(v interface{}) (sql.NullString, error) {
+	switch v := v.(type) {
+	case nil:
+		return sql.NullString{}, nil
+	case string:
+		return sql.NullString{String: v, Valid: true}, nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return sql.NullString{}, err
+		}
+		return sql.NullString{String: string(b), Valid: true}, nil
+	}
+}
+
+func nullInt64(i *int) sql.NullInt64 {
+	if i == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: int64(*i), Valid: true}
+}
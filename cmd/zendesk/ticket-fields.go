@@ -0,0 +1,329 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type TicketField struct {
+	ID                  int                 `json:"id"`
+	URL                 string              `json:"url"`
+	Type                string              `json:"type"`
+	Title               string              `json:"title"`
+	Description         string              `json:"description"`
+	Active              bool                `json:"active"`
+	Position            int                 `json:"position"`
+	CustomFieldOptions  []CustomFieldOption `json:"custom_field_options"`
+	SystemFieldOptions  []CustomFieldOption `json:"system_field_options"`
+	RegexpForValidation *string             `json:"regexp_for_validation"`
+	CreatedAt           string              `json:"created_at"`
+	UpdatedAt           string              `json:"updated_at"`
+}
+
+// CustomFieldOption is one choice of a dropdown or multiselect field. Value
+// is the tag stored on the ticket, Name the label shown to agents.
+type CustomFieldOption struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// getTicketFields fetches the definitions of all system and custom ticket fields.
+func (zd *ZendeskConfig) getTicketFields(ctx context.Context) ([]TicketField, error) {
+	endpoint := fmt.Sprintf("%s/api/v2/ticket_fields.json?page[size]=100", zd.Domain)
+
+	var fields []TicketField
+	for {
+		var result struct {
+			cursorPagination
+			TicketFields []TicketField `json:"ticket_fields"`
+		}
+		if err := zd.requestJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return nil, err
+		}
+		fields = append(fields, result.TicketFields...)
+
+		next, ok := result.nextPage()
+		if !ok {
+			return fields, nil
+		}
+		endpoint = next
+	}
+}
+
+// getTicketFieldMap returns the TicketFieldMap of the account. The ticket
+// fields are only fetched on the first call.
+func (zd *ZendeskConfig) getTicketFieldMap(ctx context.Context) (*TicketFieldMap, error) {
+	zd.ticketFieldsMu.Lock()
+	defer zd.ticketFieldsMu.Unlock()
+
+	if zd.ticketFields != nil {
+		return zd.ticketFields, nil
+	}
+
+	fields, err := zd.getTicketFields(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not fetch ticket fields")
+	}
+	zd.ticketFields = NewTicketFieldMap(fields)
+	return zd.ticketFields, nil
+}
+
+// TicketFieldMap turns the {id, value} pairs of Ticket.CustomFields into named,
+// typed values, using the ticket field definitions.
+type TicketFieldMap struct {
+	fields  map[int]TicketField
+	columns map[int]string
+	// ids maps column names and lowercased titles back to field IDs
+	ids map[string]int
+}
+
+func NewTicketFieldMap(fields []TicketField) *TicketFieldMap {
+	m := &TicketFieldMap{
+		fields:  map[int]TicketField{},
+		columns: map[int]string{},
+		ids:     map[string]int{},
+	}
+
+	// fields sharing a title get their ID appended to the column name, so
+	// that the names don't depend on the order of the fields
+	titleCount := map[string]int{}
+	for _, field := range fields {
+		titleCount[columnName(field.Title)]++
+	}
+
+	for _, field := range fields {
+		column := columnName(field.Title)
+		if column == "" || titleCount[column] > 1 {
+			column = strings.TrimLeft(fmt.Sprintf("%s_%d", column, field.ID), "_")
+		}
+		m.fields[field.ID] = field
+		m.columns[field.ID] = column
+		m.ids[column] = field.ID
+		m.ids[strings.ToLower(field.Title)] = field.ID
+	}
+
+	return m
+}
+
+var nonAlphanumeric = regexp.MustCompile(`[^a-z0-9]+`)
+
+// columnName turns a field title like "Product Area (new)" into "product_area_new".
+func columnName(title string) string {
+	return strings.Trim(nonAlphanumeric.ReplaceAllString(strings.ToLower(title), "_"), "_")
+}
+
+// Field returns the definition of the field with the given ID.
+func (m *TicketFieldMap) Field(id int) (TicketField, bool) {
+	field, ok := m.fields[id]
+	return field, ok
+}
+
+// ColumnName returns the column name of a field: its snake_cased title, or
+// "field_<id>" for unknown fields.
+func (m *TicketFieldMap) ColumnName(id int) string {
+	if column, ok := m.columns[id]; ok {
+		return column
+	}
+	return fmt.Sprintf("field_%d", id)
+}
+
+// Lookup finds a field by ID, column name or (case-insensitive) title.
+func (m *TicketFieldMap) Lookup(name string) (TicketField, bool) {
+	if id, err := strconv.Atoi(name); err == nil {
+		return m.Field(id)
+	}
+	id, ok := m.ids[strings.ToLower(name)]
+	if !ok {
+		return TicketField{}, false
+	}
+	return m.Field(id)
+}
+
+// Value converts the raw JSON value of a custom field to a typed value:
+//   - dropdowns (tagger) resolve to the option label, multiselects to a list of labels
+//   - integers to int64, decimals to float64, checkboxes to bool
+//   - dates to time.Time
+//   - lookups to the ID of the referenced object
+//
+// Text fields and fields of unknown type are returned as is. nil stays nil.
+func (m *TicketFieldMap) Value(id int, raw interface{}) (interface{}, error) {
+	field, ok := m.fields[id]
+	if !ok || raw == nil {
+		return raw, nil
+	}
+
+	switch field.Type {
+	case "tagger":
+		tag, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("field %d: expected a tag, got %v", id, raw)
+		}
+		return field.optionLabel(tag), nil
+
+	case "multiselect":
+		tags, ok := raw.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("field %d: expected a list of tags, got %v", id, raw)
+		}
+		labels := make([]string, 0, len(tags))
+		for _, tag_ := range tags {
+			tag, ok := tag_.(string)
+			if !ok {
+				return nil, fmt.Errorf("field %d: expected a tag, got %v", id, tag_)
+			}
+			labels = append(labels, field.optionLabel(tag))
+		}
+		return labels, nil
+
+	case "integer", "lookup":
+		switch v := raw.(type) {
+		case float64:
+			return int64(v), nil
+		case string:
+			if v == "" {
+				return nil, nil
+			}
+			i, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %d: invalid integer", id)
+			}
+			return i, nil
+		}
+
+	case "decimal":
+		switch v := raw.(type) {
+		case float64:
+			return v, nil
+		case string:
+			if v == "" {
+				return nil, nil
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %d: invalid decimal", id)
+			}
+			return f, nil
+		}
+
+	case "checkbox":
+		switch v := raw.(type) {
+		case bool:
+			return v, nil
+		case string:
+			return strconv.ParseBool(v)
+		}
+
+	case "date":
+		if v, ok := raw.(string); ok {
+			if v == "" {
+				return nil, nil
+			}
+			t, err := time.Parse("2006-01-02", v)
+			if err != nil {
+				return nil, errors.Wrapf(err, "field %d: invalid date", id)
+			}
+			return t, nil
+		}
+
+	default:
+		return raw, nil
+	}
+
+	return nil, fmt.Errorf("field %d: unexpected %s value %v", id, field.Type, raw)
+}
+
+// addColumns sets one column per custom field on row, named after the field
+// and holding its typed value. A field whose name clashes with an existing
+// column gets its ID appended. Values that can't be converted are logged and
+// kept as is.
+func (m *TicketFieldMap) addColumns(row types.Row, customFields []CustomField) {
+	for _, customField := range customFields {
+		column := m.ColumnName(customField.ID)
+		if _, ok := row.Get(column); ok {
+			column = fmt.Sprintf("%s_%d", column, customField.ID)
+		}
+
+		value, err := m.Value(customField.ID, customField.Value)
+		if err != nil {
+			log.Warn().Err(err).Msg("Could not convert custom field value")
+			value = customField.Value
+		}
+		row.Set(column, value)
+	}
+}
+
+// RawValue is the inverse of Value for dropdowns and multiselects: it turns
+// option labels back into the tags Zendesk expects. Multiselect values can be
+// a list or a comma-separated string, as given on the command line. Other
+// values are returned unchanged.
+func (m *TicketFieldMap) RawValue(id int, value interface{}) interface{} {
+	field, ok := m.fields[id]
+	if !ok {
+		return value
+	}
+
+	switch field.Type {
+	case "tagger":
+		if label, ok := value.(string); ok {
+			return field.optionTag(label)
+		}
+	case "multiselect":
+		var labels []string
+		switch v := value.(type) {
+		case []string:
+			labels = v
+		case string:
+			for _, label := range strings.Split(v, ",") {
+				if label = strings.TrimSpace(label); label != "" {
+					labels = append(labels, label)
+				}
+			}
+		case []interface{}:
+			for _, label_ := range v {
+				label, ok := label_.(string)
+				if !ok {
+					return value
+				}
+				labels = append(labels, label)
+			}
+		default:
+			return value
+		}
+		tags := make([]string, len(labels))
+		for i, label := range labels {
+			tags[i] = field.optionTag(label)
+		}
+		return tags
+	}
+	return value
+}
+
+// optionLabel returns the label of the option with the given tag, or the tag
+// itself if there is no such option.
+func (f TicketField) optionLabel(tag string) string {
+	for _, option := range f.CustomFieldOptions {
+		if option.Value == tag {
+			return option.Name
+		}
+	}
+	return tag
+}
+
+// optionTag returns the tag of the option with the given label or tag.
+func (f TicketField) optionTag(label string) string {
+	for _, option := range f.CustomFieldOptions {
+		if option.Name == label || option.Value == label {
+			return option.Value
+		}
+	}
+	return label
+}
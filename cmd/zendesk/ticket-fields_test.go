@@ -0,0 +1,57 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTicketFieldMap(t *testing.T) {
+	m := NewTicketFieldMap([]TicketField{
+		{ID: 1, Type: "tagger", Title: "Product Area", CustomFieldOptions: []CustomFieldOption{
+			{Name: "Billing & Invoices", Value: "area_billing"},
+		}},
+		{ID: 2, Type: "text", Title: "Notes"},
+		{ID: 3, Type: "text", Title: "notes"},
+		{ID: 4, Type: "multiselect", Title: "Products", CustomFieldOptions: []CustomFieldOption{
+			{Name: "Chat", Value: "product_chat"},
+		}},
+	})
+
+	if column := m.ColumnName(1); column != "product_area" {
+		t.Errorf("expected product_area, got %s", column)
+	}
+	// fields with the same title both get their ID appended
+	if a, b := m.ColumnName(2), m.ColumnName(3); a != "notes_2" || b != "notes_3" {
+		t.Errorf("expected notes_2 and notes_3, got %s and %s", a, b)
+	}
+	if column := m.ColumnName(99); column != "field_99" {
+		t.Errorf("expected field_99, got %s", column)
+	}
+
+	for _, name := range []string{"1", "product_area", "Product Area"} {
+		if field, ok := m.Lookup(name); !ok || field.ID != 1 {
+			t.Errorf("expected %q to resolve to field 1, got %v", name, field.ID)
+		}
+	}
+	if _, ok := m.Lookup("unknown"); ok {
+		t.Errorf("expected unknown field not to resolve")
+	}
+
+	if v := m.RawValue(1, "Billing & Invoices"); v != "area_billing" {
+		t.Errorf("expected area_billing, got %v", v)
+	}
+	if v := m.RawValue(4, []string{"Chat", "product_voice"}); !reflect.DeepEqual(v, []string{"product_chat", "product_voice"}) {
+		t.Errorf("unexpected multiselect tags %v", v)
+	}
+	// --custom-fields gives strings on the command line and lists from a file
+	if v := m.RawValue(4, "Chat, product_voice"); !reflect.DeepEqual(v, []string{"product_chat", "product_voice"}) {
+		t.Errorf("unexpected multiselect tags %v", v)
+	}
+	if v := m.RawValue(4, []interface{}{"Chat"}); !reflect.DeepEqual(v, []string{"product_chat"}) {
+		t.Errorf("unexpected multiselect tags %v", v)
+	}
+
+	if _, err := m.Value(1, true); err == nil {
+		t.Errorf("expected an error for a non-tag dropdown value")
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/pkg/errors"
+	"io"
+	"strings"
+)
+
+type UndeleteCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewUndeleteCommand() (*UndeleteCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &UndeleteCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"undelete",
+			cmds.WithShort("Restore soft-deleted tickets in Zendesk"),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"ids",
+					parameters.ParameterTypeIntegerList,
+					parameters.WithHelp("List of ticket IDs to restore."),
+				),
+				parameters.NewParameterDefinition(
+					"manifest",
+					parameters.ParameterTypeObjectListFromFile,
+					parameters.WithHelp("Manifest written by delete-tickets --manifest."),
+				),
+				parameters.NewParameterDefinition(
+					"dry-run",
+					parameters.ParameterTypeBool,
+					parameters.WithHelp("Only print the tickets that would be restored."),
+					parameters.WithDefault(false),
+				),
+			),
+			cmds.WithFlags(zendeskConnectionFlags()...),
+			cmds.WithLayers(
+				glazedParameterLayer,
+			),
+		),
+	}, nil
+}
+
+func (c *UndeleteCommand) RunIntoWriter(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	w io.Writer,
+) error {
+	// the manifest has the same shape as a tickets-file
+	ticketIds, err := getTicketIdsFromParameters(map[string]interface{}{
+		"ids":          ps["ids"],
+		"tickets-file": ps["manifest"],
+	})
+	if err != nil {
+		return err
+	}
+	if len(ticketIds) == 0 {
+		return fmt.Errorf("no ticket IDs specified")
+	}
+
+	zd := newZendeskConfigFromParameters(ps)
+	dryRun := ps["dry-run"].(bool)
+
+	restored := 0
+	var failedIds []int
+	for _, group := range chunkInts(ticketIds, 100) {
+		if dryRun {
+			_, _ = fmt.Fprintf(w, "Would restore tickets %s\n", strings.Join(convertIntsToStrings(group), ","))
+			continue
+		}
+
+		if err := zd.restoreDeletedTickets(ctx, group); err != nil {
+			_, _ = fmt.Fprintf(w, "Failed to restore %d tickets: %v\n", len(group), err)
+			failedIds = append(failedIds, group...)
+			continue
+		}
+		restored += len(group)
+		_, _ = fmt.Fprintf(w, "Restored %d (%d/%d) tickets\n", len(group), restored, len(ticketIds))
+	}
+
+	if len(failedIds) > 0 {
+		_, _ = fmt.Fprintf(w, "Failed ids: %s\n", strings.Join(convertIntsToStrings(failedIds), ","))
+		return fmt.Errorf("failed to restore %d of %d tickets", len(failedIds), len(ticketIds))
+	}
+
+	return nil
+}
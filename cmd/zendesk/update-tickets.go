@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/go-go-golems/glazed/pkg/cmds"
+	"github.com/go-go-golems/glazed/pkg/cmds/layers"
+	"github.com/go-go-golems/glazed/pkg/cmds/parameters"
+	"github.com/go-go-golems/glazed/pkg/middlewares"
+	"github.com/go-go-golems/glazed/pkg/settings"
+	"github.com/go-go-golems/glazed/pkg/types"
+	"github.com/pkg/errors"
+	"sort"
+	"strings"
+)
+
+type UpdateTicketsCommand struct {
+	*cmds.CommandDescription
+}
+
+func NewUpdateTicketsCommand() (*UpdateTicketsCommand, error) {
+	glazedParameterLayer, err := settings.NewGlazedParameterLayers()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create Glazed parameter layer")
+	}
+
+	return &UpdateTicketsCommand{
+		CommandDescription: cmds.NewCommandDescription(
+			"update-tickets",
+			cmds.WithShort("Update tags, status, priority or custom fields of tickets in Zendesk"),
+			cmds.WithFlags(
+				parameters.NewParameterDefinition(
+					"ids",
+					parameters.ParameterTypeIntegerList,
+					parameters.WithHelp("List of ticket IDs to update."),
+				),
+				parameters.NewParameterDefinition(
+					"tickets-file",
+					parameters.ParameterTypeObjectListFromFile,
+					parameters.WithHelp("File containing a list of tickets to update."),
+				),
+				parameters.NewParameterDefinition(
+					"workers",
+					parameters.ParameterTypeInteger,
+					parameters.WithHelp("Number of workers to use."),
+					parameters.WithDefault(8),
+				),
+				parameters.NewParameterDefinition(
+					"rate-limit",
+					parameters.ParameterTypeFloat,
+					parameters.WithHelp("Maximum number of batches started per second, across all workers (0 for no limit)."),
+					parameters.WithDefault(0.0),
+				),
+				parameters.NewParameterDefinition(
+					"status",
+					parameters.ParameterTypeChoice,
+					parameters.WithHelp("Set the status of the tickets."),
+					parameters.WithChoices([]string{"new", "open", "pending", "hold", "solved", "closed"}),
+				),
+				parameters.NewParameterDefinition(
+					"priority",
+					parameters.ParameterTypeChoice,
+					parameters.WithHelp("Set the priority of the tickets."),
+					parameters.WithChoices([]string{"low", "normal", "high", "urgent"}),
+				),
+				parameters.NewParameterDefinition(
+					"set-tags",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Replace the tags of the tickets."),
+				),
+				parameters.NewParameterDefinition(
+					"add-tags",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Add tags to the tickets."),
+				),
+				parameters.NewParameterDefinition(
+					"remove-tags",
+					parameters.ParameterTypeStringList,
+					parameters.WithHelp("Remove tags from the tickets."),
+				),
+				parameters.NewParameterDefinition(
+					"custom-fields",
+					parameters.ParameterTypeKeyValue,
+					parameters.WithHelp("Set custom field values, as field:value pairs or @file.json. Fields can be given by ID, title or column name, dropdown values by label."),
+				),
+			),
+			cmds.WithFlags(zendeskConnectionFlags()...),
+			cmds.WithLayers(
+				glazedParameterLayer,
+			),
+		),
+	}, nil
+}
+
+// getTicketUpdateFromParameters builds the update from the flags. Custom
+// fields are resolved through the ticket field definitions, which are only
+// fetched if --custom-fields is given.
+func getTicketUpdateFromParameters(ctx context.Context, zd *ZendeskConfig, ps map[string]interface{}) (TicketUpdate, error) {
+	update := TicketUpdate{}
+	update.Status, _ = ps["status"].(string)
+	update.Priority, _ = ps["priority"].(string)
+	update.Tags, _ = ps["set-tags"].([]string)
+	update.AdditionalTags, _ = ps["add-tags"].([]string)
+	update.RemoveTags, _ = ps["remove-tags"].([]string)
+
+	if customFields, ok := ps["custom-fields"].(map[string]interface{}); ok && len(customFields) > 0 {
+		fieldMap, err := zd.getTicketFieldMap(ctx)
+		if err != nil {
+			return update, err
+		}
+		for name, value := range customFields {
+			field, ok := fieldMap.Lookup(name)
+			if !ok {
+				return update, fmt.Errorf("unknown custom field %s", name)
+			}
+			update.CustomFields = append(update.CustomFields, CustomFieldUpdate{
+				ID:    field.ID,
+				Value: fieldMap.RawValue(field.ID, value),
+			})
+		}
+		sort.Slice(update.CustomFields, func(i, j int) bool {
+			return update.CustomFields[i].ID < update.CustomFields[j].ID
+		})
+	}
+
+	if update.Status == "" && update.Priority == "" &&
+		len(update.Tags) == 0 && len(update.AdditionalTags) == 0 && len(update.RemoveTags) == 0 &&
+		len(update.CustomFields) == 0 {
+		return update, fmt.Errorf("nothing to update")
+	}
+
+	return update, nil
+}
+
+func (c *UpdateTicketsCommand) Run(
+	ctx context.Context,
+	parsedLayers map[string]*layers.ParsedParameterLayer,
+	ps map[string]interface{},
+	gp middlewares.Processor,
+) error {
+	ticketIds, err := getTicketIdsFromParameters(ps)
+	if err != nil {
+		return err
+	}
+	if len(ticketIds) == 0 {
+		return fmt.Errorf("no ticket IDs specified")
+	}
+
+	zd := newZendeskConfigFromParameters(ps)
+	update, err := getTicketUpdateFromParameters(ctx, zd, ps)
+	if err != nil {
+		return err
+	}
+
+	workers := ps["workers"].(int)
+	rateLimit, _ := ps["rate-limit"].(float64)
+
+	submit := func(ctx context.Context, ticketIds []int) (*JobStatus, error) {
+		return zd.bulkUpdateTickets(ctx, ticketIds, update)
+	}
+
+	// failed tickets are collected, so that they can be retried with the list
+	// returned in the error
+	var failedIds []int
+	failedBatches := 0
+	batchCount := 0
+	var rowErr error
+	err = runBulkJobs(ctx, zd, ticketIds, workers, rateLimit, submit, func(result bulkBatchResult) {
+		batchCount++
+		if rowErr != nil {
+			return
+		}
+
+		// a batch that failed as a whole gets one row per ticket, so that the
+		// output always covers every requested ticket
+		if result.Err != nil {
+			failedBatches++
+			failedIds = append(failedIds, result.TicketIds...)
+			for _, id := range result.TicketIds {
+				row := types.NewRow(
+					types.MRP("id", id),
+					types.MRP("success", false),
+					types.MRP("status", ""),
+					types.MRP("error", result.Err.Error()),
+					types.MRP("details", ""),
+					types.MRP("job_id", ""),
+				)
+				if err := gp.AddRow(ctx, row); err != nil {
+					rowErr = err
+					return
+				}
+			}
+			return
+		}
+
+		for _, jobResult := range result.JobStatus.Results {
+			if !jobResult.Success {
+				failedIds = append(failedIds, jobResult.ID)
+			}
+			row := types.NewRow(
+				types.MRP("id", jobResult.ID),
+				types.MRP("success", jobResult.Success),
+				types.MRP("status", jobResult.Status),
+				types.MRP("error", jobResult.Error),
+				types.MRP("details", jobResult.Details),
+				types.MRP("job_id", result.JobStatus.ID),
+			)
+			if err := gp.AddRow(ctx, row); err != nil {
+				rowErr = err
+				return
+			}
+		}
+	})
+	if rowErr != nil {
+		return rowErr
+	}
+	// batches that were skipped because ctx was canceled have no rows
+	if err != nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	if len(failedIds) > 0 {
+		// the error exits before the rows are output, flush them first so
+		// that the failed tickets can be told apart from the updated ones
+		if err := gp.Close(ctx); err != nil {
+			return err
+		}
+		sort.Ints(failedIds)
+		return errors.Errorf("%d of %d batches failed, %d of %d tickets were not updated: %s",
+			failedBatches, batchCount, len(failedIds), len(ticketIds),
+			strings.Join(convertIntsToStrings(failedIds), ","))
+	}
+
+	return nil
+}
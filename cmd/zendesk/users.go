@@ -0,0 +1,33 @@
+package main
+
+type User struct {
+	ID             int                    `json:"id"`
+	URL            string                 `json:"url"`
+	Name           string                 `json:"name"`
+	Email          *string                `json:"email"`
+	Role           string                 `json:"role"`
+	OrganizationID *int                   `json:"organization_id"`
+	Active         bool                   `json:"active"`
+	Suspended      bool                   `json:"suspended"`
+	TimeZone       string                 `json:"time_zone"`
+	Locale         string                 `json:"locale"`
+	Tags           []string               `json:"tags"`
+	UserFields     map[string]interface{} `json:"user_fields"`
+	CreatedAt      string                 `json:"created_at"`
+	UpdatedAt      string                 `json:"updated_at"`
+}
+
+type Organization struct {
+	ID                 int                    `json:"id"`
+	URL                string                 `json:"url"`
+	Name               string                 `json:"name"`
+	Details            *string                `json:"details"`
+	Notes              *string                `json:"notes"`
+	GroupID            *int                   `json:"group_id"`
+	SharedTickets      bool                   `json:"shared_tickets"`
+	DomainNames        []string               `json:"domain_names"`
+	Tags               []string               `json:"tags"`
+	OrganizationFields map[string]interface{} `json:"organization_fields"`
+	CreatedAt          string                 `json:"created_at"`
+	UpdatedAt          string                 `json:"updated_at"`
+}
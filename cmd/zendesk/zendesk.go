@@ -1,39 +1,27 @@
 package main
 
 import (
+	"context"
 	"encoding/base64"
-	"encoding/json"
 	"fmt"
-	"github.com/levigross/grequests"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
-	"strconv"
+	"net/http"
 	"strings"
 	"time"
 )
 
-func (zd *ZendeskConfig) getTicketById(id string) Ticket {
+func (zd *ZendeskConfig) getTicketById(ctx context.Context, id string) (Ticket, error) {
 	endpoint := fmt.Sprintf("%s/api/v2/tickets/%s.json", zd.Domain, id)
 
-	headers := map[string]string{
-		"Authorization": "Basic " + basicAuth(zd.Email, zd.ApiToken),
-	}
-
-	response, err := grequests.Get(endpoint, &grequests.RequestOptions{
-		Headers: headers,
-	})
-	if err != nil {
-		panic(err)
-	}
-
 	var result struct {
 		Ticket Ticket `json:"ticket"`
 	}
-	if err := response.JSON(&result); err != nil {
-		panic(err)
+	if err := zd.requestJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+		return Ticket{}, err
 	}
 
-	return result.Ticket
+	return result.Ticket, nil
 }
 
 type Ticket struct {
@@ -77,14 +65,17 @@ type Ticket struct {
 	Via                  Via                `json:"via"`
 }
 
+// CustomField is the raw value of a custom field: a string, bool, number,
+// list of tags or nil depending on the field type. Use TicketFieldMap.Value
+// to get a typed value.
 type CustomField struct {
-	ID    int     `json:"id"`
-	Value *string `json:"value"`
+	ID    int         `json:"id"`
+	Value interface{} `json:"value"`
 }
 
 type Field struct {
-	ID    int     `json:"id"`
-	Value *string `json:"value"`
+	ID    int         `json:"id"`
+	Value interface{} `json:"value"`
 }
 
 type SatisfactionRating struct {
@@ -107,41 +98,38 @@ type Source struct {
 type Query struct {
 	StartDate time.Time
 	EndDate   time.Time
-	Limit     int
-	Callback  func(Ticket) error
+	// Statuses, if not empty, only selects tickets with one of these statuses.
+	Statuses []string
+	// Tags, if not empty, only selects tickets that have all of these tags.
+	Tags     []string
+	Limit    int
+	Callback func(Ticket) error
+
+	// Cursor resumes the export from a previously returned after_cursor
+	// instead of starting at StartDate.
+	Cursor string
+	// OnPage is called after every page whose tickets have all been passed to
+	// Callback, with the state needed to resume right after that page.
+	OnPage func(state *ExportState) error
 }
 
 // getIncrementalTickets fetches tickets that changed since the provided startTime using cursor-based incremental exports.
-func (zd *ZendeskConfig) getIncrementalTickets(query Query) ([]Ticket, error) {
-	startTime := query.StartDate.Unix()
-	endpoint := fmt.Sprintf("%s/api/v2/incremental/tickets/cursor.json?start_time=%d", zd.Domain, startTime)
-
-	headers := map[string]string{
-		"Authorization": "Basic " + basicAuth(zd.Email, zd.ApiToken),
-	}
-
+//
+// A ticket that is updated while the export is running can show up on several
+// pages; only its first occurrence is returned.
+func (zd *ZendeskConfig) getIncrementalTickets(ctx context.Context, query Query) ([]Ticket, error) {
 	var allTickets []Ticket
 	count := 0
+	seen := map[int]bool{}
 
-	for {
-		response, err := grequests.Get(endpoint, &grequests.RequestOptions{
-			Headers: headers,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		var result struct {
-			Tickets     []Ticket `json:"tickets"`
-			AfterCursor string   `json:"after_cursor"`
-			EndOfStream bool     `json:"end_of_stream"`
-		}
-		if err := response.JSON(&result); err != nil {
-			return nil, err
-		}
-
+	onPage := func(tickets []Ticket, state *ExportState) (bool, error) {
 		oneSkipped := false
-		for _, ticket := range result.Tickets {
+		for _, ticket := range tickets {
+			if seen[ticket.ID] {
+				continue
+			}
+			seen[ticket.ID] = true
+
 			createdAt, err1 := time.Parse(time.RFC3339, ticket.CreatedAt)
 			updatedAt, err2 := time.Parse(time.RFC3339, ticket.UpdatedAt)
 
@@ -158,10 +146,14 @@ func (zd *ZendeskConfig) getIncrementalTickets(query Query) ([]Ticket, error) {
 				continue
 			}
 
+			if !query.matchesStatusAndTags(ticket) {
+				continue
+			}
+
 			if query.Callback != nil {
 				err := query.Callback(ticket)
 				if err != nil {
-					return nil, err
+					return false, err
 				}
 				continue
 			}
@@ -169,64 +161,100 @@ func (zd *ZendeskConfig) getIncrementalTickets(query Query) ([]Ticket, error) {
 			allTickets = append(allTickets, ticket)
 		}
 
-		count += len(result.Tickets)
+		count += len(tickets)
+
+		// Only advance the saved cursor when the page was not cut short by
+		// the end date, otherwise the skipped tickets would be lost on resume.
+		if query.OnPage != nil && !oneSkipped && state.Cursor != "" {
+			if err := query.OnPage(state); err != nil {
+				return false, err
+			}
+		}
+
 		if query.Limit > 0 && count >= query.Limit {
-			break
+			return false, nil
 		}
 
 		if oneSkipped {
 			log.Warn().Msg("One or more tickets were skipped due to being outside the specified time range")
-			break
+			return false, nil
 		}
 
-		// Check if end of stream is reached or if there's no cursor for the next set
-		if result.EndOfStream || result.AfterCursor == "" {
-			break
-		}
+		return true, nil
+	}
 
-		endpoint = fmt.Sprintf("%s/api/v2/incremental/tickets/cursor.json?cursor=%s", zd.Domain, result.AfterCursor)
+	err := incrementalCursorExport[Ticket](ctx, zd, "tickets", query.StartDate.Unix(), query.Cursor, onPage)
+	if err != nil {
+		return nil, err
 	}
 
 	return allTickets, nil
 }
 
+func (q Query) matchesStatusAndTags(ticket Ticket) bool {
+	if len(q.Statuses) > 0 {
+		found := false
+		for _, status := range q.Statuses {
+			if ticket.Status == status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	for _, tag := range q.Tags {
+		found := false
+		for _, ticketTag := range ticket.Tags {
+			if ticketTag == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
 func basicAuth(username, password string) string {
 	auth := username + "/token:" + password
 	return base64.StdEncoding.EncodeToString([]byte(auth))
 }
 
 type JobStatus struct {
-	ID       string `json:"id"`
-	Message  string `json:"message"`
-	Progress int    `json:"progress"`
-	Status   string `json:"status"`
-	Total    int    `json:"total"`
-	URL      string `json:"url"`
+	ID       string      `json:"id"`
+	Message  string      `json:"message"`
+	Progress int         `json:"progress"`
+	Status   string      `json:"status"`
+	Total    int         `json:"total"`
+	URL      string      `json:"url"`
+	Results  []JobResult `json:"results"`
+}
+
+// JobResult is the outcome of a background job for a single ticket.
+type JobResult struct {
+	ID      int    `json:"id"`
+	Index   int    `json:"index"`
+	Action  string `json:"action"`
+	Success bool   `json:"success"`
+	Status  string `json:"status"`
+	Error   string `json:"error"`
+	Details string `json:"details"`
 }
 
-func (zd *ZendeskConfig) getJobStatus(jobID string) (*JobStatus, error) {
+func (zd *ZendeskConfig) getJobStatus(ctx context.Context, jobID string) (*JobStatus, error) {
 	endpoint := fmt.Sprintf("%s/api/v2/job_statuses/%s.json", zd.Domain, jobID)
-	headers := map[string]string{
-		"Authorization": "Basic " + basicAuth(zd.Email, zd.ApiToken),
-	}
 
 	for {
-		response, err := grequests.Get(endpoint, &grequests.RequestOptions{
-			Headers: headers,
-		})
-		if err != nil {
-			return nil, err
-		}
-
-		if response.StatusCode != 200 {
-			return nil, fmt.Errorf("failed to fetch job status. Status: %s", response.String())
-		}
-
 		var result struct {
 			JobStatus JobStatus `json:"job_status"`
 		}
-
-		if err := json.Unmarshal(response.Bytes(), &result); err != nil {
+		if err := zd.requestJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
 			return nil, err
 		}
 
@@ -234,50 +262,105 @@ func (zd *ZendeskConfig) getJobStatus(jobID string) (*JobStatus, error) {
 		case "completed", "failed":
 			return &result.JobStatus, nil
 		case "queued", "working":
-			// Polling interval. Adjust as needed.
-			time.Sleep(5 * time.Second)
+			pollInterval := zd.JobPollInterval
+			if pollInterval == 0 {
+				pollInterval = defaultJobPollInterval
+			}
+			if err := sleepContext(ctx, pollInterval); err != nil {
+				return nil, err
+			}
 		default:
 			return nil, errors.New("unexpected job status")
 		}
 	}
 }
 
-func (zd *ZendeskConfig) bulkDeleteTickets(ticketIds []int) (*JobStatus, error) {
+func (zd *ZendeskConfig) bulkDeleteTickets(ctx context.Context, ticketIds []int) (*JobStatus, error) {
 	endpoint := fmt.Sprintf("%s/api/v2/tickets/destroy_many.json?ids=%s", zd.Domain, strings.Join(convertIntsToStrings(ticketIds), ","))
 
-	headers := map[string]string{
-		"Authorization": "Basic " + basicAuth(zd.Email, zd.ApiToken),
+	var respBody struct {
+		JobStatus *JobStatus `json:"job_status"`
 	}
-
-	response, err := grequests.Delete(endpoint, &grequests.RequestOptions{
-		Headers: headers,
-	})
-	if err != nil {
+	if err := zd.requestJSON(ctx, http.MethodDelete, endpoint, nil, &respBody); err != nil {
 		return nil, err
 	}
 
-	// Check and handle rate limit
-	if response.StatusCode == 429 {
-		retryAfter, _ := strconv.Atoi(response.Header.Get("Retry-After"))
-		fmt.Printf("Rate limit exceeded. Retrying after %d seconds.\n", retryAfter)
-		time.Sleep(time.Duration(retryAfter) * time.Second)
-		return zd.bulkDeleteTickets(ticketIds)
-	}
+	return respBody.JobStatus, nil
+}
 
-	if response.StatusCode != 200 {
-		return nil, fmt.Errorf("failed to bulk delete tickets. Status: %s", response.String())
-	}
+// TicketUpdate is the change applied to every ticket by bulkUpdateTickets.
+// Empty fields are left untouched.
+type TicketUpdate struct {
+	Status         string              `json:"status,omitempty"`
+	Priority       string              `json:"priority,omitempty"`
+	Tags           []string            `json:"tags,omitempty"`
+	AdditionalTags []string            `json:"additional_tags,omitempty"`
+	RemoveTags     []string            `json:"remove_tags,omitempty"`
+	CustomFields   []CustomFieldUpdate `json:"custom_fields,omitempty"`
+}
+
+type CustomFieldUpdate struct {
+	ID    int         `json:"id"`
+	Value interface{} `json:"value"`
+}
 
+// bulkUpdateTickets applies the same update to up to 100 tickets.
+func (zd *ZendeskConfig) bulkUpdateTickets(ctx context.Context, ticketIds []int, update TicketUpdate) (*JobStatus, error) {
+	endpoint := fmt.Sprintf("%s/api/v2/tickets/update_many.json?ids=%s", zd.Domain, strings.Join(convertIntsToStrings(ticketIds), ","))
+
+	body := map[string]interface{}{
+		"ticket": update,
+	}
 	var respBody struct {
 		JobStatus *JobStatus `json:"job_status"`
 	}
-	if err := json.Unmarshal(response.Bytes(), &respBody); err != nil {
+	if err := zd.requestJSON(ctx, http.MethodPut, endpoint, body, &respBody); err != nil {
 		return nil, err
 	}
 
 	return respBody.JobStatus, nil
 }
 
+// getTicketsByIds fetches the full payload of the given tickets, 100 at a time.
+// Tickets that don't exist (anymore) are silently left out.
+func (zd *ZendeskConfig) getTicketsByIds(ctx context.Context, ticketIds []int) ([]Ticket, error) {
+	var tickets []Ticket
+	for _, group := range chunkInts(ticketIds, 100) {
+		endpoint := fmt.Sprintf("%s/api/v2/tickets/show_many.json?ids=%s", zd.Domain, strings.Join(convertIntsToStrings(group), ","))
+
+		var result struct {
+			Tickets []Ticket `json:"tickets"`
+		}
+		if err := zd.requestJSON(ctx, http.MethodGet, endpoint, nil, &result); err != nil {
+			return nil, err
+		}
+		tickets = append(tickets, result.Tickets...)
+	}
+
+	return tickets, nil
+}
+
+// restoreDeletedTickets restores soft-deleted tickets. At most 100 tickets can be restored at once.
+func (zd *ZendeskConfig) restoreDeletedTickets(ctx context.Context, ticketIds []int) error {
+	endpoint := fmt.Sprintf("%s/api/v2/deleted_tickets/restore_many.json?ids=%s", zd.Domain, strings.Join(convertIntsToStrings(ticketIds), ","))
+
+	_, err := zd.request(ctx, http.MethodPut, endpoint, nil)
+	return err
+}
+
+// chunkInts splits ints into groups of at most size elements.
+func chunkInts(ints []int, size int) [][]int {
+	var chunks [][]int
+	for i := 0; i < len(ints); i += size {
+		end := i + size
+		if end > len(ints) {
+			end = len(ints)
+		}
+		chunks = append(chunks, ints[i:end])
+	}
+	return chunks
+}
+
 func convertIntsToStrings(ints []int) []string {
 	strs := make([]string, len(ints))
 	for i, v := range ints {
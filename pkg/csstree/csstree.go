@@ -0,0 +1,107 @@
+// Package csstree parses CSS into a tree of rules, at-rules, declarations and
+// comments, which can be encoded as JSON and rendered back to CSS.
+//
+// The tree is lossless: the whitespace and stray characters between nodes
+// are kept in their Raws, so that rendering a parsed stylesheet returns its
+// source byte for byte. Nodes without Raws, such as nodes added by a script
+// transforming the JSON, are rendered with a default formatting.
+//
+// Selectors, at-rule preludes and declaration values are kept as written,
+// including the comments they contain.
+package csstree
+
+// Node types.
+const (
+	TypeStylesheet  = "stylesheet"
+	TypeRule        = "rule"
+	TypeAtRule      = "at-rule"
+	TypeDeclaration = "declaration"
+	TypeComment     = "comment"
+)
+
+// Node is a node of the tree. Which fields are used depends on Type.
+type Node struct {
+	Type string `json:"type"`
+
+	// Source is where a stylesheet was read from, if known.
+	Source string `json:"source,omitempty"`
+
+	// Selector is the selector list of a rule.
+	Selector string `json:"selector,omitempty"`
+
+	// Name is the name of an at-rule, without the @.
+	Name string `json:"name,omitempty"`
+	// Prelude is what comes between the name of an at-rule and its block or
+	// semicolon, such as `screen and (min-width: 600px)`.
+	Prelude string `json:"prelude,omitempty"`
+	// Block is set for at-rules with a block, such as @media, as opposed to
+	// statements such as @import.
+	Block bool `json:"block,omitempty"`
+
+	Property string `json:"property,omitempty"`
+	// Value is the value of a declaration, without !important.
+	Value     string `json:"value,omitempty"`
+	Important bool   `json:"important,omitempty"`
+
+	// Text is the content of a comment, without /* and */.
+	Text string `json:"text,omitempty"`
+
+	// Children are the nodes of a stylesheet, or of the block of a rule or
+	// at-rule.
+	Children []*Node `json:"children,omitempty"`
+
+	// Start is the position of the first character of the node, and End the
+	// position right after its last character. Positions of nodes that
+	// weren't parsed are nil.
+	Start *Position `json:"start,omitempty"`
+	End   *Position `json:"end,omitempty"`
+
+	Raws *Raws `json:"raws,omitempty"`
+
+	// semicolon is set for declarations and at-rule statements ending with
+	// a semicolon
+	semicolon bool
+}
+
+// Position is a position in a stylesheet. Lines and columns start at 1,
+// and columns count characters.
+type Position struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Raws is the source text around a node that isn't part of its fields.
+type Raws struct {
+	// Before is the text before the node: whitespace, but also the stray
+	// semicolons and invalid content that are skipped when parsing.
+	Before string `json:"before,omitempty"`
+	// AfterName is the whitespace between the name and the prelude of an
+	// at-rule.
+	AfterName string `json:"afterName,omitempty"`
+	// Between is the text between the selector or prelude and the { or ; of
+	// rules and at-rules, and the text between the property and the value of
+	// declarations, including the colon.
+	Between string `json:"between,omitempty"`
+	// Important is the text of !important, including the whitespace before
+	// it, such as ` !important`.
+	Important string `json:"important,omitempty"`
+	// After is the text before the } closing the block of a stylesheet, rule
+	// or at-rule, and the text between the value and the semicolon of a
+	// declaration.
+	After string `json:"after,omitempty"`
+	// Semicolon is set for stylesheets, rules and at-rules whose last child
+	// is a declaration or at-rule statement ending with a semicolon.
+	Semicolon bool `json:"semicolon,omitempty"`
+}
+
+// Walk calls f for n and all its descendants, depth first. The children of
+// nodes for which f returns false are skipped.
+func (n *Node) Walk(f func(n *Node) bool) {
+	if !f(n) {
+		return
+	}
+	for _, c := range n.Children {
+		c.Walk(f)
+	}
+}
@@ -0,0 +1,105 @@
+package csstree
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var roundTripTests = []string{
+	"",
+	"a{color:red}",
+	"/* header */\n.a > .b, .c { color: red; margin : 0 !important ; }\n",
+	"@import url(a.css);\n@charset \"utf-8\";\n@media screen and (max-width: 600px) {\n  .a { color: blue }\n}\n",
+	"@font-face { font-family: \"Inter\"; src: url(inter.woff2) }\n@keyframes fade { from { opacity: 0 } to { opacity: 1 } }",
+	".card { color: red; &:hover { color: blue; } .title { font-weight: bold } }",
+	":root { --x: { a: b }; --empty:; }",
+	"a { color: red /* c */ ; background: url(\"x;y.png\") }",
+	"a[href=\"{\"] { b: c }",
+	";; } junk; .a { *zoom: 1; b }\n<!-- .c {} -->",
+	"@media print { @page { margin: 1cm } .a { b: c !IMPORTANT } }",
+	"@layer base, components;@layer base{html{color:black}}",
+	"é { content: \"ü\" }",
+}
+
+func TestParse_RoundTrip(t *testing.T) {
+	for _, css := range roundTripTests {
+		tree := Parse(css)
+		if got := Render(tree); got != css {
+			t.Errorf("expected %q, got %q", css, got)
+		}
+
+		// through JSON, as scripts would
+		b, err := json.Marshal(tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := &Node{}
+		if err := json.Unmarshal(b, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := Render(decoded); got != css {
+			t.Errorf("expected %q after JSON, got %q", css, got)
+		}
+	}
+}
+
+func TestParse_Tree(t *testing.T) {
+	tree := Parse("/* c */\n@media (max-width: 600px) {\n  .a > .b { margin: 0 !important; --x: 1 }\n}\n@import 'x.css';")
+	if len(tree.Children) != 3 {
+		t.Fatalf("expected 3 children, got %d", len(tree.Children))
+	}
+
+	comment := tree.Children[0]
+	if comment.Type != TypeComment || comment.Text != " c " {
+		t.Errorf("unexpected comment %+v", comment)
+	}
+
+	media := tree.Children[1]
+	if media.Type != TypeAtRule || media.Name != "media" || media.Prelude != "(max-width: 600px)" || !media.Block {
+		t.Errorf("unexpected at-rule %+v", media)
+	}
+	if media.Start.Line != 2 || media.Start.Column != 1 || media.End.Line != 4 || media.End.Column != 2 {
+		t.Errorf("unexpected at-rule positions %+v %+v", media.Start, media.End)
+	}
+
+	rule := media.Children[0]
+	if rule.Type != TypeRule || rule.Selector != ".a > .b" || len(rule.Children) != 2 {
+		t.Fatalf("unexpected rule %+v", rule)
+	}
+	if rule.Start.Line != 3 || rule.Start.Column != 3 {
+		t.Errorf("unexpected rule position %+v", rule.Start)
+	}
+
+	margin := rule.Children[0]
+	if margin.Property != "margin" || margin.Value != "0" || !margin.Important || margin.Start.Column != 13 {
+		t.Errorf("unexpected declaration %+v", margin)
+	}
+	custom := rule.Children[1]
+	if custom.Property != "--x" || custom.Value != "1" || custom.Important {
+		t.Errorf("unexpected declaration %+v", custom)
+	}
+
+	import_ := tree.Children[2]
+	if import_.Type != TypeAtRule || import_.Name != "import" || import_.Prelude != "'x.css'" || import_.Block {
+		t.Errorf("unexpected at-rule %+v", import_)
+	}
+}
+
+func TestRender_Defaults(t *testing.T) {
+	tree := &Node{Type: TypeStylesheet, Children: []*Node{
+		{Type: TypeRule, Selector: ".a", Children: []*Node{
+			{Type: TypeDeclaration, Property: "color", Value: "red", Important: true},
+			{Type: TypeDeclaration, Property: "margin", Value: "0"},
+		}},
+		{Type: TypeAtRule, Name: "media", Prelude: "print", Children: []*Node{
+			{Type: TypeRule, Selector: ".b", Children: []*Node{
+				{Type: TypeDeclaration, Property: "display", Value: "none"},
+			}},
+		}},
+	}}
+
+	expected := ".a {\n  color: red !important;\n  margin: 0;\n}\n@media print {\n  .b {\n    display: none;\n  }\n}\n"
+	if got := Render(tree); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
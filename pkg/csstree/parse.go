@@ -0,0 +1,304 @@
+package csstree
+
+import (
+	"github.com/tdewolff/parse/v2"
+	"github.com/tdewolff/parse/v2/css"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+type token struct {
+	Type   css.TokenType
+	Data   string
+	Offset int
+}
+
+type parser struct {
+	content string
+	tokens  []token
+	i       int
+	lines   []int
+}
+
+// Parse parses a stylesheet. It never fails: like browsers, it skips what
+// it can't make sense of, and keeps it in the raws of the next node. Blocks
+// left open at the end of the stylesheet are closed when rendered.
+func Parse(content string) *Node {
+	p := &parser{content: content, lines: []int{0}}
+	for i := 0; i < len(content); i++ {
+		if content[i] == '\n' {
+			p.lines = append(p.lines, i+1)
+		}
+	}
+
+	l := css.NewLexer(parse.NewInputString(content))
+	offset := 0
+	for offset < len(content) {
+		tt, data := l.Next()
+		if tt == css.ErrorToken {
+			// the lexer only stops at the end of the input, but keep
+			// whatever might remain
+			p.tokens = append(p.tokens, token{css.DelimToken, content[offset:], offset})
+			break
+		}
+		p.tokens = append(p.tokens, token{tt, string(data), offset})
+		offset += len(data)
+	}
+
+	ret := &Node{Type: TypeStylesheet, Raws: &Raws{}}
+	ret.Start = p.position(0)
+	p.parseChildren(ret, false)
+	ret.End = p.position(len(content))
+	return ret
+}
+
+// offset returns the offset of the current token.
+func (p *parser) offset() int {
+	if p.i < len(p.tokens) {
+		return p.tokens[p.i].Offset
+	}
+	return len(p.content)
+}
+
+func (p *parser) position(offset int) *Position {
+	line := sort.Search(len(p.lines), func(i int) bool {
+		return p.lines[i] > offset
+	})
+	lineStart := p.lines[line-1]
+	return &Position{
+		Offset: offset,
+		Line:   line,
+		Column: utf8.RuneCountInString(p.content[lineStart:offset]) + 1,
+	}
+}
+
+func (p *parser) text(start int, end int) string {
+	return p.content[p.tokens[start].Offset:p.tokenEnd(end)]
+}
+
+// tokenEnd returns the offset of the end of the tokens before i.
+func (p *parser) tokenEnd(i int) int {
+	if i < len(p.tokens) {
+		return p.tokens[i].Offset
+	}
+	return len(p.content)
+}
+
+// trimEnd returns the index after the last token of [start, end) that isn't
+// whitespace.
+func (p *parser) trimEnd(start int, end int) int {
+	for end > start && p.tokens[end-1].Type == css.WhitespaceToken {
+		end--
+	}
+	return end
+}
+
+// parseChildren parses the content of a stylesheet, or of a block up to and
+// including its closing }.
+func (p *parser) parseChildren(parent *Node, isBlock bool) {
+	beforeStart := p.offset()
+	for {
+		for p.i < len(p.tokens) {
+			tt := p.tokens[p.i].Type
+			if tt != css.WhitespaceToken && tt != css.CDOToken && tt != css.CDCToken && tt != css.SemicolonToken &&
+				(isBlock || tt != css.RightBraceToken) {
+				break
+			}
+			p.i++
+		}
+		before := p.content[beforeStart:p.offset()]
+
+		if p.i == len(p.tokens) || p.tokens[p.i].Type == css.RightBraceToken {
+			parent.Raws.After = before
+			if n := len(parent.Children); n > 0 {
+				last := parent.Children[n-1]
+				parent.Raws.Semicolon = last.semicolon
+			}
+			if p.i < len(p.tokens) {
+				p.i++
+			}
+			return
+		}
+
+		start := p.offset()
+		var n *Node
+		switch t := p.tokens[p.i]; t.Type {
+		case css.CommentToken:
+			n = &Node{
+				Type: TypeComment,
+				Text: strings.TrimSuffix(strings.TrimPrefix(t.Data, "/*"), "*/"),
+			}
+			p.i++
+		case css.AtKeywordToken:
+			n = p.parseAtRule()
+		default:
+			n = p.parseRuleOrDeclaration()
+		}
+		if n == nil {
+			// invalid content, kept in the raws of the next node
+			continue
+		}
+
+		if n.Raws == nil {
+			n.Raws = &Raws{}
+		}
+		n.Raws.Before = before
+		n.Start = p.position(start)
+		n.End = p.position(p.tokenEnd(p.i))
+		parent.Children = append(parent.Children, n)
+		beforeStart = p.offset()
+	}
+}
+
+// scan returns the index of the first token from the current one that is a
+// ;, { or } outside of parentheses and brackets, or the number of tokens.
+// Braces are nested when nestBraces is set.
+func (p *parser) scan(nestBraces bool) int {
+	depth := 0
+	for i := p.i; i < len(p.tokens); i++ {
+		switch p.tokens[i].Type {
+		case css.LeftParenthesisToken, css.LeftBracketToken, css.FunctionToken:
+			depth++
+		case css.RightParenthesisToken, css.RightBracketToken:
+			if depth > 0 {
+				depth--
+			}
+		case css.LeftBraceToken:
+			if !nestBraces && depth == 0 {
+				return i
+			}
+			depth++
+		case css.RightBraceToken:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		case css.SemicolonToken:
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.tokens)
+}
+
+func (p *parser) parseAtRule() *Node {
+	n := &Node{
+		Type: TypeAtRule,
+		Name: strings.TrimPrefix(p.tokens[p.i].Data, "@"),
+		Raws: &Raws{},
+	}
+	p.i++
+	if p.i < len(p.tokens) && p.tokens[p.i].Type == css.WhitespaceToken {
+		n.Raws.AfterName = p.tokens[p.i].Data
+		p.i++
+	}
+
+	end := p.scan(false)
+	preludeEnd := p.trimEnd(p.i, end)
+	if preludeEnd > p.i {
+		n.Prelude = p.text(p.i, preludeEnd)
+	}
+	n.Raws.Between = p.content[p.tokenEnd(preludeEnd):p.tokenEnd(end)]
+	p.i = end
+
+	if p.i == len(p.tokens) {
+		return n
+	}
+	switch p.tokens[p.i].Type {
+	case css.SemicolonToken:
+		n.semicolon = true
+		p.i++
+	case css.LeftBraceToken:
+		n.Block = true
+		p.i++
+		p.parseChildren(n, true)
+	}
+	return n
+}
+
+// parseRuleOrDeclaration parses a rule, or a declaration if there is a colon
+// before the next ; or }. It returns nil for invalid content, which is
+// skipped.
+func (p *parser) parseRuleOrDeclaration() *Node {
+	t := p.tokens[p.i]
+	isCustomProperty := (t.Type == css.IdentToken || t.Type == css.CustomPropertyNameToken) &&
+		strings.HasPrefix(t.Data, "--")
+	end := p.scan(isCustomProperty)
+
+	if end < len(p.tokens) && p.tokens[end].Type == css.LeftBraceToken {
+		selectorEnd := p.trimEnd(p.i, end)
+		n := &Node{
+			Type:     TypeRule,
+			Selector: p.text(p.i, selectorEnd),
+			Raws:     &Raws{Between: p.content[p.tokenEnd(selectorEnd):p.tokens[end].Offset]},
+		}
+		p.i = end + 1
+		p.parseChildren(n, true)
+		return n
+	}
+
+	colon := -1
+	for i := p.i; i < end; i++ {
+		if p.tokens[i].Type == css.ColonToken {
+			colon = i
+			break
+		}
+	}
+	if colon < 0 {
+		p.i = end
+		return nil
+	}
+
+	propertyEnd := p.trimEnd(p.i, colon)
+	n := &Node{
+		Type:     TypeDeclaration,
+		Property: p.text(p.i, propertyEnd),
+		Raws:     &Raws{},
+	}
+	valueStart := colon + 1
+	for valueStart < end && (p.tokens[valueStart].Type == css.WhitespaceToken || p.tokens[valueStart].Type == css.CommentToken) {
+		valueStart++
+	}
+	n.Raws.Between = p.content[p.tokenEnd(propertyEnd):p.tokenEnd(valueStart)]
+
+	valueEnd := p.trimEnd(valueStart, end)
+	n.Raws.After = p.content[p.tokenEnd(valueEnd):p.tokenEnd(end)]
+	if bang := p.importantStart(valueStart, valueEnd); bang >= 0 {
+		n.Important = true
+		importantStart := p.trimEnd(valueStart, bang)
+		n.Raws.Important = p.content[p.tokenEnd(importantStart):p.tokenEnd(valueEnd)]
+		valueEnd = importantStart
+	}
+	if valueEnd > valueStart {
+		n.Value = p.text(valueStart, valueEnd)
+	}
+
+	p.i = end
+	if p.i < len(p.tokens) && p.tokens[p.i].Type == css.SemicolonToken {
+		n.semicolon = true
+		p.i++
+	}
+	return n
+}
+
+// importantStart returns the index of the ! of a value ending with
+// !important, or -1.
+func (p *parser) importantStart(start int, end int) int {
+	skip := func(i int) int {
+		for i > start && (p.tokens[i-1].Type == css.WhitespaceToken || p.tokens[i-1].Type == css.CommentToken) {
+			i--
+		}
+		return i
+	}
+	end = skip(end)
+	if end == start || p.tokens[end-1].Type != css.IdentToken || !strings.EqualFold(p.tokens[end-1].Data, "important") {
+		return -1
+	}
+	end = skip(end - 1)
+	if end == start || p.tokens[end-1].Type != css.DelimToken || p.tokens[end-1].Data != "!" {
+		return -1
+	}
+	return end - 1
+}
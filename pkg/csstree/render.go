@@ -0,0 +1,110 @@
+package csstree
+
+import (
+	"strings"
+)
+
+// Render returns the CSS of n. Parsed nodes render to their source, nodes
+// without raws are indented by two spaces per level.
+func Render(n *Node) string {
+	sb := &strings.Builder{}
+	render(sb, n, 0, 0, true, true)
+	return sb.String()
+}
+
+// render writes n, the index-th child of its parent, which is depth levels
+// deep. last is set for the last child, semicolon when it ends with a
+// semicolon in the source.
+func render(sb *strings.Builder, n *Node, depth int, index int, last bool, semicolon bool) {
+	raws := n.Raws
+	if raws == nil {
+		raws = defaultRaws(n, depth, index)
+	}
+
+	if n.Type == TypeStylesheet {
+		renderChildren(sb, n, raws, 0)
+		return
+	}
+
+	sb.WriteString(raws.Before)
+	switch n.Type {
+	case TypeComment:
+		sb.WriteString("/*")
+		sb.WriteString(n.Text)
+		sb.WriteString("*/")
+
+	case TypeRule:
+		sb.WriteString(n.Selector)
+		sb.WriteString(raws.Between)
+		sb.WriteString("{")
+		renderChildren(sb, n, raws, depth+1)
+		sb.WriteString("}")
+
+	case TypeAtRule:
+		sb.WriteString("@")
+		sb.WriteString(n.Name)
+		sb.WriteString(raws.AfterName)
+		sb.WriteString(n.Prelude)
+		sb.WriteString(raws.Between)
+		if n.Block || len(n.Children) > 0 {
+			sb.WriteString("{")
+			renderChildren(sb, n, raws, depth+1)
+			sb.WriteString("}")
+		} else if !last || semicolon {
+			sb.WriteString(";")
+		}
+
+	case TypeDeclaration:
+		sb.WriteString(n.Property)
+		sb.WriteString(raws.Between)
+		sb.WriteString(n.Value)
+		if n.Important {
+			if raws.Important != "" {
+				sb.WriteString(raws.Important)
+			} else {
+				sb.WriteString(" !important")
+			}
+		}
+		sb.WriteString(raws.After)
+		if !last || semicolon {
+			sb.WriteString(";")
+		}
+	}
+}
+
+func renderChildren(sb *strings.Builder, n *Node, raws *Raws, depth int) {
+	for i, c := range n.Children {
+		render(sb, c, depth, i, i == len(n.Children)-1, raws.Semicolon)
+	}
+	sb.WriteString(raws.After)
+}
+
+func defaultRaws(n *Node, depth int, index int) *Raws {
+	indent := strings.Repeat("  ", depth)
+	ret := &Raws{
+		Before:    "\n" + indent,
+		After:     "\n" + indent,
+		Semicolon: true,
+	}
+	if depth == 0 && index == 0 {
+		ret.Before = ""
+	}
+
+	switch n.Type {
+	case TypeStylesheet:
+		ret.After = "\n"
+	case TypeRule:
+		ret.Between = " "
+	case TypeAtRule:
+		if n.Prelude != "" {
+			ret.AfterName = " "
+		}
+		if n.Block || len(n.Children) > 0 {
+			ret.Between = " "
+		}
+	case TypeDeclaration:
+		ret.Between = ": "
+		ret.After = ""
+	}
+	return ret
+}
@@ -0,0 +1,210 @@
+// Package workerpool runs jobs on a fixed number of goroutines.
+//
+// A Pool is started with a context, jobs are added with AddJob, and Close
+// waits for all of them to finish. Failed jobs don't stop the pool: their
+// errors are collected and returned by Close. An optional token-bucket rate
+// limit is shared by all workers, and an optional callback reports progress
+// after every job.
+package workerpool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Job is a unit of work. ctx is the context the pool was started with.
+type Job func(ctx context.Context) error
+
+// Progress is a snapshot of the pool's counters.
+type Progress struct {
+	// Added is the number of jobs added so far.
+	Added int
+	// Completed is the number of jobs that returned nil.
+	Completed int
+	// Failed is the number of jobs that returned an error.
+	Failed int
+	// Skipped is the number of jobs that were never run because the context
+	// was canceled.
+	Skipped int
+}
+
+// Done returns the number of jobs that are finished, one way or another.
+func (p Progress) Done() int {
+	return p.Completed + p.Failed + p.Skipped
+}
+
+// Errors is returned by Close when at least one job failed or was skipped.
+// It works with errors.Is and errors.As.
+type Errors []error
+
+func (e Errors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d errors: %s", len(e), strings.Join(msgs, "; "))
+}
+
+func (e Errors) Unwrap() []error {
+	return e
+}
+
+type Option func(*Pool)
+
+// WithRateLimit limits the pool to perSecond job starts per second on average,
+// across all workers, allowing bursts of up to burst jobs.
+// A perSecond of 0 or less means no limit.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(p *Pool) {
+		if perSecond > 0 {
+			p.limiter = newTokenBucket(perSecond, burst)
+		}
+	}
+}
+
+// WithProgress calls f after every finished job. Calls are never concurrent,
+// and f must not call back into the pool.
+func WithProgress(f func(Progress)) Option {
+	return func(p *Pool) {
+		p.onProgress = f
+	}
+}
+
+type Pool struct {
+	workerCount int
+	jobs        chan Job
+	wg          sync.WaitGroup
+	// ctx is set by Start before started is closed, and only read after
+	ctx     context.Context
+	started chan struct{}
+
+	limiter    *tokenBucket
+	onProgress func(Progress)
+
+	mu       sync.Mutex
+	progress Progress
+	errs     Errors
+	ctxErr   error
+}
+
+func New(workerCount int, options ...Option) *Pool {
+	if workerCount < 1 {
+		workerCount = 1
+	}
+	p := &Pool{
+		workerCount: workerCount,
+		jobs:        make(chan Job, workerCount),
+		started:     make(chan struct{}),
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// Start launches the workers. Once ctx is canceled, jobs that haven't
+// started yet are skipped and Close returns ctx.Err() among its errors.
+func (p *Pool) Start(ctx context.Context) {
+	p.ctx = ctx
+	close(p.started)
+	for i := 0; i < p.workerCount; i++ {
+		p.wg.Add(1)
+		go p.worker()
+	}
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for job := range p.jobs {
+		if err := p.ctx.Err(); err != nil {
+			p.finish(nil, err)
+			continue
+		}
+		if p.limiter != nil {
+			if err := p.limiter.wait(p.ctx); err != nil {
+				p.finish(nil, err)
+				continue
+			}
+		}
+		p.finish(job(p.ctx), nil)
+	}
+}
+
+// finish records the outcome of a job: err is the job's error, ctxErr is set
+// if the job was skipped.
+func (p *Pool) finish(err error, ctxErr error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	switch {
+	case ctxErr != nil:
+		p.progress.Skipped++
+		if p.ctxErr == nil {
+			p.ctxErr = ctxErr
+		}
+	case err != nil:
+		p.progress.Failed++
+		p.errs = append(p.errs, err)
+	default:
+		p.progress.Completed++
+	}
+
+	if p.onProgress != nil {
+		p.onProgress(p.progress)
+	}
+}
+
+// AddJob queues a job, blocking while all workers are busy. If the context
+// is canceled, the job is skipped instead.
+//
+// Up to workerCount jobs can be queued before Start, further calls block
+// until the pool is started.
+func (p *Pool) AddJob(job Job) {
+	p.mu.Lock()
+	p.progress.Added++
+	p.mu.Unlock()
+
+	select {
+	case p.jobs <- job:
+		return
+	case <-p.started:
+	}
+
+	select {
+	case p.jobs <- job:
+	case <-p.ctx.Done():
+		p.finish(nil, p.ctx.Err())
+	}
+}
+
+// Progress returns the current counters.
+func (p *Pool) Progress() Progress {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.progress
+}
+
+// Close waits for all queued jobs to finish and returns the errors of the
+// failed jobs as Errors, followed by the context error if jobs were skipped.
+// It returns nil if every job succeeded.
+func (p *Pool) Close() error {
+	close(p.jobs)
+	p.wg.Wait()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	errs := append(Errors{}, p.errs...)
+	if p.ctxErr != nil {
+		errs = append(errs, p.ctxErr)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
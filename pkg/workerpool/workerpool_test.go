@@ -0,0 +1,186 @@
+package workerpool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPool_CollectsErrors(t *testing.T) {
+	errOdd := errors.New("odd")
+
+	var ran int32
+	p := New(4)
+	p.Start(context.Background())
+	for i := 0; i < 10; i++ {
+		i := i
+		p.AddJob(func(ctx context.Context) error {
+			atomic.AddInt32(&ran, 1)
+			if i%2 == 1 {
+				return errOdd
+			}
+			return nil
+		})
+	}
+	err := p.Close()
+
+	if ran != 10 {
+		t.Fatalf("expected all 10 jobs to run, got %d", ran)
+	}
+	errs, ok := err.(Errors)
+	if !ok || len(errs) != 5 {
+		t.Fatalf("expected 5 collected errors, got %v", err)
+	}
+	if !errors.Is(err, errOdd) {
+		t.Fatalf("expected errors.Is to find the job error")
+	}
+	if progress := p.Progress(); progress.Completed != 5 || progress.Failed != 5 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+}
+
+func TestPool_NoErrors(t *testing.T) {
+	p := New(2)
+	p.Start(context.Background())
+	p.AddJob(func(ctx context.Context) error { return nil })
+	if err := p.Close(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+}
+
+func TestPool_AddJobBeforeStart(t *testing.T) {
+	var ran int32
+	p := New(2)
+	for i := 0; i < 2; i++ {
+		p.AddJob(func(ctx context.Context) error {
+			atomic.AddInt32(&ran, 1)
+			return nil
+		})
+	}
+	p.Start(context.Background())
+	if err := p.Close(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if ran != 2 {
+		t.Fatalf("expected the queued jobs to run, got %d", ran)
+	}
+}
+
+func TestPool_AddJobBlockedBeforeStart(t *testing.T) {
+	var ran int32
+	p := New(1)
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		for i := 0; i < 5; i++ {
+			p.AddJob(func(ctx context.Context) error {
+				atomic.AddInt32(&ran, 1)
+				return nil
+			})
+		}
+	}()
+	p.Start(context.Background())
+	<-added
+	if err := p.Close(); err != nil {
+		t.Fatalf("expected nil, got %v", err)
+	}
+	if ran != 5 {
+		t.Fatalf("expected the 5 jobs to run, got %d", ran)
+	}
+}
+
+func TestPool_CancelUnblocksAddJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(1)
+	p.Start(ctx)
+	release := make(chan struct{})
+	// the first job keeps the worker busy, the second fills the queue
+	for i := 0; i < 2; i++ {
+		p.AddJob(func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+	}
+	added := make(chan struct{})
+	go func() {
+		defer close(added)
+		p.AddJob(func(ctx context.Context) error {
+			t.Error("job ran after cancel")
+			return nil
+		})
+	}()
+	cancel()
+	<-added
+	close(release)
+	if err := p.Close(); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestPool_Cancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	p := New(1)
+	p.Start(ctx)
+	p.AddJob(func(ctx context.Context) error {
+		cancel()
+		return nil
+	})
+	for i := 0; i < 5; i++ {
+		p.AddJob(func(ctx context.Context) error {
+			t.Error("job ran after cancel")
+			return nil
+		})
+	}
+	err := p.Close()
+
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if progress := p.Progress(); progress.Completed != 1 || progress.Skipped != 5 || progress.Done() != progress.Added {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+}
+
+func TestPool_RateLimit(t *testing.T) {
+	p := New(4, WithRateLimit(100, 1))
+	p.Start(context.Background())
+
+	start := time.Now()
+	for i := 0; i < 11; i++ {
+		p.AddJob(func(ctx context.Context) error { return nil })
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first job uses the initial token, the other 10 wait 10ms each
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Fatalf("expected the rate limit to take at least 90ms, took %s", elapsed)
+	}
+}
+
+func TestPool_Progress(t *testing.T) {
+	var calls []Progress
+	p := New(3, WithProgress(func(progress Progress) {
+		calls = append(calls, progress)
+	}))
+	p.Start(context.Background())
+	for i := 0; i < 6; i++ {
+		p.AddJob(func(ctx context.Context) error { return nil })
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(calls) != 6 {
+		t.Fatalf("expected 6 progress calls, got %d", len(calls))
+	}
+	for i, progress := range calls {
+		if progress.Done() != i+1 {
+			t.Fatalf("expected call %d to report %d done jobs, got %+v", i, i+1, progress)
+		}
+	}
+}